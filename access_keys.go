@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- ACCESS KEYS: MASA BERLAKU, JUMLAH PEMAKAIAN & TIER ---
+// Satu key bisa dipakai beberapa user (max_uses), memberi akses selama Duration
+// dan tier tertentu. Redeem ulang oleh user yang masih aktif memperpanjang langganan.
+
+const defaultKeyTier = "basic"
+
+// Urutan dari paket terendah, kuota per tier ada di quota.go (tierQuotas)
+var keyTiers = []string{"basic", "pro", "enterprise"}
+
+var (
+	errKeyNotFound = errors.New("key tidak ditemukan")
+	errKeyInactive = errors.New("key sudah dinonaktifkan")
+	errKeyExpired  = errors.New("key sudah kedaluwarsa")
+	errKeyUsedUp   = errors.New("key sudah mencapai batas pemakaian")
+	errKeyRedeemed = errors.New("key ini sudah pernah Anda pakai")
+	errKeyConflict = errors.New("key sedang dipakai bersamaan, coba lagi")
+	// errKeyUnavailable: Store gagal dibaca, bukan berarti key tidak ada
+	errKeyUnavailable = errors.New("key tidak bisa diperiksa saat ini, coba lagi nanti")
+)
+
+func parseKeyTier(s string) (string, bool) {
+	s = strings.ToLower(s)
+	for _, t := range keyTiers {
+		if s == t {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// parseAccessDuration: "30d", "2w", "12h" atau "permanent"/"0" (= tanpa batas)
+func parseAccessDuration(s string) (time.Duration, error) {
+	s = strings.ToLower(s)
+	if s == "permanent" || s == "0" {
+		return 0, nil
+	}
+	units := map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if len(s) < 2 {
+		return 0, fmt.Errorf("durasi %q tidak valid", s)
+	}
+	unit, ok := units[s[len(s)-1]]
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if !ok || err != nil || n <= 0 {
+		return 0, fmt.Errorf("durasi %q tidak valid (contoh: 12h, 30d, 4w)", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// formatAccessDuration kebalikan parseAccessDuration untuk pesan bot
+func formatAccessDuration(d time.Duration) string {
+	switch {
+	case d == 0:
+		return "permanen"
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%d hari", d/(24*time.Hour))
+	default:
+		return fmt.Sprintf("%d jam", d/time.Hour)
+	}
+}
+
+// Batas /genkey <jumlah> sekali jalan
+const genKeyMaxBatch = 500
+
+// parseGenKeyArgs membaca opsi /genkey [jumlah] [durasi] [uses:N] [tier:nama] [valid:durasi].
+// Angka tanpa satuan = jumlah key yang dibuat (default 1).
+func parseGenKeyArgs(args string, now time.Time) (AccessKey, int, error) {
+	key := AccessKey{CreatedAt: now, Active: true, MaxUses: 1, Tier: defaultKeyTier}
+	count := 1
+	for _, arg := range strings.Fields(args) {
+		name, value, hasValue := strings.Cut(arg, ":")
+		if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+			if n > genKeyMaxBatch {
+				return key, 0, fmt.Errorf("maksimal %d key sekali buat", genKeyMaxBatch)
+			}
+			count = n
+			continue
+		}
+		switch {
+		case !hasValue:
+			d, err := parseAccessDuration(arg)
+			if err != nil {
+				return key, 0, err
+			}
+			key.Duration = d
+		case strings.EqualFold(name, "uses"):
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return key, 0, fmt.Errorf("uses harus angka > 0")
+			}
+			key.MaxUses = n
+		case strings.EqualFold(name, "tier"):
+			tier, ok := parseKeyTier(value)
+			if !ok {
+				return key, 0, fmt.Errorf("tier tidak dikenal, pilihan: %s", strings.Join(keyTiers, ", "))
+			}
+			key.Tier = tier
+		case strings.EqualFold(name, "valid"):
+			d, err := parseAccessDuration(value)
+			if err != nil {
+				return key, 0, err
+			}
+			if d > 0 {
+				expires := now.Add(d)
+				key.ExpiresAt = &expires
+			}
+		default:
+			return key, 0, fmt.Errorf("opsi %q tidak dikenal", arg)
+		}
+	}
+	return key, count, nil
+}
+
+// Redeemable mengecek apakah key masih bisa dipakai saat now
+func (k AccessKey) Redeemable(now time.Time) error {
+	switch {
+	case !k.Active:
+		return errKeyInactive
+	case k.ExpiresAt != nil && !now.Before(*k.ExpiresAt):
+		return errKeyExpired
+	case k.Uses >= k.maxUses():
+		return errKeyUsedUp
+	}
+	return nil
+}
+
+// redeem mencatat satu pemakaian oleh userID. Dipanggil Store di dalam operasi atomik
+// (lock / optimistic concurrency), jadi key yang dikembalikan Store sudah berisi hasilnya.
+func (k *AccessKey) redeem(userID string, now time.Time) error {
+	if err := k.Redeemable(now); err != nil {
+		return err
+	}
+	for _, id := range k.RedeemedBy {
+		if id == userID {
+			return errKeyRedeemed
+		}
+	}
+	k.Uses++
+	k.RedeemedBy = append(k.RedeemedBy, userID)
+	// Key yang habis dinonaktifkan (tidak dihapus, untuk riwayat)
+	if k.Uses >= k.maxUses() {
+		k.Active = false
+	}
+	return nil
+}
+
+// Status key untuk /keys
+const (
+	keyStatusActive  = "aktif"
+	keyStatusUsed    = "habis"
+	keyStatusExpired = "kedaluwarsa"
+	keyStatusRevoked = "dicabut"
+)
+
+var keyStatusOrder = []string{keyStatusActive, keyStatusUsed, keyStatusExpired, keyStatusRevoked}
+
+// Status: key nonaktif yang belum habis dipakai berarti dicabut lewat /revokekey
+func (k AccessKey) Status(now time.Time) string {
+	switch {
+	case k.Uses >= k.maxUses():
+		return keyStatusUsed
+	case !k.Active:
+		return keyStatusRevoked
+	case k.ExpiresAt != nil && !now.Before(*k.ExpiresAt):
+		return keyStatusExpired
+	}
+	return keyStatusActive
+}
+
+// maxUses: key lama (sebelum ada max_uses) berlaku sekali pakai
+func (k AccessKey) maxUses() int {
+	if k.MaxUses <= 0 {
+		return 1
+	}
+	return k.MaxUses
+}
+
+// Active: user masih punya akses saat now
+func (u AuthorizedUser) Active(now time.Time) bool {
+	return u.ExpiresAt == nil || now.Before(*u.ExpiresAt)
+}
+
+// tierRank: posisi tier di keyTiers, tier kosong (key lama) dianggap basic
+func tierRank(tier string) int {
+	for i, t := range keyTiers {
+		if t == tier {
+			return i
+		}
+	}
+	return 0
+}
+
+// extendAuthorization menghitung status user setelah redeem key.
+// Langganan yang masih aktif diperpanjang dari tanggal berakhirnya, yang sudah lewat dihitung dari now.
+// Akses permanen (dari key permanen atau sebelumnya) tetap permanen.
+// Tier tidak pernah turun selama langganan lama masih aktif (key basic hanya menambah waktu).
+func extendAuthorization(current AuthorizedUser, exists bool, key AccessKey, userID string, now time.Time) AuthorizedUser {
+	user := AuthorizedUser{UserID: userID, RedeemedAt: now, UsedKey: key.Key, Tier: key.Tier}
+	if exists && current.Active(now) && tierRank(current.Tier) > tierRank(key.Tier) {
+		user.Tier = current.Tier
+	}
+	if exists && current.Active(now) && current.ExpiresAt == nil {
+		return user
+	}
+	if key.Duration == 0 {
+		return user
+	}
+
+	start := now
+	if exists && current.Active(now) {
+		start = *current.ExpiresAt
+	}
+	expires := start.Add(key.Duration)
+	user.ExpiresAt = &expires
+	return user
+}
+
+// formatExpiry untuk pesan ke user
+func formatExpiry(t *time.Time) string {
+	if t == nil {
+		return "permanen"
+	}
+	return t.Format("2006-01-02 15:04")
+}
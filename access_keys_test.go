@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseGenKeyArgs tests the /genkey option parser.
+func TestParseGenKeyArgs(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		args     string
+		count    int
+		duration time.Duration
+		uses     int
+		tier     string
+		expires  bool
+		wantErr  bool
+	}{
+		{"", 1, 0, 1, "basic", false, false},
+		{"30d", 1, 30 * 24 * time.Hour, 1, "basic", false, false},
+		{"50 30d", 50, 30 * 24 * time.Hour, 1, "basic", false, false},
+		{"12h uses:5 tier:PRO", 1, 12 * time.Hour, 5, "pro", false, false},
+		{"2w valid:7d tier:enterprise 3", 3, 14 * 24 * time.Hour, 1, "enterprise", true, false},
+		{"permanent uses:3", 1, 0, 3, "basic", false, false},
+		{"501", 0, 0, 0, "", false, true},
+		{"30x", 0, 0, 0, "", false, true},
+		{"uses:0", 0, 0, 0, "", false, true},
+		{"tier:gold", 0, 0, 0, "", false, true},
+		{"foo:bar", 0, 0, 0, "", false, true},
+	}
+	for _, tt := range tests {
+		key, count, err := parseGenKeyArgs(tt.args, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGenKeyArgs(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if count != tt.count || key.Duration != tt.duration || key.MaxUses != tt.uses || key.Tier != tt.tier || (key.ExpiresAt != nil) != tt.expires {
+			t.Errorf("parseGenKeyArgs(%q) = %+v x%d", tt.args, key, count)
+		}
+	}
+}
+
+// TestAccessKeyRedeemable tests key expiry, deactivation and max uses.
+func TestAccessKeyRedeemable(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	tests := []struct {
+		name string
+		key  AccessKey
+		want error
+	}{
+		{"fresh", AccessKey{Active: true, MaxUses: 2, Uses: 1, ExpiresAt: &future}, nil},
+		{"legacy single use", AccessKey{Active: true}, nil},
+		{"used up", AccessKey{Active: true, MaxUses: 2, Uses: 2}, errKeyUsedUp},
+		{"expired", AccessKey{Active: true, MaxUses: 5, ExpiresAt: &past}, errKeyExpired},
+		{"inactive", AccessKey{MaxUses: 5}, errKeyInactive},
+	}
+	for _, tt := range tests {
+		if got := tt.key.Redeemable(now); got != tt.want {
+			t.Errorf("%s: Redeemable() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestAccessKeyStatus tests the /keys status of a key.
+func TestAccessKeyStatus(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	tests := []struct {
+		key  AccessKey
+		want string
+	}{
+		{AccessKey{Active: true, MaxUses: 3, Uses: 1}, keyStatusActive},
+		{AccessKey{Active: false, MaxUses: 1, Uses: 1}, keyStatusUsed},
+		{AccessKey{Active: false, MaxUses: 3, Uses: 1}, keyStatusRevoked},
+		{AccessKey{Active: true, ExpiresAt: &past}, keyStatusExpired},
+	}
+	for _, tt := range tests {
+		if got := tt.key.Status(now); got != tt.want {
+			t.Errorf("Status(%+v) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+// TestExtendAuthorization tests that redeeming extends an active subscription.
+func TestExtendAuthorization(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	active, lapsed := now.Add(5*day), now.Add(-5*day)
+	key := AccessKey{Key: "BR-X", Duration: 30 * day, Tier: "pro"}
+
+	tests := []struct {
+		name    string
+		current AuthorizedUser
+		exists  bool
+		key     AccessKey
+		want    *time.Time
+	}{
+		{"new user", AuthorizedUser{}, false, key, timePtr(now.Add(30 * day))},
+		{"active subscription", AuthorizedUser{ExpiresAt: &active}, true, key, timePtr(active.Add(30 * day))},
+		{"lapsed subscription", AuthorizedUser{ExpiresAt: &lapsed}, true, key, timePtr(now.Add(30 * day))},
+		{"already permanent", AuthorizedUser{}, true, key, nil},
+		{"permanent key", AuthorizedUser{ExpiresAt: &active}, true, AccessKey{Key: "BR-Y"}, nil},
+	}
+	for _, tt := range tests {
+		got := extendAuthorization(tt.current, tt.exists, tt.key, "42", now)
+		if got.UserID != "42" || got.UsedKey != tt.key.Key || got.Tier != tt.key.Tier {
+			t.Errorf("%s: extendAuthorization() = %+v", tt.name, got)
+		}
+		if (got.ExpiresAt == nil) != (tt.want == nil) || (tt.want != nil && !got.ExpiresAt.Equal(*tt.want)) {
+			t.Errorf("%s: ExpiresAt = %s, want %s", tt.name, formatExpiry(got.ExpiresAt), formatExpiry(tt.want))
+		}
+	}
+}
+
+// TestExtendAuthorizationTier tests that an active subscriber is never downgraded by a lower-tier key.
+func TestExtendAuthorizationTier(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	active, lapsed := now.Add(24*time.Hour), now.Add(-24*time.Hour)
+	basic := AccessKey{Key: "BR-B", Duration: 30 * 24 * time.Hour, Tier: "basic"}
+
+	tests := []struct {
+		name    string
+		current AuthorizedUser
+		key     AccessKey
+		want    string
+	}{
+		{"active enterprise + basic", AuthorizedUser{Tier: "enterprise", ExpiresAt: &active}, basic, "enterprise"},
+		{"permanent pro + basic", AuthorizedUser{Tier: "pro"}, basic, "pro"},
+		{"lapsed enterprise + basic", AuthorizedUser{Tier: "enterprise", ExpiresAt: &lapsed}, basic, "basic"},
+		{"active basic + pro", AuthorizedUser{Tier: "basic", ExpiresAt: &active}, AccessKey{Key: "BR-P", Tier: "pro"}, "pro"},
+	}
+	for _, tt := range tests {
+		if got := extendAuthorization(tt.current, true, tt.key, "42", now); got.Tier != tt.want {
+			t.Errorf("%s: Tier = %q, want %q", tt.name, got.Tier, tt.want)
+		}
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
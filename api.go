@@ -0,0 +1,527 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- REST API ---
+// HTTP JSON API (API_LISTEN) untuk integrasi skrip/SOC. Memakai query language, Store, exporter
+// dan JobManager yang sama dengan bot. Autentikasi: header "Authorization: Bearer <token>" dari
+// /apitoken. Token mewakili user Telegram pembuatnya, jadi role, blacklist, whitelist (mode CLOSE),
+// rate limit, kuota & log aktivitas berlaku persis seperti di bot.
+
+const (
+	apiTokenPrefix       = "brk_"
+	apiMaxTokensPerUser  = 5
+	apiDefaultSearchSize = 20
+	apiMaxSearchSize     = 100
+	apiMaxBodyBytes      = 1 << 20
+)
+
+type APIServer struct {
+	store   Store
+	config  *LiveConfig
+	limiter *RateLimiter
+	jobs    *JobManager
+	ownerID int64
+}
+
+func NewAPIServer(store Store, globalConfig *LiveConfig, limiter *RateLimiter, jobs *JobManager, ownerID int64) *APIServer {
+	return &APIServer{store: store, config: globalConfig, limiter: limiter, jobs: jobs, ownerID: ownerID}
+}
+
+// apiCaller: pemilik token yang sedang memanggil API
+type apiCaller struct {
+	User  *tgbotapi.User // Hanya ID yang terisi, cukup untuk LogActivity & kuota
+	Role  Role
+	Token APIToken
+}
+
+// apiRoute: syarat satu endpoint, padanan field Command di router bot
+type apiRoute struct {
+	Perm           Permission
+	RequiresAccess bool
+	Cost           int // Token rate limit (0 = tidak dihitung)
+	Handler        func(w http.ResponseWriter, r *http.Request, caller *apiCaller)
+}
+
+// Handler: semua endpoint /api/v1
+func (s *APIServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/search", s.route(apiRoute{RequiresAccess: true, Cost: searchCost, Handler: s.handleSearch}))
+	mux.Handle("POST /api/v1/export", s.route(apiRoute{RequiresAccess: true, Cost: exportCost, Handler: s.handleExport}))
+	mux.Handle("POST /api/v1/ingest", s.route(apiRoute{Perm: PermIngest, Handler: s.handleIngest}))
+	mux.Handle("GET /api/v1/jobs/{id}", s.route(apiRoute{Perm: PermIngest, Handler: s.handleJob}))
+	mux.Handle("GET /api/v1/stats", s.route(apiRoute{Perm: PermSystem, Handler: s.handleStats}))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	return mux
+}
+
+// runAPI menjalankan HTTP server API (blocking). TLS opsional, biasanya di reverse proxy.
+func runAPI(s *APIServer, listen string) error {
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second, // Tanpa WriteTimeout: export besar bisa lama
+	}
+	log.Printf("🔌 REST API aktif di %s", listen)
+	if cert, key := os.Getenv("API_TLS_CERT"), os.Getenv("API_TLS_KEY"); cert != "" && key != "" {
+		return server.ListenAndServeTLS(cert, key)
+	}
+	return server.ListenAndServe()
+}
+
+// route menjalankan pemeriksaan dengan urutan yang sama seperti middleware router:
+// token -> role -> ban -> permission -> rate limit -> akses
+func (s *APIServer) route(rt apiRoute) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="breachradar"`)
+			writeAPIError(w, http.StatusUnauthorized, "token API tidak valid")
+			return
+		}
+		userID := strconv.FormatInt(caller.User.ID, 10)
+
+		if !caller.Role.Can(PermStaff) && s.store.IsUserBanned(userID) {
+			writeAPIError(w, http.StatusForbidden, "akun masuk daftar hitam (blacklist)")
+			return
+		}
+		if rt.Perm != "" && !caller.Role.Can(rt.Perm) {
+			writeAPIError(w, http.StatusForbidden, "role tidak memiliki izin untuk endpoint ini")
+			return
+		}
+
+		now := time.Now()
+		config := s.config.Get()
+		if rt.Cost > 0 && !caller.Role.Can(PermStaff) {
+			if ok, wait, _ := s.limiter.Allow(caller.User.ID, rt.Cost, &config, now); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeAPIError(w, http.StatusTooManyRequests, fmt.Sprintf("rate limit: %d request/menit (burst %d)", config.RateLimit, config.rateBurst()))
+				return
+			}
+		}
+
+		if rt.RequiresAccess && !caller.Role.Can(PermAccess) && config.Mode != "OPEN" {
+			user, ok := s.store.GetAuthorizedUser(userID)
+			if !ok {
+				writeAPIError(w, http.StatusForbidden, "bot dalam mode privat, redeem kode akses lewat /redeem")
+				return
+			}
+			if !user.Active(now) {
+				writeAPIError(w, http.StatusForbidden, "langganan habis pada "+formatExpiry(user.ExpiresAt))
+				return
+			}
+		}
+		rt.Handler(w, r, caller)
+	})
+}
+
+// authenticate mencari token berdasarkan hash-nya, role selalu dihitung ulang (bisa berubah lewat /grant)
+func (s *APIServer) authenticate(r *http.Request) (*apiCaller, bool) {
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	raw = strings.TrimSpace(raw)
+	if !ok || !strings.HasPrefix(raw, apiTokenPrefix) {
+		return nil, false
+	}
+	token, ok := s.store.GetAPIToken(generateFingerprint(raw))
+	if !ok {
+		return nil, false
+	}
+	userID, err := strconv.ParseInt(token.UserID, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &apiCaller{
+		User:  &tgbotapi.User{ID: userID},
+		Role:  resolveRole(s.store, s.ownerID, userID),
+		Token: token,
+	}, true
+}
+
+// --- RESPONSES ---
+
+type apiErrorResponse struct {
+	Error    string `json:"error"`
+	Position int    `json:"position,omitempty"` // Posisi karakter (mulai 1) untuk error query
+}
+
+type apiSearchResponse struct {
+	Query     string                   `json:"query"`
+	Total     int                      `json:"total"`
+	Sensitive SensitivePolicy          `json:"sensitive"`
+	Hits      []map[string]interface{} `json:"hits"`
+}
+
+type apiStatsResponse struct {
+	Mode          string   `json:"mode"`
+	RateLimit     int      `json:"rate_limit"`
+	RateBurst     int      `json:"rate_burst"`
+	TotalRecords  int64    `json:"total_records"`
+	TotalSources  int      `json:"total_sources"`
+	VerifiedUsers int64    `json:"verified_users"`
+	TopSearches   []string `json:"top_searches"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, apiErrorResponse{Error: msg})
+}
+
+// writeQueryError: padanan validateQuery untuk API (posisi error dikirim sebagai angka)
+func writeQueryError(w http.ResponseWriter, err error) {
+	var syntaxErr *QuerySyntaxError
+	if errors.As(err, &syntaxErr) {
+		writeJSON(w, http.StatusBadRequest, apiErrorResponse{
+			Error:    "query tidak valid: " + syntaxErr.Msg,
+			Position: utf8.RuneCountInString(syntaxErr.Input[:syntaxErr.Pos]) + 1,
+		})
+		return
+	}
+	writeAPIError(w, http.StatusBadRequest, "query tidak valid")
+}
+
+// decodeJSONBody membaca body JSON (maksimal apiMaxBodyBytes), field tak dikenal ditolak
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, out interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiMaxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "body JSON tidak valid: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// --- ENDPOINTS ---
+
+// GET /api/v1/search?q=<query>&size=<1-100>
+func (s *APIServer) handleSearch(w http.ResponseWriter, r *http.Request, caller *apiCaller) {
+	keyword := strings.TrimSpace(r.URL.Query().Get("q"))
+	if keyword == "" {
+		writeAPIError(w, http.StatusBadRequest, "parameter q wajib diisi")
+		return
+	}
+	if _, err := parseSearchQuery(keyword); err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	size := apiDefaultSearchSize
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > apiMaxSearchSize {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("size harus 1-%d", apiMaxSearchSize))
+			return
+		}
+		size = n
+	}
+
+	// Kuota harian sama dengan /s (lihat quotaMiddleware)
+	now := time.Now()
+	quota, err := updateQuota(s.store, caller.User.ID, caller.Role, now, chargeSearch)
+	if err == errQuotaExhausted {
+		writeAPIError(w, http.StatusTooManyRequests, fmt.Sprintf("kuota %d pencarian/hari habis, reset %s",
+			quota.Limits.SearchesPerDay, nextQuotaDay(now).Format(time.RFC3339)))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	s.store.LogActivity(caller.User, "API_SEARCH", keyword)
+
+	result, err := s.store.SearchBreaches(keyword, size)
+	if err != nil {
+		// Kegagalan cluster bukan salah user, jatah pencarian dikembalikan
+		updateQuota(s.store, caller.User.ID, caller.Role, time.Now(), refundSearch(quota.Day))
+		writeAPIError(w, http.StatusBadGateway, "error database")
+		return
+	}
+
+	// Kolom sensitif mengikuti export policy role pemilik token
+	config := s.config.Get()
+	policy := exportPolicyFor(&config, caller.Role)
+	hits := make([]map[string]interface{}, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		doc := exportRow(hit.Source)
+		policy.Apply(doc, config.exportHashKey())
+		hits = append(hits, doc)
+	}
+	writeJSON(w, http.StatusOK, apiSearchResponse{Query: keyword, Total: result.Hits.Total.Value, Sensitive: policy, Hits: hits})
+}
+
+// POST /api/v1/export {"q": "...", "format": "csv|json|ndjson|xlsx|html", "secure": false}
+// Response berupa file (satu bagian, tanpa batas ukuran Telegram). Jumlah data ada di header X-Export-*.
+func (s *APIServer) handleExport(w http.ResponseWriter, r *http.Request, caller *apiCaller) {
+	var body struct {
+		Query  string `json:"q"`
+		Format string `json:"format"`
+		Secure bool   `json:"secure"`
+	}
+	if !decodeJSONBody(w, r, &body) {
+		return
+	}
+	config := s.config.Get()
+	req := ExportRequest{Format: strings.ToLower(body.Format), Keyword: strings.TrimSpace(body.Query), Encrypt: body.Secure}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+	req = req.forUser(caller.User.ID, caller.Role, &config)
+
+	exporter, ok := exporters[req.Format]
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, "format tidak dikenal, pilihan: csv, json, ndjson, xlsx, html")
+		return
+	}
+	if req.Keyword == "" {
+		writeAPIError(w, http.StatusBadRequest, "field q wajib diisi")
+		return
+	}
+	if _, err := parseSearchQuery(req.Keyword); err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	// Kuota bulanan dipesan di depan, sama dengan handleExport di bot
+	now := time.Now()
+	reservation, quota, err := reserveExport(s.store, req.UserID, req.Role, now)
+	if err == errQuotaExhausted {
+		writeAPIError(w, http.StatusTooManyRequests, fmt.Sprintf("kuota export habis (export %s, baris %s), reset %s",
+			formatQuota(int64(quota.Exports), int64(quota.Limits.ExportsPerMonth)), formatQuota(quota.ExportRows, quota.Limits.ExportRows),
+			nextQuotaMonth(now).Format(time.RFC3339)))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	sentRows := int64(-1) // Tetap -1 jika file tidak terkirim utuh = kuota dikembalikan penuh
+	defer func() { reservation.settle(sentRows) }()
+	s.store.LogActivity(caller.User, "API_EXPORT", req.Format+" "+req.Keyword)
+
+	sp, err := spoolSearchResults(s.store, req.Keyword, req.Sensitive, []byte(req.HashKey), reservation.MaxRows(), nil)
+	if sp == nil {
+		writeAPIError(w, http.StatusBadGateway, "gagal export: "+err.Error())
+		return
+	}
+	defer sp.Close()
+	if err != nil {
+		log.Printf("⚠️ Export API %q terhenti di %d/%d: %v", req.Keyword, sp.Rows, sp.Total, err)
+	}
+	if sp.Rows == 0 {
+		writeAPIError(w, http.StatusNotFound, "data tidak ditemukan")
+		return
+	}
+
+	// Tidak ada batas upload di HTTP, jadi selalu satu file
+	parts, err := exporter.Export(sp, "result_"+sanitizeFileName(req.Keyword), math.MaxInt64)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "gagal menulis file export: "+err.Error())
+		return
+	}
+	password := ""
+	if req.Encrypt {
+		password = generateExportPassword()
+		if parts, err = encryptExportParts(parts, password); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "gagal mengenkripsi file export: "+err.Error())
+			return
+		}
+	}
+
+	file, err := os.Open(parts[0].Path)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	name := filepath.Base(parts[0].Path)
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	h.Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	h.Set("X-Export-Rows", strconv.Itoa(sp.Rows))
+	h.Set("X-Export-Total", strconv.Itoa(sp.Total))
+	h.Set("X-Export-Capped", strconv.FormatBool(sp.Capped))
+	h.Set("X-Export-Sensitive", string(req.Sensitive))
+	if password != "" {
+		h.Set("X-Export-Password", password)
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("⚠️ Export API %q gagal dikirim: %v", req.Keyword, err)
+		return
+	}
+	sentRows = int64(sp.Rows)
+}
+
+// POST /api/v1/ingest {"url": "https://...", "source": "opsional.csv"}
+// Notifikasi progres job dikirim ke chat pribadi pemilik token.
+func (s *APIServer) handleIngest(w http.ResponseWriter, r *http.Request, caller *apiCaller) {
+	var body struct {
+		URL    string `json:"url"`
+		Source string `json:"source"`
+	}
+	if !decodeJSONBody(w, r, &body) {
+		return
+	}
+	u, err := url.Parse(body.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeAPIError(w, http.StatusBadRequest, "url wajib berupa link http(s) direct download")
+		return
+	}
+	source := strings.TrimSpace(body.Source)
+	if source == "" {
+		// Sama dengan handleURLUpload
+		parts := strings.Split(body.URL, "/")
+		source = "url_" + parts[len(parts)-1]
+	}
+
+	s.store.LogActivity(caller.User, "API_UPLOAD_URL", body.URL)
+	job, err := s.jobs.Submit(IngestJobRecord{
+		Source: source,
+		Kind:   "url",
+		URL:    body.URL,
+		ChatID: caller.User.ID,
+	})
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.jobs.snapshot(job))
+}
+
+// GET /api/v1/jobs/{id}
+func (s *APIServer) handleJob(w http.ResponseWriter, r *http.Request, caller *apiCaller) {
+	rec, ok := s.jobs.Get(strings.ToUpper(r.PathValue("id")))
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "job tidak ditemukan")
+		return
+	}
+	writeJSON(w, http.StatusOK, rec)
+}
+
+// GET /api/v1/stats: isi yang sama dengan /stats
+func (s *APIServer) handleStats(w http.ResponseWriter, r *http.Request, caller *apiCaller) {
+	stats := s.store.GetClusterStats()
+	config := s.config.Get()
+	writeJSON(w, http.StatusOK, apiStatsResponse{
+		Mode:          config.Mode,
+		RateLimit:     config.RateLimit,
+		RateBurst:     config.rateBurst(),
+		TotalRecords:  stats.TotalRecords,
+		TotalSources:  stats.TotalSources,
+		VerifiedUsers: stats.TotalUsers,
+		TopSearches:   stats.TopSearches,
+	})
+}
+
+// --- TOKEN MANAGEMENT ---
+
+// newAPIToken: token asli dikembalikan sekali, Store hanya menyimpan hash-nya
+func newAPIToken(userID, name string, now time.Time) (string, APIToken) {
+	bytes := make([]byte, 24)
+	rand.Read(bytes)
+	plain := apiTokenPrefix + hex.EncodeToString(bytes)
+	hash := generateFingerprint(plain)
+	return plain, APIToken{Hash: hash, ID: hash[:8], UserID: userID, Name: name, CreatedAt: now}
+}
+
+// /apitoken                -> daftar token milik sendiri
+// /apitoken new <nama>     -> buat token (hanya di chat pribadi)
+// /apitoken revoke <id>    -> hapus token (pemegang PermKeys boleh token user lain)
+func handleAPIToken(ctx *CommandContext) {
+	userID := strconv.FormatInt(ctx.User.ID, 10)
+	action, arg, _ := strings.Cut(ctx.Args, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch strings.ToLower(action) {
+	case "":
+		tokens := ctx.Store.ListAPITokens(userID)
+		if len(tokens) == 0 {
+			ctx.Reply("📭 Belum ada token API. Buat dengan `/apitoken new <nama>`.")
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString("🔌 **TOKEN API**\n\n")
+		for _, t := range tokens {
+			sb.WriteString(fmt.Sprintf("`%s` %s — dibuat %s\n", t.ID, t.Name, t.CreatedAt.Format("2006-01-02")))
+		}
+		ctx.Reply(sb.String())
+
+	case "new":
+		if arg == "" {
+			ctx.ReplyUsage()
+			return
+		}
+		if !ctx.Msg.Chat.IsPrivate() {
+			ctx.Reply("⚠️ Token API hanya bisa dibuat di chat pribadi dengan bot.")
+			return
+		}
+		if len(ctx.Store.ListAPITokens(userID)) >= apiMaxTokensPerUser {
+			ctx.Reply(fmt.Sprintf("❌ Maksimal %d token per user. Hapus dulu dengan `/apitoken revoke <id>`.", apiMaxTokensPerUser))
+			return
+		}
+		plain, token := newAPIToken(userID, arg, time.Now())
+		ctx.Store.SaveAPIToken(token)
+		ctx.Store.LogActivity(ctx.User, "APITOKEN_NEW", token.ID+" "+arg)
+
+		reply := tgbotapi.NewMessage(ctx.ChatID, fmt.Sprintf("🔌 *TOKEN API DIBUAT*\nID: `%s`\nToken: `%s`\n\nPakai header `Authorization: Bearer <token>`.\n_Simpan sekarang, token tidak akan ditampilkan lagi._", token.ID, plain))
+		reply.ParseMode = "Markdown"
+		ctx.Bot.Send(reply)
+
+	case "revoke":
+		if arg == "" {
+			ctx.ReplyUsage()
+			return
+		}
+		scope := userID
+		if ctx.Role.Can(PermKeys) {
+			scope = ""
+		}
+		for _, t := range ctx.Store.ListAPITokens(scope) {
+			if t.ID == strings.ToLower(arg) && ctx.Store.DeleteAPIToken(t.Hash) {
+				ctx.Store.LogActivity(ctx.User, "APITOKEN_REVOKE", t.ID+" "+t.UserID)
+				ctx.Reply(fmt.Sprintf("🗑 Token `%s` (%s) dihapus.", t.ID, t.Name))
+				return
+			}
+		}
+		ctx.Reply(fmt.Sprintf("❌ Token `%s` tidak ditemukan.", arg))
+
+	default:
+		ctx.ReplyUsage()
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAPI returns an API server backed by a MemoryStore and one token per role.
+func newTestAPI(t *testing.T, config SystemConfig) (*APIServer, *MemoryStore, map[Role]string) {
+	t.Helper()
+	store := NewMemoryStore()
+	store.IndexDocument(map[string]interface{}{"email": "rudi@acme.com", "password": "hunter2", "leak_source": "a.csv", "full_text": "rudi"}, "1")
+	store.IndexDocument(map[string]interface{}{"email": "sari@acme.com", "password": "qwerty", "leak_source": "a.csv", "full_text": "sari"}, "2")
+
+	tokens := make(map[Role]string)
+	users := map[Role]string{roleOwner: "99", roleAdmin: "10", roleUser: "20"}
+	for role, id := range users {
+		if role != roleOwner {
+			store.SetUserRole(RoleAssignment{UserID: id, Role: role})
+		}
+		plain, token := newAPIToken(id, string(role), time.Now())
+		store.SaveAPIToken(token)
+		tokens[role] = plain
+	}
+	api := NewAPIServer(store, NewLiveConfig(config), NewRateLimiter(""), NewJobManager(nil, store, 0), 99)
+	return api, store, tokens
+}
+
+func doAPI(h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestAPIAuth tests token validation, permissions, bans and the CLOSE mode whitelist.
+func TestAPIAuth(t *testing.T) {
+	api, store, tokens := newTestAPI(t, SystemConfig{Mode: "CLOSE", RateLimit: 100})
+	h := api.Handler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"missing token", "GET", "/api/v1/stats", "", http.StatusUnauthorized},
+		{"unknown token", "GET", "/api/v1/stats", apiTokenPrefix + "deadbeef", http.StatusUnauthorized},
+		{"owner stats", "GET", "/api/v1/stats", tokens[roleOwner], http.StatusOK},
+		{"admin stats", "GET", "/api/v1/stats", tokens[roleAdmin], http.StatusOK},
+		{"user stats", "GET", "/api/v1/stats", tokens[roleUser], http.StatusForbidden},
+		{"user ingest", "POST", "/api/v1/ingest", tokens[roleUser], http.StatusForbidden},
+		{"user search without key", "GET", "/api/v1/search?q=rudi", tokens[roleUser], http.StatusForbidden},
+		{"wrong method", "POST", "/api/v1/search", tokens[roleOwner], http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if rec := doAPI(h, tt.method, tt.path, tt.token, ""); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+
+	store.AuthorizeUser(AuthorizedUser{UserID: "20"})
+	if rec := doAPI(h, "GET", "/api/v1/search?q=rudi", tokens[roleUser], ""); rec.Code != http.StatusOK {
+		t.Errorf("authorized user search: status = %d", rec.Code)
+	}
+	store.BanUser("20", "spam")
+	if rec := doAPI(h, "GET", "/api/v1/search?q=rudi", tokens[roleUser], ""); rec.Code != http.StatusForbidden {
+		t.Errorf("banned user search: status = %d", rec.Code)
+	}
+
+	// Token yang dihapus lewat /apitoken revoke langsung ditolak
+	store.DeleteAPIToken(generateFingerprint(tokens[roleAdmin]))
+	if rec := doAPI(h, "GET", "/api/v1/stats", tokens[roleAdmin], ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked token: status = %d", rec.Code)
+	}
+}
+
+// TestAPISearch tests search results, sensitive field policy, query errors, rate limit and logging.
+func TestAPISearch(t *testing.T) {
+	api, store, tokens := newTestAPI(t, SystemConfig{Mode: "OPEN", RateLimit: 60, RateBurst: 2})
+	h := api.Handler()
+
+	rec := doAPI(h, "GET", "/api/v1/search?q=email:rudi@acme.com", tokens[roleUser], "")
+	var res apiSearchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("search: status %d, %v", rec.Code, err)
+	}
+	if res.Total != 1 || len(res.Hits) != 1 || res.Hits[0]["password"] != "********" || res.Hits[0]["full_text"] != nil {
+		t.Errorf("search result = %+v, want one masked hit without internal fields", res)
+	}
+
+	rec = doAPI(h, "GET", "/api/v1/search?q=(rudi", tokens[roleUser], "")
+	var apiErr apiErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &apiErr)
+	if rec.Code != http.StatusBadRequest || apiErr.Position == 0 {
+		t.Errorf("invalid query: status %d, %+v", rec.Code, apiErr)
+	}
+
+	// Burst 2 sudah habis (query invalid tetap memakai token, sama seperti di bot)
+	if rec := doAPI(h, "GET", "/api/v1/search?q=rudi", tokens[roleUser], ""); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("rate limit: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// Owner tidak kena rate limit & melihat password apa adanya
+	rec = doAPI(h, "GET", "/api/v1/search?q=rudi&size=1", tokens[roleOwner], "")
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	if rec.Code != http.StatusOK || len(res.Hits) != 1 || res.Hits[0]["password"] != "hunter2" {
+		t.Errorf("owner search: status %d, %+v", rec.Code, res)
+	}
+
+	logs, _ := store.SearchActivity("email:rudi@acme.com", 10)
+	if logs.Hits.Total.Value != 1 || logs.Hits.Hits[0].Source["action_type"] != "API_SEARCH" || logs.Hits.Hits[0].Source["user_id"] != "20" {
+		t.Errorf("activity log = %+v, want one API_SEARCH", logs.Hits.Hits)
+	}
+	if q, _, _ := store.GetUserQuota("20"); q.Searches != 1 {
+		t.Errorf("searches counted = %d, want 1", q.Searches)
+	}
+}
+
+// downSearchStore fails every breach search, as if the cluster were unreachable.
+type downSearchStore struct{ *MemoryStore }
+
+func (downSearchStore) SearchBreaches(keyword string, size int) (*ESResponse, error) {
+	return nil, errors.New("503 Service Unavailable")
+}
+
+// TestAPISearchRefund tests that a search failing in the database does not use up quota.
+func TestAPISearchRefund(t *testing.T) {
+	api, store, tokens := newTestAPI(t, SystemConfig{Mode: "OPEN", RateLimit: 100})
+	api.store = downSearchStore{store}
+	h := api.Handler()
+
+	if rec := doAPI(h, "GET", "/api/v1/search?q=rudi", tokens[roleUser], ""); rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", rec.Code)
+	}
+	if q, _, _ := store.GetUserQuota("20"); q.Searches != 0 {
+		t.Errorf("searches counted = %d after database error, want 0", q.Searches)
+	}
+}
+
+// TestAPIExport tests file export over HTTP and that export quota is charged.
+func TestAPIExport(t *testing.T) {
+	api, store, tokens := newTestAPI(t, SystemConfig{Mode: "OPEN", RateLimit: 100})
+	h := api.Handler()
+
+	rec := doAPI(h, "POST", "/api/v1/export", tokens[roleUser], `{"q": "password:*", "format": "ndjson"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	if rows := rec.Header().Get("X-Export-Rows"); rows != "2" {
+		t.Errorf("X-Export-Rows = %q, want 2", rows)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, ".ndjson") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 2 || strings.Contains(rec.Body.String(), "hunter2") {
+		t.Errorf("export body = %q, want 2 masked rows", rec.Body.String())
+	}
+	if q, _, _ := store.GetUserQuota("20"); q.Exports != 1 || q.ExportRows != 2 {
+		t.Errorf("export quota = %+v, want 1 export / 2 rows", q)
+	}
+
+	for _, body := range []string{`{"q": "rudi", "format": "pdf"}`, `{"format": "csv"}`, `{"q": "rudi", "foo": 1}`, `{`} {
+		if rec := doAPI(h, "POST", "/api/v1/export", tokens[roleOwner], body); rec.Code != http.StatusBadRequest {
+			t.Errorf("export %s: status %d, want 400", body, rec.Code)
+		}
+	}
+	if rec := doAPI(h, "POST", "/api/v1/export", tokens[roleOwner], `{"q": "nobody"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("empty export: status %d, want 404", rec.Code)
+	}
+}
+
+// TestAPIIngest tests that ingest requests become queued jobs owned by the caller.
+func TestAPIIngest(t *testing.T) {
+	api, store, tokens := newTestAPI(t, SystemConfig{Mode: "OPEN", RateLimit: 100})
+	h := api.Handler()
+
+	rec := doAPI(h, "POST", "/api/v1/ingest", tokens[roleAdmin], `{"url": "https://files.example.com/dump/leak.csv"}`)
+	var job IngestJobRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil || rec.Code != http.StatusAccepted {
+		t.Fatalf("ingest: status %d, %v", rec.Code, err)
+	}
+	if job.Source != "url_leak.csv" || job.Kind != "url" || job.ChatID != 10 || job.Status != jobQueued {
+		t.Errorf("job = %+v", job)
+	}
+	if jobs := store.ListIngestJobs(); len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Errorf("stored jobs = %+v", jobs)
+	}
+
+	if rec := doAPI(h, "GET", "/api/v1/jobs/"+strings.ToLower(job.ID), tokens[roleAdmin], ""); rec.Code != http.StatusOK {
+		t.Errorf("job status: %d", rec.Code)
+	}
+	if rec := doAPI(h, "GET", "/api/v1/jobs/J-NOPE", tokens[roleAdmin], ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown job: %d", rec.Code)
+	}
+	if rec := doAPI(h, "POST", "/api/v1/ingest", tokens[roleAdmin], `{"url": "file:///etc/passwd"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("non-http url: status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Kategori /help (urutan tampil = urutan registrasi pertama)
+const (
+	catSearch  = "🔍 *Pencarian Data*"
+	catTools   = "🛠️ *Fitur & Tools*"
+	catSystem  = "⚙️ *System Control*"
+	catAccess  = "🔑 *Access Management*"
+	catComm    = "📢 *Communication*"
+	catDataMgr = "📥 *Data Management*"
+)
+
+// newCommandRouter mendaftarkan semua command bot beserta middleware-nya
+func newCommandRouter(globalConfig *LiveConfig, jobs *JobManager, limiter *RateLimiter, ownerID int64) *Router {
+	r := NewRouter()
+	sessions := NewSearchSessions(searchSessionTTL)
+	r.Use(
+		roleMiddleware(ownerID),
+		banMiddleware(),
+		permissionMiddleware(),
+		rateLimitMiddleware(globalConfig, limiter),
+		accessMiddleware(globalConfig),
+		quotaMiddleware(),
+	)
+
+	// --- USER FEATURES ---
+	r.Handle(&Command{
+		Name: "/s", RequiresAccess: true, Cost: searchCost, Quota: QuotaSearch,
+		Category: catSearch, Usage: "/s <keyword>", Help: "Cari data (cth: `rudi`, `email:rudi@gmail.com`, `domain:acme.com AND password:* NOT source:old.txt`, `\"rudi hartono\"`, `(a OR b)`)",
+		Handler: func(ctx *CommandContext) {
+			if ctx.Args == "" {
+				ctx.Reply("⚠️ Gunakan format: `/s <keyword>`\nContoh: `/s sudi` atau `/s email:sudi@gmail.com`")
+				return
+			}
+			ctx.Store.LogActivity(ctx.User, "SEARCH", ctx.Args) // Log keyword bersih
+			handleSearch(ctx.Bot, ctx.Msg, ctx.Store, sessions, ctx.Args)
+		},
+	})
+	r.Handle(&Command{
+		Callback: searchCallback, RequiresAccess: true,
+		CostFunc: func(ctx *CommandContext) int {
+			// Tombol Export sama beratnya dengan /export
+			if strings.HasSuffix(ctx.Args, ":export") {
+				return exportCost
+			}
+			return searchCost
+		},
+		Handler: func(ctx *CommandContext) {
+			handleSearchCallback(ctx, sessions, globalConfig.Get())
+		},
+	})
+	r.Handle(&Command{
+		Name: "/export", RequiresAccess: true, Cost: exportCost,
+		Category: catTools, Usage: "/export [secure] [csv|json|ndjson|xlsx|html] <keyword>", Help: "Download hasil lengkap (default CSV, `html` = laporan ringkas, `secure` = zip berpassword)",
+		Handler: func(ctx *CommandContext) {
+			ctx.Store.LogActivity(ctx.User, "EXPORT", ctx.Msg.Text)
+			if ctx.Args == "" {
+				ctx.ReplyUsage()
+				return
+			}
+			config := globalConfig.Get()
+			handleExport(ctx.Bot, ctx.Msg, ctx.Store, parseExportArgs(ctx.Args).withPolicy(ctx, &config))
+		},
+	})
+	r.Handle(&Command{
+		Name: "/domain", RequiresAccess: true, Cost: searchCost, Quota: QuotaSearch,
+		Category: catSearch, Usage: "/domain <domain>", Help: "Ringkasan eksposur satu domain (akun, source, password) + laporan lengkap",
+		Handler: func(ctx *CommandContext) {
+			handleDomain(ctx)
+		},
+	})
+	r.Handle(&Command{
+		Callback: domainCallback, RequiresAccess: true, Cost: exportCost,
+		Handler: func(ctx *CommandContext) {
+			handleDomainCallback(ctx, globalConfig.Get())
+		},
+	})
+	r.Handle(&Command{
+		Name: "/watch", RequiresAccess: true, Cost: searchCost,
+		Category: catSearch, Usage: "/watch [query]", Help: "Pantau data baru (cth: `email:ceo@acme.com`, `domain:acme.com`), tanpa query = daftar watch",
+		Handler: func(ctx *CommandContext) {
+			handleWatch(ctx)
+		},
+	})
+	r.Handle(&Command{
+		Name:     "/unwatch",
+		Category: catSearch, Usage: "/unwatch <id|all>", Help: "Berhenti memantau",
+		Handler: func(ctx *CommandContext) {
+			handleUnwatch(ctx)
+		},
+	})
+	r.Handle(&Command{
+		Name:     "/quota",
+		Category: catTools, Help: "Cek sisa kuota pencarian & export",
+		Handler: func(ctx *CommandContext) {
+			handleQuota(ctx)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/apitoken", RequiresAccess: true, Cost: 1,
+		Category: catTools, Usage: "/apitoken [new <nama> | revoke <id>]", Help: "Kelola token REST API",
+		Handler: func(ctx *CommandContext) {
+			handleAPIToken(ctx)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/redeem", Cost: 1,
+		Category: catTools, Usage: "/redeem <kode>", Help: "Masukkan kode akses VIP",
+		Handler: func(ctx *CommandContext) {
+			handleRedeem(ctx.Bot, ctx.Msg, ctx.Store, ctx.Args)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/help", Aliases: []string{"/start"},
+		Category: catTools, Help: "Menampilkan pesan ini",
+		Handler: func(ctx *CommandContext) {
+			handleHelp(ctx.Bot, ctx.ChatID, r, ctx.Role)
+		},
+	})
+
+	// --- SYSTEM CONTROL (ADMIN) ---
+	r.Handle(&Command{
+		Name: "/open", Perm: PermSystem,
+		Category: catSystem, Help: "Buka bot untuk publik",
+		Handler: func(ctx *CommandContext) {
+			globalConfig.Update(ctx.Store, func(c *SystemConfig) { c.Mode = "OPEN" }) // Update RAM & DB
+			handleAccessControl(ctx.Bot, ctx.ChatID, ctx.Store, "/open")
+		},
+	})
+	r.Handle(&Command{
+		Name: "/close", Perm: PermSystem,
+		Category: catSystem, Help: "Kunci bot (Mode Privat)",
+		Handler: func(ctx *CommandContext) {
+			globalConfig.Update(ctx.Store, func(c *SystemConfig) { c.Mode = "CLOSE" }) // Update RAM & DB
+			handleAccessControl(ctx.Bot, ctx.ChatID, ctx.Store, "/close")
+		},
+	})
+	r.Handle(&Command{
+		Name: "/setlimit", Perm: PermSystem,
+		Category: catSystem, Usage: "/setlimit <n> [burst]", Help: "Set rate limit per menit & burst (cth: `300 50`)",
+		Handler: func(ctx *CommandContext) {
+			fields := strings.Fields(ctx.Args)
+			if len(fields) == 0 || len(fields) > 2 {
+				ctx.ReplyUsage()
+				return
+			}
+			newLimit, err := strconv.Atoi(fields[0])
+			if err != nil || newLimit < 1 {
+				ctx.Reply("❌ Angka tidak valid.")
+				return
+			}
+			newBurst := 0 // 0 = sama dengan limit
+			if len(fields) == 2 {
+				if newBurst, err = strconv.Atoi(fields[1]); err != nil || newBurst < 1 {
+					ctx.Reply("❌ Burst tidak valid.")
+					return
+				}
+			}
+			// Update Config di RAM & Database
+			config := globalConfig.Update(ctx.Store, func(c *SystemConfig) {
+				c.RateLimit = newLimit
+				c.RateBurst = newBurst
+			})
+			ctx.Reply(fmt.Sprintf("⚡ **LIMIT UPDATED**\nBatas request user: %d per menit, burst %d (export = %d token).", newLimit, config.rateBurst(), exportCost))
+		},
+	})
+	r.Handle(&Command{
+		Name: "/exportpolicy", Perm: PermSystem,
+		Category: catSystem, Usage: "/exportpolicy [<role> mask|hash|include] [encrypt on|off]", Help: "Atur kolom sensitif & enkripsi export",
+		Handler: func(ctx *CommandContext) {
+			handleExportPolicy(ctx.Bot, ctx.ChatID, ctx.Store, globalConfig, ctx.Args)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/stats", Perm: PermSystem,
+		Category: catSystem, Help: "Cek status server & data",
+		Handler: func(ctx *CommandContext) {
+			handleStats(ctx.Bot, ctx.ChatID, ctx.Store)
+		},
+	})
+
+	// --- ACCESS MANAGEMENT (ADMIN) ---
+	r.Handle(&Command{
+		Name: "/genkey", Perm: PermKeys,
+		Category: catAccess, Usage: "/genkey [jumlah] [30d|permanent] [uses:N] [tier:basic|pro|enterprise] [valid:7d]",
+		Help: "Buat kode invite (jumlah > 1 dikirim sebagai CSV)",
+		Handler: func(ctx *CommandContext) {
+			handleGenKey(ctx)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/setquota", Perm: PermQuota,
+		Category: catAccess, Usage: "/setquota <user> search=N export=N rows=N | default", Help: "Atur kuota khusus satu user (`unlimited` = tanpa batas)",
+		Handler: func(ctx *CommandContext) {
+			handleSetQuota(ctx)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/keys", Perm: PermKeys,
+		Category: catAccess, Usage: "/keys [aktif|habis|kedaluwarsa|dicabut]", Help: "Daftar key & siapa yang redeem",
+		Handler: func(ctx *CommandContext) {
+			handleListKeys(ctx)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/revokekey", Perm: PermKeys,
+		Category: catAccess, Usage: "/revokekey <key>", Help: "Cabut satu key",
+		Handler: func(ctx *CommandContext) {
+			handleRevokeKey(ctx)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/deauth", Perm: PermKeys,
+		Category: catAccess, Usage: "/deauth <user>", Help: "Keluarkan satu user dari whitelist",
+		Handler: func(ctx *CommandContext) {
+			handleDeauth(ctx)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/delkey", Perm: PermKeys,
+		Category: catAccess, Help: "Hapus semua key & whitelist",
+		Handler: func(ctx *CommandContext) {
+			handleAccessControl(ctx.Bot, ctx.ChatID, ctx.Store, "/delkey")
+		},
+	})
+	r.Handle(&Command{
+		Name: "/getusers", Perm: PermAudit,
+		Category: catAccess, Help: "Download data user (CSV)",
+		Handler: func(ctx *CommandContext) {
+			handleGetUsers(ctx.Bot, ctx.ChatID, ctx.Store)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/audit", Perm: PermAudit,
+		Category: catAccess, Usage: "/audit <user>", Help: "Cek log aktivitas user",
+		Handler: func(ctx *CommandContext) {
+			handleAuditLog(ctx.Bot, ctx.ChatID, ctx.Store, ctx.Args)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/ban", Perm: PermModerate,
+		Category: catAccess, Usage: "/ban <user> [alasan]", Help: "Ban user",
+		Handler: func(ctx *CommandContext) {
+			handleBanSystem(ctx.Bot, ctx.Msg, ctx.Store, "/ban", ctx.Args)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/unban", Perm: PermModerate,
+		Category: catAccess, Usage: "/unban <user>", Help: "Unban user",
+		Handler: func(ctx *CommandContext) {
+			handleBanSystem(ctx.Bot, ctx.Msg, ctx.Store, "/unban", ctx.Args)
+		},
+	})
+
+	r.Handle(&Command{
+		Name: "/grant", Perm: PermRoles,
+		Category: catAccess, Usage: "/grant <id> <role>", Help: "Beri role (admin/moderator/analyst/user)",
+		Handler: func(ctx *CommandContext) {
+			handleGrant(ctx, ownerID)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/revoke", Perm: PermRoles,
+		Category: catAccess, Usage: "/revoke <id>", Help: "Cabut role (kembali jadi user)",
+		Handler: func(ctx *CommandContext) {
+			handleRevoke(ctx, ownerID)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/roles", Perm: PermRoles,
+		Category: catAccess, Help: "Daftar user yang punya role",
+		Handler: func(ctx *CommandContext) {
+			handleListRoles(ctx)
+		},
+	})
+
+	// --- COMMUNICATION (ADMIN) ---
+	r.Handle(&Command{
+		Name: "/broadcast", Perm: PermBroadcast,
+		Category: catComm, Usage: "/broadcast <msg>", Help: "Kirim ke Verified Users",
+		Handler: func(ctx *CommandContext) {
+			handleBroadcast(ctx.Bot, ctx.ChatID, ctx.Store, ctx.Args)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/notif", Perm: PermBroadcast,
+		Category: catComm, Usage: "/notif <msg>", Help: "Kirim ke Semua Users",
+		Handler: func(ctx *CommandContext) {
+			handleNotification(ctx.Bot, ctx.ChatID, ctx.Store, ctx.Args)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/sendto", Perm: PermBroadcast,
+		Category: catComm, Usage: "/sendto <id> <msg>", Help: "Kirim pesan personal",
+		Handler: func(ctx *CommandContext) {
+			handleDirectMessage(ctx.Bot, ctx.ChatID, ctx.Args)
+		},
+	})
+
+	// --- DATA MANAGEMENT (ADMIN) ---
+	r.Handle(&Command{
+		Name: "/cleansource", Perm: PermDeleteData,
+		Category: catDataMgr, Usage: "/cleansource <file>", Help: "Hapus data dari satu source",
+		Handler: func(ctx *CommandContext) {
+			handleAccessControl(ctx.Bot, ctx.ChatID, ctx.Store, "/cleansource "+ctx.Args)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/jobs", Perm: PermIngest,
+		Category: catDataMgr, Help: "Daftar job ingest (antri/jalan/selesai)",
+		Handler: func(ctx *CommandContext) {
+			handleJobs(ctx.Bot, ctx.ChatID, jobs)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/cancel", Perm: PermIngest,
+		Category: catDataMgr, Usage: "/cancel <job_id>", Help: "Batalkan job ingest",
+		Handler: func(ctx *CommandContext) {
+			handleCancelJob(ctx.Bot, ctx.ChatID, jobs, ctx.Args)
+		},
+	})
+	r.Handle(&Command{
+		Name: "/resume", Perm: PermIngest,
+		Category: catDataMgr, Usage: "/resume <job_id>", Help: "Lanjutkan job ingest yang gagal dari checkpoint",
+		Handler: func(ctx *CommandContext) {
+			handleResumeJob(ctx.Bot, ctx.ChatID, jobs, ctx.Args)
+		},
+	})
+	r.Handle(&Command{
+		Perm:     PermIngest,
+		Match:    func(msg *tgbotapi.Message) bool { return strings.HasPrefix(msg.Text, "http") },
+		Category: catDataMgr, Help: "*Upload URL:* Kirim Link Direct Download",
+		Handler: func(ctx *CommandContext) {
+			ctx.Store.LogActivity(ctx.User, "UPLOAD_URL", ctx.Msg.Text)
+			handleURLUpload(ctx.Bot, ctx.Msg, jobs)
+		},
+	})
+	r.Handle(&Command{
+		Perm:     PermIngest,
+		Match:    func(msg *tgbotapi.Message) bool { return msg.Document != nil },
+		Category: catDataMgr, Help: "*Upload File:* Kirim file CSV/TXT/JSON (boleh .zip/.gz/.tar.gz)",
+		Handler: func(ctx *CommandContext) {
+			ctx.Store.LogActivity(ctx.User, "UPLOAD_FILE", ctx.Msg.Document.FileName)
+			handleFileUpload(ctx.Bot, ctx.Msg, jobs)
+		},
+	})
+
+	return r
+}
+
+// --- MIDDLEWARE ---
+
+// Tentukan role user sekali per update, dipakai middleware & handler berikutnya
+func roleMiddleware(ownerID int64) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *CommandContext) {
+			ctx.Role = resolveRole(ctx.Store, ownerID, ctx.User.ID)
+			next(ctx)
+		}
+	}
+}
+
+// Blokir user yang ada di blacklist (staff tidak pernah dicek)
+func banMiddleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *CommandContext) {
+			if !ctx.Role.Can(PermStaff) && ctx.Store.IsUserBanned(strconv.FormatInt(ctx.User.ID, 10)) {
+				ctx.Reply("🚫 **AKSES DIBLOKIR**\nAkun Anda masuk dalam daftar hitam (Blacklist).")
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+// Command tanpa izin diabaikan diam-diam (tidak membocorkan daftar command)
+func permissionMiddleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *CommandContext) {
+			if ctx.Command.Perm != "" && !ctx.Role.Can(ctx.Command.Perm) {
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+func accessMiddleware(globalConfig *LiveConfig) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *CommandContext) {
+			if !ctx.Command.RequiresAccess || ctx.Role.Can(PermAccess) || globalConfig.Get().Mode == "OPEN" {
+				next(ctx)
+				return
+			}
+			user, ok := ctx.Store.GetAuthorizedUser(strconv.FormatInt(ctx.User.ID, 10))
+			if !ok {
+				ctx.Reply("🔒 **AKSES DITOLAK**\nBot dalam mode PRIVAT. Silakan `/redeem` kode akses.")
+				return
+			}
+			if !user.Active(time.Now()) {
+				ctx.Reply(fmt.Sprintf("⌛ **AKSES BERAKHIR**\nLangganan Anda habis pada %s. Silakan `/redeem` key baru untuk memperpanjang.", formatExpiry(user.ExpiresAt)))
+				return
+			}
+			next(ctx)
+		}
+	}
+}
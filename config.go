@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sync"
+)
+
+// --- SYSTEM CONFIG (RUNTIME) ---
+// Update Telegram diproses paralel (lihat dispatcher.go), jadi config yang bisa diubah lewat
+// command (/open, /close, /setlimit, /exportpolicy) dibungkus LiveConfig. Pembaca mendapat
+// salinan, perubahan lewat Update sehingga tidak ada yang membaca config setengah diubah.
+
+type LiveConfig struct {
+	mu     sync.RWMutex
+	config SystemConfig
+}
+
+func NewLiveConfig(config SystemConfig) *LiveConfig {
+	return &LiveConfig{config: config}
+}
+
+// Get mengembalikan salinan config saat ini. Map di dalamnya tidak pernah diubah
+// setelah dipublikasikan (Update selalu membuat map baru), jadi aman dibaca.
+func (c *LiveConfig) Get() SystemConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config
+}
+
+// Update menerapkan fn ke salinan config lalu menyimpannya ke RAM & Store.
+// Disimpan di dalam lock agar urutan di database sama dengan urutan di RAM.
+func (c *LiveConfig) Update(store Store, fn func(config *SystemConfig)) SystemConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	next := c.config
+	if c.config.ExportPolicy != nil {
+		next.ExportPolicy = make(map[Role]SensitivePolicy, len(c.config.ExportPolicy))
+		for role, p := range c.config.ExportPolicy {
+			next.ExportPolicy[role] = p
+		}
+	}
+	fn(&next)
+
+	c.config = next
+	store.SaveSystemConfig(next)
+	return next
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- UPDATE DISPATCHER ---
+// Update diproses oleh sejumlah worker (BOT_WORKERS) agar satu pencarian/export yang lambat
+// tidak memblokir user lain. Update dari chat yang sama tetap diproses berurutan:
+// setiap chat punya antrean sendiri dan paling banyak satu worker yang memegangnya.
+
+const (
+	defaultBotWorkers = 8
+	maxPendingPerChat = 50 // Sisa update chat yang spam dibuang
+)
+
+type UpdateDispatcher struct {
+	mu      sync.Mutex
+	pending map[int64][]tgbotapi.Update // Antrean per chat
+	ready   chan int64                  // Chat yang punya antrean & belum dipegang worker
+	handle  func(update tgbotapi.Update)
+	wg      sync.WaitGroup
+}
+
+func botWorkersFromEnv() int {
+	if v, err := strconv.Atoi(os.Getenv("BOT_WORKERS")); err == nil && v > 0 {
+		return v
+	}
+	return defaultBotWorkers
+}
+
+func NewUpdateDispatcher(workers int, handle func(update tgbotapi.Update)) *UpdateDispatcher {
+	d := &UpdateDispatcher{
+		pending: make(map[int64][]tgbotapi.Update),
+		ready:   make(chan int64, workers),
+		handle:  handle,
+	}
+	for i := 0; i < workers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+// Submit memasukkan update ke antrean chat-nya. Memblokir jika semua worker sibuk
+// dan antrean chat siap sudah penuh (backpressure ke loop polling).
+func (d *UpdateDispatcher) Submit(update tgbotapi.Update) {
+	chatID := updateChatID(update)
+
+	d.mu.Lock()
+	queue, busy := d.pending[chatID]
+	if len(queue) >= maxPendingPerChat {
+		d.mu.Unlock()
+		log.Printf("⚠️ Antrean chat %d penuh, update %d dibuang", chatID, update.UpdateID)
+		return
+	}
+	d.pending[chatID] = append(queue, update)
+	d.wg.Add(1)
+	d.mu.Unlock()
+
+	// Chat yang sudah ada di map sedang dipegang worker / menunggu di ready
+	if !busy {
+		d.ready <- chatID
+	}
+}
+
+// Wait menunggu semua update yang sudah di-Submit selesai diproses
+func (d *UpdateDispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// worker memproses seluruh antrean satu chat sampai kosong, baru mengambil chat lain
+func (d *UpdateDispatcher) worker() {
+	for chatID := range d.ready {
+		for {
+			d.mu.Lock()
+			queue := d.pending[chatID]
+			if len(queue) == 0 {
+				delete(d.pending, chatID)
+				d.mu.Unlock()
+				break
+			}
+			update := queue[0]
+			d.pending[chatID] = queue[1:]
+			d.mu.Unlock()
+
+			d.run(update)
+		}
+	}
+}
+
+// run: panic di satu handler tidak boleh mematikan worker
+func (d *UpdateDispatcher) run(update tgbotapi.Update) {
+	defer d.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			buf := make([]byte, 4096)
+			log.Printf("🔥 Panic saat memproses update %d: %v\n%s", update.UpdateID, r, buf[:runtime.Stack(buf, false)])
+		}
+	}()
+	d.handle(update)
+}
+
+// updateChatID: kunci urutan. Update tanpa chat (jarang) digabung di antrean 0.
+func updateChatID(update tgbotapi.Update) int64 {
+	if chat := update.FromChat(); chat != nil {
+		return chat.ID
+	}
+	return 0
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func chatUpdate(id int, chatID int64) tgbotapi.Update {
+	return tgbotapi.Update{UpdateID: id, Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}, From: &tgbotapi.User{ID: chatID}}}
+}
+
+// TestUpdateDispatcherOrdering tests that updates of one chat are handled in order.
+func TestUpdateDispatcherOrdering(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[int64][]int)
+	d := NewUpdateDispatcher(4, func(u tgbotapi.Update) {
+		time.Sleep(time.Duration(u.UpdateID%3) * time.Millisecond)
+		mu.Lock()
+		seen[u.Message.Chat.ID] = append(seen[u.Message.Chat.ID], u.UpdateID)
+		mu.Unlock()
+	})
+
+	for i := 0; i < 200; i++ {
+		d.Submit(chatUpdate(i, int64(i%5)))
+	}
+	d.Wait()
+
+	for chatID, ids := range seen {
+		if len(ids) != 40 {
+			t.Errorf("chat %d handled %d updates, want 40", chatID, len(ids))
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] < ids[i-1] {
+				t.Fatalf("chat %d out of order: %v", chatID, ids)
+			}
+		}
+	}
+}
+
+// TestUpdateDispatcherConcurrency tests that a slow chat does not block other chats
+// and that a panicking handler does not kill the worker.
+func TestUpdateDispatcherConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan int64, 10)
+	d := NewUpdateDispatcher(2, func(u tgbotapi.Update) {
+		switch u.Message.Chat.ID {
+		case 1:
+			<-release // Export lambat
+		case 2:
+			panic("boom")
+		}
+		done <- u.Message.Chat.ID
+	})
+
+	d.Submit(chatUpdate(1, 1))
+	d.Submit(chatUpdate(2, 2))
+	d.Submit(chatUpdate(3, 3))
+	select {
+	case id := <-done:
+		if id != 3 {
+			t.Errorf("first finished chat = %d, want 3", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("chat 3 blocked by slow chat 1")
+	}
+	close(release)
+	d.Wait()
+}
+
+// TestLiveConfigUpdate tests that snapshots are not mutated by later updates.
+func TestLiveConfigUpdate(t *testing.T) {
+	store := NewMemoryStore()
+	live := NewLiveConfig(SystemConfig{Mode: "OPEN", RateLimit: 10, ExportPolicy: map[Role]SensitivePolicy{roleUser: SensitiveMask}})
+	before := live.Get()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			live.Update(store, func(c *SystemConfig) {
+				c.RateLimit = i + 1
+				c.ExportPolicy[roleUser] = SensitiveHash
+			})
+		}(i)
+		go func() {
+			defer wg.Done()
+			cfg := live.Get()
+			_ = exportPolicyFor(&cfg, roleUser)
+		}()
+	}
+	wg.Wait()
+
+	if before.ExportPolicy[roleUser] != SensitiveMask || before.RateLimit != 10 {
+		t.Errorf("snapshot was mutated: %+v", before)
+	}
+	if got := store.GetSystemConfig(); got.RateLimit != live.Get().RateLimit || got.ExportPolicy[roleUser] != SensitiveHash {
+		t.Errorf("store config %+v differs from live %+v", got, live.Get())
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- DOMAIN REPORT ---
+// /domain acme.com meringkas semua record yang email (atau field domain) berada di domain tersebut:
+// jumlah akun, source, porsi password plaintext vs hash, upload terakhir dan password yang dipakai ulang.
+// Angka dihitung Store lewat aggregation (lihat ElasticStore.DomainReport), laporan lengkap
+// (semua record) diunduh lewat tombol yang memakai pipeline /export format HTML.
+// Akun terekspos hanya menghitung field kanonik email (field_aliases.go): record yang di-index sebelum
+// normalisasi alias ikut dihitung di Records, tapi baru masuk Accounts setelah di-ingest ulang.
+
+const (
+	domainCallback        = "dom" // Callback data: "dom:<domain>"
+	domainReportSources   = 50    // Bucket terms leak_source
+	domainReportPasswords = 10    // Password dipakai ulang yang ditampilkan
+	domainPreviewSources  = 10    // Source yang ditampilkan di pesan
+
+	// Record dengan password tapi tanpa kolom hash dianggap plaintext
+	domainPlaintextQuery = "password:* NOT hash:*"
+	domainHashedQuery    = "hash:*"
+)
+
+type DomainCount struct {
+	Name    string
+	Records int64
+}
+
+type DomainReport struct {
+	Domain       string
+	Records      int64
+	Accounts     int64 // Email kanonik unik, tanpa membedakan huruf besar/kecil
+	Sources      []DomainCount
+	Plaintext    int64
+	Hashed       int64
+	LastUpload   time.Time     // Zero jika record belum punya upload_date
+	TopPasswords []DomainCount // Hanya password yang muncul >= 2 kali, nilai asli (mask saat ditampilkan)
+}
+
+// domainQuery: query /s untuk semua record satu domain, dipakai juga oleh tombol laporan lengkap
+func domainQuery(domain string) string {
+	return fmt.Sprintf("email:%s OR domain:%s", quoteQueryValue(domain), quoteQueryValue(domain))
+}
+
+// normalizeDomain menerima "acme.com", "@acme.com", "https://acme.com/login", return "" jika tidak valid
+func normalizeDomain(input string) string {
+	d := strings.ToLower(strings.TrimSpace(input))
+	d = strings.TrimPrefix(d, "https://")
+	d = strings.TrimPrefix(d, "http://")
+	if i := strings.LastIndex(d, "@"); i >= 0 {
+		d = d[i+1:]
+	}
+	d, _, _ = strings.Cut(d, "/")
+	d = strings.TrimSuffix(d, ".")
+
+	if len(d) > 253 || !strings.Contains(d, ".") {
+		return ""
+	}
+	for _, label := range strings.Split(d, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return ""
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return ""
+			}
+		}
+	}
+	return d
+}
+
+// maskReusedPassword hanya menyisakan huruf pertama & terakhir, panjang asli tidak terlihat
+func maskReusedPassword(pw string) string {
+	runes := []rune(pw)
+	if len(runes) <= 3 {
+		return "•••"
+	}
+	return string(runes[0]) + "•••••" + string(runes[len(runes)-1])
+}
+
+func percentOf(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+func formatDomainReport(r DomainReport) string {
+	if r.Records == 0 {
+		return fmt.Sprintf("✅ *AMAN!*\nTidak ada data untuk domain `%s`", escapeMarkdown(r.Domain))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🏢 *DOMAIN REPORT*: `%s`\n\n", escapeMarkdown(r.Domain)))
+	sb.WriteString(fmt.Sprintf("👤 Akun terekspos: %d\n", r.Accounts))
+	sb.WriteString(fmt.Sprintf("📄 Total record: %d\n", r.Records))
+	sb.WriteString(fmt.Sprintf("🔓 Password plaintext: %d (%.1f%%)\n", r.Plaintext, percentOf(r.Plaintext, r.Records)))
+	sb.WriteString(fmt.Sprintf("🔐 Password hash: %d (%.1f%%)\n", r.Hashed, percentOf(r.Hashed, r.Records)))
+	lastUpload := "-"
+	if !r.LastUpload.IsZero() {
+		lastUpload = r.LastUpload.Local().Format("2006-01-02 15:04")
+	}
+	sb.WriteString(fmt.Sprintf("🕒 Upload terakhir: %s\n", lastUpload))
+
+	sb.WriteString(fmt.Sprintf("\n📂 *Source* (%d):\n", len(r.Sources)))
+	for i, src := range r.Sources {
+		if i >= domainPreviewSources {
+			sb.WriteString(fmt.Sprintf("_...dan %d source lainnya._\n", len(r.Sources)-domainPreviewSources))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("• %s: %d record\n", escapeMarkdown(src.Name), src.Records))
+	}
+
+	sb.WriteString("\n🔁 *Password dipakai ulang*:\n")
+	if len(r.TopPasswords) == 0 {
+		sb.WriteString("_Tidak ada._\n")
+	}
+	for _, pw := range r.TopPasswords {
+		sb.WriteString(fmt.Sprintf("• %s ×%d\n", escapeMarkdown(maskReusedPassword(pw.Name)), pw.Records))
+	}
+	return sb.String()
+}
+
+// --- HANDLERS ---
+
+// /domain <domain>
+func handleDomain(ctx *CommandContext) {
+	if ctx.Args == "" {
+		ctx.ReplyUsage()
+		return
+	}
+	domain := normalizeDomain(ctx.Args)
+	if domain == "" {
+		ctx.Reply("⚠️ Domain tidak valid. Contoh: `/domain acme.com`")
+		return
+	}
+	ctx.Store.LogActivity(ctx.User, "DOMAIN", domain)
+
+	report, err := ctx.Store.DomainReport(domain)
+	if err != nil {
+		ctx.Reply("❌ Error Database.")
+		return
+	}
+
+	msg := tgbotapi.NewMessage(ctx.ChatID, formatDomainReport(report))
+	msg.ParseMode = "Markdown"
+	// Batas callback data Telegram 64 byte, domain sangat panjang tetap bisa lewat /export
+	if data := domainCallback + ":" + domain; report.Records > 0 && len(data) <= 64 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📥 Laporan Lengkap", data)))
+	}
+	ctx.Bot.Send(msg)
+}
+
+// handleDomainCallback: tombol laporan lengkap = /export html untuk query domain
+func handleDomainCallback(ctx *CommandContext, config SystemConfig) {
+	domain := normalizeDomain(ctx.Args)
+	if domain == "" {
+		return
+	}
+	query := domainQuery(domain)
+	ctx.Store.LogActivity(ctx.User, "EXPORT", query)
+	ctx.Answer("📄 Menyiapkan laporan...")
+	handleExport(ctx.Bot, ctx.Msg, ctx.Store, ExportRequest{Format: "html", Keyword: query}.withPolicy(ctx, &config))
+}
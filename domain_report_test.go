@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDomainReport tests the aggregated numbers of the in-memory report and its masked rendering.
+func TestDomainReport(t *testing.T) {
+	store := NewMemoryStore()
+	docs := []map[string]interface{}{
+		{"email": "ceo@acme.com", "password": "Summer2024!", "leak_source": "a.csv", "upload_date": "2026-01-10T08:00:00Z"},
+		{"email": "dev@acme.com", "password": "Summer2024!", "leak_source": "a.csv", "upload_date": "2026-01-10T08:00:00Z"},
+		{"email": "CEO@Acme.com", "password": "5f4dcc3b5aa765d61d8327deb882cf99", "password_hash": "5f4dcc3b5aa765d61d8327deb882cf99", "leak_source": "b.txt", "upload_date": "2026-03-02T09:30:00Z"},
+		{"email": "ops@acme.com", "password": "unique-one", "leak_source": "b.txt"},
+		{"email": "ceo@other.org", "password": "Summer2024!", "leak_source": "a.csv", "upload_date": "2026-09-01T00:00:00Z"},
+	}
+	for i, d := range docs {
+		store.IndexDocument(d, string(rune('a'+i)))
+	}
+
+	r, err := store.DomainReport("acme.com")
+	if err != nil {
+		t.Fatalf("DomainReport: %v", err)
+	}
+	if r.Records != 4 || r.Accounts != 3 || r.Plaintext != 3 || r.Hashed != 1 {
+		t.Errorf("records/accounts/plaintext/hashed = %d/%d/%d/%d, want 4/3/3/1", r.Records, r.Accounts, r.Plaintext, r.Hashed)
+	}
+	if len(r.Sources) != 2 || r.Sources[0] != (DomainCount{"a.csv", 2}) || r.Sources[1] != (DomainCount{"b.txt", 2}) {
+		t.Errorf("Sources = %v", r.Sources)
+	}
+	if want := time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC); !r.LastUpload.Equal(want) {
+		t.Errorf("LastUpload = %v, want %v", r.LastUpload, want)
+	}
+	if len(r.TopPasswords) != 1 || r.TopPasswords[0] != (DomainCount{"Summer2024!", 2}) {
+		t.Errorf("TopPasswords = %v, want only the reused plaintext password", r.TopPasswords)
+	}
+
+	text := formatDomainReport(r)
+	if strings.Contains(text, "Summer2024") || !strings.Contains(text, "S•••••! ×2") {
+		t.Errorf("report text is not masked:\n%s", text)
+	}
+	if empty, _ := store.DomainReport("nothing.io"); !strings.Contains(formatDomainReport(empty), "AMAN") {
+		t.Errorf("empty domain report = %+v", empty)
+	}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	cases := map[string]string{
+		"acme.com":               "acme.com",
+		" @ACME.com ":            "acme.com",
+		"ceo@mail.acme.co.id":    "mail.acme.co.id",
+		"https://acme.com/login": "acme.com",
+		"localhost":              "",
+		"acme..com":              "",
+		"-acme.com":              "",
+		"acme.com\" OR x":        "",
+	}
+	for in, want := range cases {
+		if got := normalizeDomain(in); got != want {
+			t.Errorf("normalizeDomain(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"log"
+	"net/http"
 	"time"
 
 	"strconv"
+	"strings"
 
 	"github.com/elastic/go-elasticsearch/v9"
 	"github.com/elastic/go-elasticsearch/v9/esapi"
@@ -37,43 +39,31 @@ type BlacklistEntry struct {
 	BannedBy string    `json:"banned_by"`
 }
 
-// --- QUERY PENCARIAN DATA ---
-func buildSearchQuery(keyword string, exactMatch bool) string {
-	if strings.Contains(keyword, ":") {
-		parts := strings.SplitN(keyword, ":", 2)
-		rawKey := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-
-		patternLower := fmt.Sprintf("*%s*", strings.ToLower(rawKey))
-		patternUpper := fmt.Sprintf("*%s*", strings.ToUpper(rawKey))
-
-		fuzziness := "AUTO"
-		if exactMatch {
-			fuzziness = "0"
-		}
+// ElasticStore adalah implementasi Store di atas cluster Elasticsearch
+type ElasticStore struct {
+	es *elasticsearch.Client
+}
 
-		return fmt.Sprintf(`{
-			"query": {
-				"multi_match": {
-					"query": "%s",
-					"fields": ["%s", "%s"], 
-					"operator": "and", 
-					"fuzziness": "%s" 
-				}
-			}
-		}`, value, patternLower, patternUpper, fuzziness)
-	}
+func NewElasticStore(es *elasticsearch.Client) *ElasticStore {
+	return &ElasticStore{es: es}
+}
 
-	return fmt.Sprintf(`{
-		"query": { "match": { "full_text": { "query": "%s", "operator": "and", "fuzziness": "AUTO" } } }
-	}`, keyword)
+// --- QUERY PENCARIAN DATA ---
+// buildSearchQuery mem-parse keyword dengan query language (lihat query_lang.go)
+// lalu mengubahnya menjadi bool query. Error selalu *QuerySyntaxError.
+func buildSearchQuery(keyword string, exactMatch bool) (SearchRequest, error) {
+	node, err := parseSearchQuery(keyword)
+	if err != nil {
+		return SearchRequest{}, err
+	}
+	return SearchRequest{Query: node.toQuery(exactMatch)}, nil
 }
 
-func executeSearch(es *elasticsearch.Client, index string, queryBody string, size int) (*ESResponse, error) {
+func executeSearch(es *elasticsearch.Client, index string, req SearchRequest, size int) (*ESResponse, error) {
 	res, err := es.Search(
 		es.Search.WithContext(context.Background()),
 		es.Search.WithIndex(index),
-		es.Search.WithBody(strings.NewReader(queryBody)),
+		es.Search.WithBody(req.Reader()),
 		es.Search.WithTrackTotalHits(true),
 		es.Search.WithSize(size),
 	)
@@ -89,8 +79,116 @@ func executeSearch(es *elasticsearch.Client, index string, queryBody string, siz
 	return &result, nil
 }
 
+func (s *ElasticStore) SearchBreaches(keyword string, size int) (*ESResponse, error) {
+	req, err := buildSearchQuery(keyword, true)
+	if err != nil {
+		return nil, err
+	}
+	return executeSearch(s.es, "breach_data", req, size)
+}
+
+// pitKeepAlive harus lebih lama dari umur sesi pencarian (searchSessionTTL di search_pager.go)
+const pitKeepAlive = "15m"
+
+func (s *ElasticStore) SearchBreachesAfter(keyword string, size int, cursor SearchCursor) (*ESResponse, SearchCursor, error) {
+	req, err := buildSearchQuery(keyword, true)
+	if err != nil {
+		return nil, cursor, err
+	}
+
+	// PIT menjaga urutan hasil tetap sama selama user berpindah halaman walau ada ingest baru
+	if cursor.PIT == "" {
+		if cursor.PIT, err = s.openPIT("breach_data"); err != nil {
+			return nil, cursor, err
+		}
+	}
+	req.PIT = &PointInTime{ID: cursor.PIT, KeepAlive: pitKeepAlive}
+	req.Sort = []SortField{SortBy("_score", "desc"), SortBy("_shard_doc", "asc")}
+	req.SearchAfter = cursor.After
+	req.Size = intPtr(size)
+	req.TrackTotalHits = true
+
+	// Request dengan PIT tidak boleh menyebut index
+	res, err := s.es.Search(
+		s.es.Search.WithContext(context.Background()),
+		s.es.Search.WithBody(req.Reader()),
+	)
+	if err != nil {
+		return nil, cursor, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, cursor, fmt.Errorf("search gagal: %s", res.Status())
+	}
+
+	var result ESResponse
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, cursor, err
+	}
+
+	next := SearchCursor{PIT: cursor.PIT, After: cursor.After}
+	if result.PitID != "" {
+		next.PIT = result.PitID
+	}
+	if n := len(result.Hits.Hits); n > 0 {
+		next.After = result.Hits.Hits[n-1].Sort
+	}
+	return &result, next, nil
+}
+
+// RefreshBreaches: bulk ingest memakai refresh=false, watchlist perlu melihat data baru seketika
+func (s *ElasticStore) RefreshBreaches() {
+	res, err := s.es.Indices.Refresh(
+		s.es.Indices.Refresh.WithContext(context.Background()),
+		s.es.Indices.Refresh.WithIndex("breach_data"),
+	)
+	if err == nil {
+		res.Body.Close()
+	}
+}
+
+func (s *ElasticStore) openPIT(index string) (string, error) {
+	res, err := s.es.OpenPointInTime([]string{index}, pitKeepAlive)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", fmt.Errorf("open PIT gagal: %s", res.Status())
+	}
+
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.ID, nil
+}
+
+func (s *ElasticStore) CloseSearchCursor(cursor SearchCursor) {
+	if cursor.PIT == "" {
+		return
+	}
+	body, _ := json.Marshal(PointInTime{ID: cursor.PIT})
+	res, err := s.es.ClosePointInTime(bytes.NewReader(body))
+	if err != nil {
+		log.Printf("⚠️ Gagal menutup PIT: %v", err)
+		return
+	}
+	res.Body.Close()
+}
+
+func (s *ElasticStore) SearchActivity(keyword string, size int) (*ESResponse, error) {
+	req := SearchRequest{
+		Query: MultiMatch(keyword, []string{"username", "first_name", "last_name", "query_content"}, "", "AUTO"),
+		Sort:  []SortField{SortBy("timestamp", "desc")},
+	}
+	return executeSearch(s.es, "user_logs", req, size)
+}
+
 // --- LOGGING ---
-func logActivity(es *elasticsearch.Client, user *tgbotapi.User, action string, content string) {
+func (s *ElasticStore) LogActivity(user *tgbotapi.User, action string, content string) {
 	idString := strconv.FormatInt(user.ID, 10)
 	logEntry := UserActivity{
 		Timestamp:  time.Now(),
@@ -107,11 +205,11 @@ func logActivity(es *elasticsearch.Client, user *tgbotapi.User, action string, c
 		Body:    bytes.NewReader(body),
 		Refresh: "false",
 	}
-	go req.Do(context.Background(), es)
+	go req.Do(context.Background(), s.es)
 }
 
 // --- INGESTION (Insert Data) ---
-func indexDocument(es *elasticsearch.Client, doc map[string]interface{}, id string) {
+func (s *ElasticStore) IndexDocument(doc map[string]interface{}, id string) {
 	body, _ := json.Marshal(doc)
 	req := esapi.IndexRequest{
 		Index:      "breach_data",
@@ -119,7 +217,72 @@ func indexDocument(es *elasticsearch.Client, doc map[string]interface{}, id stri
 		Body:       bytes.NewReader(body),
 		Refresh:    "false",
 	}
-	req.Do(context.Background(), es)
+	req.Do(context.Background(), s.es)
+}
+
+// BulkIndex mengirim banyak dokumen sekaligus lewat _bulk API.
+// Return hanya item yang gagal; error berarti seluruh request gagal (boleh di-retry semua).
+func (s *ElasticStore) BulkIndex(docs []BulkDocument) ([]BulkItemResult, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, d := range docs {
+		meta := map[string]map[string]string{"index": {"_index": "breach_data", "_id": d.ID}}
+		if err := enc.Encode(meta); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(d.Doc); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := s.es.Bulk(
+		&buf,
+		s.es.Bulk.WithContext(context.Background()),
+		s.es.Bulk.WithRefresh("false"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("bulk request gagal: %s", res.Status())
+	}
+
+	var response struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	var failed []BulkItemResult
+	if !response.Errors {
+		return failed, nil
+	}
+	// Item response berurutan sesuai action di request
+	for i, item := range response.Items {
+		for _, r := range item {
+			if r.Status >= 200 && r.Status < 300 {
+				continue
+			}
+			failed = append(failed, BulkItemResult{
+				ID:     r.ID,
+				Index:  i,
+				Status: r.Status,
+				Error:  fmt.Sprintf("%s: %s", r.Error.Type, r.Error.Reason),
+			})
+		}
+	}
+	return failed, nil
 }
 
 // --- ACCESS CONTROL MANAGEMENT ---
@@ -156,14 +319,14 @@ func indexDocument(es *elasticsearch.Client, doc map[string]interface{}, id stri
 // 	req.Do(context.Background(), es)
 // }
 
-func getSystemConfig(es *elasticsearch.Client) SystemConfig {
+func (s *ElasticStore) GetSystemConfig() SystemConfig {
 	// Default Value
 	config := SystemConfig{
 		Mode:      "OPEN",
 		RateLimit: 10,
 	}
 
-	res, err := es.Get("system_config", "current_config")
+	res, err := s.es.Get("system_config", "current_config")
 	if err != nil || res.IsError() {
 		return config // Return default jika belum ada di DB
 	}
@@ -188,12 +351,28 @@ func getSystemConfig(es *elasticsearch.Client) SystemConfig {
 	if l, ok := src["rate_limit"].(float64); ok {
 		config.RateLimit = int(l)
 	}
+	if b, ok := src["rate_burst"].(float64); ok {
+		config.RateBurst = int(b)
+	}
+
+	// Parse Export Policy
+	if policies, ok := src["export_policy"].(map[string]interface{}); ok {
+		config.ExportPolicy = make(map[Role]SensitivePolicy)
+		for role, v := range policies {
+			if p, ok := parseSensitivePolicy(fmt.Sprint(v)); ok {
+				config.ExportPolicy[Role(role)] = p
+			}
+		}
+	}
+	if enc, ok := src["encrypt_exports"].(bool); ok {
+		config.EncryptExports = enc
+	}
 
 	return config
 }
 
 // Simpan Config (Mode & Limit)
-func saveSystemConfig(es *elasticsearch.Client, config SystemConfig) {
+func (s *ElasticStore) SaveSystemConfig(config SystemConfig) {
 	body, _ := json.Marshal(config)
 	req := esapi.IndexRequest{
 		Index:      "system_config",
@@ -201,71 +380,215 @@ func saveSystemConfig(es *elasticsearch.Client, config SystemConfig) {
 		Body:       bytes.NewReader(body),
 		Refresh:    "true",
 	}
-	req.Do(context.Background(), es)
+	req.Do(context.Background(), s.es)
 }
 
-// 3. Simpan Key Baru
-func saveAccessKey(es *elasticsearch.Client, key string) {
-	doc := AccessKey{Key: key, CreatedAt: time.Now(), Active: true}
-	body, _ := json.Marshal(doc)
+// 3. Simpan Key (baru atau update jumlah pemakaian)
+func (s *ElasticStore) SaveAccessKey(key AccessKey) {
+	body, _ := json.Marshal(key)
 	// Gunakan Key sebagai DocumentID agar pencarian cepat & mencegah duplikat
 	req := esapi.IndexRequest{
 		Index:      "access_keys",
-		DocumentID: key,
+		DocumentID: key.Key,
 		Body:       bytes.NewReader(body),
 		Refresh:    "true",
 	}
-	req.Do(context.Background(), es)
+	req.Do(context.Background(), s.es)
 }
 
-// 4. Validasi & Pakai Key (Atomic Logic handled in handler usually, but here helper)
-func getKeyStatus(es *elasticsearch.Client, key string) bool {
-	res, err := es.Get("access_keys", key)
-	if err != nil || res.IsError() {
-		return false
+// 4. Ambil Key (validasi masa berlaku & pemakaian ada di handler, lihat AccessKey.Redeemable)
+func (s *ElasticStore) GetAccessKey(key string) (AccessKey, bool) {
+	var doc struct {
+		Source AccessKey `json:"_source"`
 	}
-	return true // Jika key ditemukan (nanti dihapus setelah dipakai)
+	if !s.getDocument("access_keys", key, &doc) {
+		return AccessKey{}, false
+	}
+	return doc.Source, true
 }
 
-// 5. Whitelist User
-func authorizeUser(es *elasticsearch.Client, userID string, key string) {
-	doc := AuthorizedUser{UserID: userID, RedeemedAt: time.Now(), UsedKey: key}
-	body, _ := json.Marshal(doc)
+// Batas baca ulang saat redeem / update kuota bentrok (409) dengan update lain
+const redeemMaxAttempts = 10
+
+// 4b. Redeem Key secara atomik dengan optimistic concurrency (if_seq_no/if_primary_term):
+// jika dokumen berubah di antara GET & INDEX, ES menolak dengan 409 lalu kita baca ulang.
+// Dua user yang redeem key sekali pakai bersamaan -> hanya satu yang tersimpan.
+func (s *ElasticStore) RedeemAccessKey(code, userID string, now time.Time) (AccessKey, error) {
+	for attempt := 0; attempt < redeemMaxAttempts; attempt++ {
+		var doc struct {
+			SeqNo       int       `json:"_seq_no"`
+			PrimaryTerm int       `json:"_primary_term"`
+			Source      AccessKey `json:"_source"`
+		}
+		found, err := s.fetchDocument("access_keys", code, &doc)
+		if err != nil {
+			log.Printf("⚠️ Gagal membaca key %s: %v", code, err)
+			return AccessKey{}, errKeyUnavailable
+		}
+		if !found {
+			return AccessKey{}, errKeyNotFound
+		}
+		key := doc.Source
+		if err := key.redeem(userID, now); err != nil {
+			return key, err
+		}
+
+		body, _ := json.Marshal(key)
+		req := esapi.IndexRequest{
+			Index:         "access_keys",
+			DocumentID:    code,
+			Body:          bytes.NewReader(body),
+			IfSeqNo:       &doc.SeqNo,
+			IfPrimaryTerm: &doc.PrimaryTerm,
+			Refresh:       "true",
+		}
+		res, err := req.Do(context.Background(), s.es)
+		if err != nil {
+			return key, err
+		}
+		res.Body.Close()
+		if res.StatusCode == 409 {
+			continue // Kalah balapan, ulangi dengan versi terbaru
+		}
+		if res.IsError() {
+			return key, fmt.Errorf("gagal menyimpan key: %s", res.Status())
+		}
+		return key, nil
+	}
+	return AccessKey{}, errKeyConflict
+}
+
+// 5. Whitelist User (dokumen ditimpa, termasuk tanggal berakhir yang baru)
+func (s *ElasticStore) AuthorizeUser(user AuthorizedUser) {
+	body, _ := json.Marshal(user)
 	req := esapi.IndexRequest{
 		Index:      "authorized_users",
-		DocumentID: userID,
+		DocumentID: user.UserID,
 		Body:       bytes.NewReader(body),
 		Refresh:    "true",
 	}
-	req.Do(context.Background(), es)
+	req.Do(context.Background(), s.es)
 }
 
-// 6. Cek Apakah User Whitelisted?
-func isUserAuthorized(es *elasticsearch.Client, userID string) bool {
-	res, err := es.Get("authorized_users", userID)
-	if err != nil || res.IsError() {
+func (s *ElasticStore) GetAuthorizedUser(userID string) (AuthorizedUser, bool) {
+	var doc struct {
+		Source AuthorizedUser `json:"_source"`
+	}
+	if !s.getDocument("authorized_users", userID, &doc) {
+		return AuthorizedUser{}, false
+	}
+	return doc.Source, true
+}
+
+// 6. Cek Apakah User Whitelisted & langganannya belum lewat?
+func (s *ElasticStore) IsUserAuthorized(userID string) bool {
+	user, ok := s.GetAuthorizedUser(userID)
+	return ok && user.Active(time.Now())
+}
+
+// getDocument: GET satu dokumen by ID lalu decode response-nya ke out
+func (s *ElasticStore) getDocument(index, id string, out interface{}) bool {
+	found, err := s.fetchDocument(index, id, out)
+	return found && err == nil
+}
+
+// fetchDocument membedakan dokumen yang memang tidak ada (false, nil) dari kegagalan ES (error)
+func (s *ElasticStore) fetchDocument(index, id string, out interface{}) (bool, error) {
+	res, err := s.es.Get(index, id)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, fmt.Errorf("get %s/%s gagal: %s", index, id, res.Status())
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 6b. Keluarkan satu user dari whitelist (/deauth)
+func (s *ElasticStore) DeauthorizeUser(userID string) bool {
+	req := esapi.DeleteRequest{Index: "authorized_users", DocumentID: userID, Refresh: "true"}
+	res, err := req.Do(context.Background(), s.es)
+	if err != nil {
 		return false
 	}
-	return true
+	defer res.Body.Close()
+	return !res.IsError() // 404 = user memang tidak ada di whitelist
+}
+
+// 7a. Semua key untuk /keys (termasuk yang sudah habis/dicabut)
+func (s *ElasticStore) ListAccessKeys() []AccessKey {
+	var keys []AccessKey
+
+	query := SearchRequest{Query: MatchAll(), Size: intPtr(10000)}
+	res, err := s.es.Search(
+		s.es.Search.WithContext(context.Background()),
+		s.es.Search.WithIndex("access_keys"),
+		s.es.Search.WithBody(query.Reader()),
+	)
+	if err != nil {
+		return keys
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return keys
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source AccessKey `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	json.NewDecoder(res.Body).Decode(&result)
+
+	for _, hit := range result.Hits.Hits {
+		keys = append(keys, hit.Source)
+	}
+	return keys
+}
+
+// 7b. Cabut satu key. Partial update (bukan GET lalu INDEX) agar tidak menimpa redeem yang berjalan bersamaan.
+func (s *ElasticStore) RevokeAccessKey(key string) bool {
+	req := esapi.UpdateRequest{
+		Index:           "access_keys",
+		DocumentID:      key,
+		Body:            strings.NewReader(`{"doc": {"active": false}}`),
+		RetryOnConflict: intPtr(3),
+		Refresh:         "true",
+	}
+	res, err := req.Do(context.Background(), s.es)
+	if err != nil {
+		return false
+	}
+	defer res.Body.Close()
+	return !res.IsError()
 }
 
-// 7. Hapus Key (Dipakai saat redeem)
-func deleteAccessKey(es *elasticsearch.Client, key string) {
+// 7. Hapus Key
+func (s *ElasticStore) DeleteAccessKey(key string) {
 	req := esapi.DeleteRequest{Index: "access_keys", DocumentID: key, Refresh: "true"}
-	req.Do(context.Background(), es)
+	req.Do(context.Background(), s.es)
 }
 
 // 8. RESET TOTAL (/delkey)
-func resetAllAccess(es *elasticsearch.Client) {
+func (s *ElasticStore) ResetAllAccess() {
 	// Hapus index keys dan authorized users
-	esapi.IndicesDeleteRequest{Index: []string{"access_keys", "authorized_users"}}.Do(context.Background(), es)
+	esapi.IndicesDeleteRequest{Index: []string{"access_keys", "authorized_users"}}.Do(context.Background(), s.es)
 }
 
-func getClusterStats(es *elasticsearch.Client) SystemStats {
+func (s *ElasticStore) GetClusterStats() SystemStats {
 	var stats SystemStats
 
 	// 1. Hitung Total Data (Breach Data)
-	res, err := es.Count(es.Count.WithIndex("breach_data"))
+	res, err := s.es.Count(s.es.Count.WithIndex("breach_data"))
 	if err == nil && !res.IsError() {
 		var countRes map[string]interface{}
 		json.NewDecoder(res.Body).Decode(&countRes)
@@ -281,7 +604,7 @@ func getClusterStats(es *elasticsearch.Client) SystemStats {
 	}
 
 	// 2. Hitung Total User Terdaftar (Whitelist)
-	resUsers, err := es.Count(es.Count.WithIndex("authorized_users"))
+	resUsers, err := s.es.Count(s.es.Count.WithIndex("authorized_users"))
 	if err == nil && !resUsers.IsError() {
 		var userRes map[string]interface{}
 		json.NewDecoder(resUsers.Body).Decode(&userRes)
@@ -296,22 +619,18 @@ func getClusterStats(es *elasticsearch.Client) SystemStats {
 	}
 
 	// 3. Aggregation: Hitung Total Source & Top Search
-	queryBody := `{
-		"size": 0,
-		"aggs": {
-			"unique_sources": {
-				"cardinality": { "field": "leak_source.keyword" }
-			},
-			"top_keywords": {
-				"terms": { "field": "query_content.keyword", "size": 3 }
-			}
-		}
-	}`
+	query := SearchRequest{
+		Size: intPtr(0),
+		Aggs: map[string]Aggregation{
+			"unique_sources": CardinalityAgg("leak_source.keyword"),
+			"top_keywords":   TermsAgg("query_content.keyword", 3),
+		},
+	}
 
-	resAggs, err := es.Search(
-		es.Search.WithContext(context.Background()),
-		es.Search.WithIndex("breach_data", "user_logs"),
-		es.Search.WithBody(strings.NewReader(queryBody)),
+	resAggs, err := s.es.Search(
+		s.es.Search.WithContext(context.Background()),
+		s.es.Search.WithIndex("breach_data", "user_logs"),
+		s.es.Search.WithBody(query.Reader()),
 	)
 
 	if err == nil && !resAggs.IsError() { // Tambahan cek !IsError()
@@ -346,20 +665,125 @@ func getClusterStats(es *elasticsearch.Client) SystemStats {
 	return stats
 }
 
-func getAllVerifiedUserIDs(es *elasticsearch.Client) []int64 {
+// DomainReport: satu search size 0, semua angka dari aggregation di breach_data
+// Akun dihitung dari email kanonik dalam huruf kecil (CEO@acme.com = ceo@acme.com).
+// Mapping dinamis tidak punya normalizer, jadi dihitung lewat runtime field.
+const (
+	domainAccountField  = "account_email"
+	domainAccountScript = "if (doc.containsKey('email.keyword') && doc['email.keyword'].size() > 0) { emit(doc['email.keyword'].value.toLowerCase()); }"
+)
+
+func (s *ElasticStore) DomainReport(domain string) (DomainReport, error) {
+	report := DomainReport{Domain: domain}
+	req, err := buildSearchQuery(domainQuery(domain), true)
+	if err != nil {
+		return report, err
+	}
+	plaintext, _ := parseSearchQuery(domainPlaintextQuery)
+	hashed, _ := parseSearchQuery(domainHashedQuery)
+
+	req.Size = intPtr(0)
+	req.TrackTotalHits = true
+	req.RuntimeMappings = map[string]RuntimeField{domainAccountField: KeywordRuntimeField(domainAccountScript)}
+	req.Aggs = map[string]Aggregation{
+		"accounts":    CardinalityAgg(domainAccountField),
+		"sources":     TermsAgg("leak_source.keyword", domainReportSources),
+		"last_upload": MaxAgg("upload_date"),
+		"hashed":      FilterAgg(hashed.toQuery(true)),
+		"plaintext": FilterAgg(plaintext.toQuery(true)).With(map[string]Aggregation{
+			"passwords": TermsAgg("password.keyword", domainReportPasswords),
+		}),
+	}
+
+	res, err := s.es.Search(
+		s.es.Search.WithContext(context.Background()),
+		s.es.Search.WithIndex("breach_data"),
+		s.es.Search.WithBody(req.Reader()),
+	)
+	if err != nil {
+		return report, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return report, fmt.Errorf("search gagal: %s", res.Status())
+	}
+
+	type bucket struct {
+		Key      string `json:"key"`
+		DocCount int64  `json:"doc_count"`
+	}
+	var response struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations struct {
+			Accounts struct {
+				Value int64 `json:"value"`
+			} `json:"accounts"`
+			Sources struct {
+				Buckets []bucket `json:"buckets"`
+			} `json:"sources"`
+			LastUpload struct {
+				Value string `json:"value_as_string"` // Kosong jika tidak ada upload_date
+			} `json:"last_upload"`
+			Hashed struct {
+				DocCount int64 `json:"doc_count"`
+			} `json:"hashed"`
+			Plaintext struct {
+				DocCount  int64 `json:"doc_count"`
+				Passwords struct {
+					Buckets []bucket `json:"buckets"`
+				} `json:"passwords"`
+			} `json:"plaintext"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return report, err
+	}
+
+	aggs := response.Aggregations
+	report.Records = response.Hits.Total.Value
+	report.Accounts = aggs.Accounts.Value
+	report.Hashed = aggs.Hashed.DocCount
+	report.Plaintext = aggs.Plaintext.DocCount
+	for _, b := range aggs.Sources.Buckets {
+		report.Sources = append(report.Sources, DomainCount{Name: b.Key, Records: b.DocCount})
+	}
+	// Bucket terms urut dari count terbesar, berhenti di password yang hanya muncul sekali
+	for _, b := range aggs.Plaintext.Passwords.Buckets {
+		if b.DocCount < 2 {
+			break
+		}
+		report.TopPasswords = append(report.TopPasswords, DomainCount{Name: b.Key, Records: b.DocCount})
+	}
+	if t, err := time.Parse(time.RFC3339, aggs.LastUpload.Value); err == nil {
+		report.LastUpload = t
+	}
+	return report, nil
+}
+
+func (s *ElasticStore) GetAllVerifiedUserIDs() []int64 {
 	var userIDs []int64
 
-	// Query ambil semua data, hanya field 'user_id'
-	queryBody := `{
-		"_source": ["user_id"],
-		"query": { "match_all": {} },
-		"size": 10000 
-	}`
+	// Ambil user yang aksesnya permanen (tanpa expires_at) atau belum berakhir, hanya field 'user_id'
+	query := SearchRequest{
+		Source: []string{"user_id"},
+		Query: BoolQuery{
+			Should: []Query{
+				BoolQuery{MustNot: []Query{Exists("expires_at")}}.Query(),
+				Range("expires_at", map[string]interface{}{"gt": "now"}),
+			},
+			MinimumShouldMatch: 1,
+		}.Query(),
+		Size: intPtr(10000),
+	}
 
-	res, err := es.Search(
-		es.Search.WithContext(context.Background()),
-		es.Search.WithIndex("authorized_users"),
-		es.Search.WithBody(strings.NewReader(queryBody)),
+	res, err := s.es.Search(
+		s.es.Search.WithContext(context.Background()),
+		s.es.Search.WithIndex("authorized_users"),
+		s.es.Search.WithBody(query.Reader()),
 	)
 
 	if err != nil || res.IsError() {
@@ -380,24 +804,22 @@ func getAllVerifiedUserIDs(es *elasticsearch.Client) []int64 {
 	return userIDs
 }
 
-func getAllUniqueLogUserIDs(es *elasticsearch.Client) []int64 {
+func (s *ElasticStore) GetAllUniqueLogUserIDs() []int64 {
 	var userIDs []int64
 
 	// Kita gunakan Aggregation "Terms" untuk mengelompokkan user_id yang sama
 	// Size 10000 artinya kita ambil maksimal 10.000 user unik terakhir
-	queryBody := `{
-		"size": 0,
-		"aggs": {
-			"distinct_users": {
-				"terms": { "field": "user_id.keyword", "size": 10000 }
-			}
-		}
-	}`
+	query := SearchRequest{
+		Size: intPtr(0),
+		Aggs: map[string]Aggregation{
+			"distinct_users": TermsAgg("user_id.keyword", 10000),
+		},
+	}
 
-	res, err := es.Search(
-		es.Search.WithContext(context.Background()),
-		es.Search.WithIndex("user_logs"),
-		es.Search.WithBody(strings.NewReader(queryBody)),
+	res, err := s.es.Search(
+		s.es.Search.WithContext(context.Background()),
+		s.es.Search.WithIndex("user_logs"),
+		s.es.Search.WithBody(query.Reader()),
 	)
 
 	if err != nil || res.IsError() {
@@ -427,7 +849,7 @@ func getAllUniqueLogUserIDs(es *elasticsearch.Client) []int64 {
 	return userIDs
 }
 
-func generateUserReport(es *elasticsearch.Client) []UserReport {
+func (s *ElasticStore) GenerateUserReport() []UserReport {
 	// Map untuk menyimpan user unik (Key: UserID) agar tidak duplikat
 	userMap := make(map[string]UserReport)
 
@@ -438,11 +860,11 @@ func generateUserReport(es *elasticsearch.Client) []UserReport {
 	// Jadi langkah pertama: Tandai dulu siapa yang verified.
 	verifiedIDs := make(map[string]bool)
 
-	queryVerified := `{"query": { "match_all": {} }, "size": 10000}`
-	resV, _ := es.Search(
-		es.Search.WithContext(context.Background()),
-		es.Search.WithIndex("authorized_users"),
-		es.Search.WithBody(strings.NewReader(queryVerified)),
+	queryVerified := SearchRequest{Query: MatchAll(), Size: intPtr(10000)}
+	resV, _ := s.es.Search(
+		s.es.Search.WithContext(context.Background()),
+		s.es.Search.WithIndex("authorized_users"),
+		s.es.Search.WithBody(queryVerified.Reader()),
 	)
 	if resV != nil && !resV.IsError() {
 		var res map[string]interface{}
@@ -461,28 +883,19 @@ func generateUserReport(es *elasticsearch.Client) []UserReport {
 
 	// 2. Ambil Data PROFIL dari USER_LOGS
 	// Kita gunakan Aggregation "Top Hits" untuk mengambil data profil TERBARU setiap user
-	queryLogs := `{
-		"size": 0,
-		"aggs": {
-			"users": {
-				"terms": { "field": "user_id.keyword", "size": 10000 },
-				"aggs": {
-					"latest_data": {
-						"top_hits": {
-							"sort": [ { "timestamp": { "order": "desc" } } ],
-							"_source": { "includes": [ "username", "first_name", "last_name" ] },
-							"size": 1
-						}
-					}
-				}
-			}
-		}
-	}`
+	queryLogs := SearchRequest{
+		Size: intPtr(0),
+		Aggs: map[string]Aggregation{
+			"users": TermsAgg("user_id.keyword", 10000).With(map[string]Aggregation{
+				"latest_data": TopHitsAgg(1, []SortField{SortBy("timestamp", "desc")}, []string{"username", "first_name", "last_name"}),
+			}),
+		},
+	}
 
-	resL, _ := es.Search(
-		es.Search.WithContext(context.Background()),
-		es.Search.WithIndex("user_logs"),
-		es.Search.WithBody(strings.NewReader(queryLogs)),
+	resL, _ := s.es.Search(
+		s.es.Search.WithContext(context.Background()),
+		s.es.Search.WithIndex("user_logs"),
+		s.es.Search.WithBody(queryLogs.Reader()),
 	)
 
 	if resL != nil && !resL.IsError() {
@@ -537,9 +950,9 @@ func generateUserReport(es *elasticsearch.Client) []UserReport {
 	return report
 }
 
-func isUserBanned(es *elasticsearch.Client, userID string) bool {
+func (s *ElasticStore) IsUserBanned(userID string) bool {
 	// Kita gunakan UserID sebagai Document ID agar pengecekan sangat cepat (O(1))
-	res, err := es.Get("user_blacklist", userID)
+	res, err := s.es.Get("user_blacklist", userID)
 
 	// Jika error atau 404 Not Found, berarti TIDAK di-ban
 	if err != nil || res.IsError() {
@@ -548,7 +961,7 @@ func isUserBanned(es *elasticsearch.Client, userID string) bool {
 	return true
 }
 
-func banUser(es *elasticsearch.Client, userID string, reason string) {
+func (s *ElasticStore) BanUser(userID string, reason string) {
 	entry := BlacklistEntry{
 		UserID:   userID,
 		BannedAt: time.Now(),
@@ -563,33 +976,367 @@ func banUser(es *elasticsearch.Client, userID string, reason string) {
 		Body:       bytes.NewReader(body),
 		Refresh:    "true",
 	}
-	req.Do(context.Background(), es)
+	req.Do(context.Background(), s.es)
 }
 
-func unbanUser(es *elasticsearch.Client, userID string) {
+func (s *ElasticStore) UnbanUser(userID string) {
 	req := esapi.DeleteRequest{
 		Index:      "user_blacklist",
 		DocumentID: userID,
 		Refresh:    "true",
 	}
-	req.Do(context.Background(), es)
+	req.Do(context.Background(), s.es)
 }
 
-func deleteBySource(es *elasticsearch.Client, filename string) int {
-	// Query: Hapus semua data yang leak_source == filename
-	query := fmt.Sprintf(`{
-		"query": {
-			"term": { "leak_source.keyword": "%s" }
+// --- ROLES (index user_roles, Document ID = user ID) ---
+
+func (s *ElasticStore) GetUserRole(userID string) Role {
+	res, err := s.es.Get("user_roles", userID)
+	if err != nil {
+		return roleUser
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return roleUser // 404 = belum pernah diberi role
+	}
+
+	var result struct {
+		Source RoleAssignment `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return roleUser
+	}
+	if role, ok := parseRole(string(result.Source.Role)); ok && role != roleOwner {
+		return role
+	}
+	return roleUser
+}
+
+func (s *ElasticStore) SetUserRole(assignment RoleAssignment) {
+	if assignment.Role == roleUser {
+		req := esapi.DeleteRequest{Index: "user_roles", DocumentID: assignment.UserID, Refresh: "true"}
+		if res, err := req.Do(context.Background(), s.es); err == nil {
+			res.Body.Close()
 		}
-	}`, filename)
+		return
+	}
+
+	body, _ := json.Marshal(assignment)
+	req := esapi.IndexRequest{
+		Index:      "user_roles",
+		DocumentID: assignment.UserID,
+		Body:       bytes.NewReader(body),
+		Refresh:    "true",
+	}
+	if res, err := req.Do(context.Background(), s.es); err == nil {
+		res.Body.Close()
+	}
+}
+
+func (s *ElasticStore) ListUserRoles() []RoleAssignment {
+	var roles []RoleAssignment
+
+	query := SearchRequest{Query: MatchAll(), Size: intPtr(1000)}
+	res, err := s.es.Search(
+		s.es.Search.WithContext(context.Background()),
+		s.es.Search.WithIndex("user_roles"),
+		s.es.Search.WithBody(query.Reader()),
+	)
+	if err != nil {
+		return roles
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return roles
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source RoleAssignment `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	json.NewDecoder(res.Body).Decode(&result)
+
+	for _, hit := range result.Hits.Hits {
+		roles = append(roles, hit.Source)
+	}
+	return roles
+}
+
+// --- QUOTAS (index user_quotas, Document ID = user ID) ---
+
+func (s *ElasticStore) GetUserQuota(userID string) (UserQuota, bool, error) {
+	var doc struct {
+		Source UserQuota `json:"_source"`
+	}
+	found, err := s.fetchDocument("user_quotas", userID, &doc) // 404 = belum pernah dihitung
+	doc.Source.UserID = userID
+	return doc.Source, found, err
+}
+
+// UpdateUserQuota memakai optimistic concurrency seperti RedeemAccessKey: dua export/pencarian bersamaan
+// tidak bisa sama-sama lolos dari sisa kuota yang sama. Tanpa refresh: GET by ID di ES sudah realtime.
+func (s *ElasticStore) UpdateUserQuota(userID string, fn func(q *UserQuota) error) (UserQuota, error) {
+	for attempt := 0; attempt < redeemMaxAttempts; attempt++ {
+		var doc struct {
+			SeqNo       int       `json:"_seq_no"`
+			PrimaryTerm int       `json:"_primary_term"`
+			Source      UserQuota `json:"_source"`
+		}
+		found, err := s.fetchDocument("user_quotas", userID, &doc)
+		if err != nil {
+			return UserQuota{}, err
+		}
+		quota := doc.Source
+		quota.UserID = userID
+		if err := fn(&quota); err != nil {
+			return quota, err
+		}
+
+		body, _ := json.Marshal(quota)
+		req := esapi.IndexRequest{
+			Index:      "user_quotas",
+			DocumentID: userID,
+			Body:       bytes.NewReader(body),
+		}
+		if found {
+			req.IfSeqNo, req.IfPrimaryTerm = &doc.SeqNo, &doc.PrimaryTerm
+		} else {
+			req.OpType = "create" // Dokumen pertama: 409 jika user yang sama lebih dulu membuatnya
+		}
+		res, err := req.Do(context.Background(), s.es)
+		if err != nil {
+			return quota, err
+		}
+		res.Body.Close()
+		if res.StatusCode == 409 {
+			continue
+		}
+		if res.IsError() {
+			return quota, fmt.Errorf("gagal menyimpan kuota: %s", res.Status())
+		}
+		return quota, nil
+	}
+	return UserQuota{}, errQuotaConflict
+}
+
+// --- API TOKENS (index api_tokens, Document ID = hash token) ---
+
+func (s *ElasticStore) SaveAPIToken(token APIToken) {
+	body, _ := json.Marshal(token)
+	req := esapi.IndexRequest{
+		Index:      "api_tokens",
+		DocumentID: token.Hash,
+		Body:       bytes.NewReader(body),
+		Refresh:    "true",
+	}
+	res, err := req.Do(context.Background(), s.es)
+	if err == nil {
+		res.Body.Close()
+	}
+}
+
+// GetAPIToken dipanggil setiap request API, GET by ID realtime tanpa perlu refresh
+func (s *ElasticStore) GetAPIToken(hash string) (APIToken, bool) {
+	var doc struct {
+		Source APIToken `json:"_source"`
+	}
+	if !s.getDocument("api_tokens", hash, &doc) {
+		return APIToken{}, false
+	}
+	return doc.Source, true
+}
+
+func (s *ElasticStore) ListAPITokens(userID string) []APIToken {
+	var tokens []APIToken
+
+	query := SearchRequest{Query: MatchAll(), Size: intPtr(1000), Sort: []SortField{SortBy("created_at", "asc")}}
+	if userID != "" {
+		query.Query = Term("user_id.keyword", userID)
+	}
+	res, err := s.es.Search(
+		s.es.Search.WithContext(context.Background()),
+		s.es.Search.WithIndex("api_tokens"),
+		s.es.Search.WithBody(query.Reader()),
+	)
+	if err != nil {
+		return tokens
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return tokens
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source APIToken `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	json.NewDecoder(res.Body).Decode(&result)
+
+	for _, hit := range result.Hits.Hits {
+		tokens = append(tokens, hit.Source)
+	}
+	return tokens
+}
+
+func (s *ElasticStore) DeleteAPIToken(hash string) bool {
+	req := esapi.DeleteRequest{Index: "api_tokens", DocumentID: hash, Refresh: "true"}
+	res, err := req.Do(context.Background(), s.es)
+	if err != nil {
+		return false
+	}
+	defer res.Body.Close()
+	return !res.IsError()
+}
+
+// --- WATCHLISTS (index watchlists, Document ID = watch ID) ---
+
+func (s *ElasticStore) SaveWatch(watch Watch) {
+	body, _ := json.Marshal(watch)
+	req := esapi.IndexRequest{
+		Index:      "watchlists",
+		DocumentID: watch.ID,
+		Body:       bytes.NewReader(body),
+		Refresh:    "true",
+	}
+	res, err := req.Do(context.Background(), s.es)
+	if err == nil {
+		res.Body.Close()
+	}
+}
+
+func (s *ElasticStore) ListWatches(userID string) []Watch {
+	var watches []Watch
+
+	query := SearchRequest{Query: MatchAll(), Size: intPtr(10000), Sort: []SortField{SortBy("created_at", "asc")}}
+	if userID != "" {
+		query.Query = Term("user_id.keyword", userID)
+	}
+	res, err := s.es.Search(
+		s.es.Search.WithContext(context.Background()),
+		s.es.Search.WithIndex("watchlists"),
+		s.es.Search.WithBody(query.Reader()),
+	)
+	if err != nil {
+		return watches
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return watches
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source Watch `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	json.NewDecoder(res.Body).Decode(&result)
+
+	for _, hit := range result.Hits.Hits {
+		watches = append(watches, hit.Source)
+	}
+	return watches
+}
+
+func (s *ElasticStore) DeleteWatch(id string) bool {
+	req := esapi.DeleteRequest{Index: "watchlists", DocumentID: id, Refresh: "true"}
+	res, err := req.Do(context.Background(), s.es)
+	if err != nil {
+		return false
+	}
+	defer res.Body.Close()
+	return !res.IsError()
+}
+
+// ClaimWatchAlerts memakai op "create" di index watch_alerts (ID = user + record):
+// record yang sudah pernah dikirim ditolak ES dengan 409, jadi aman walau dua job selesai bersamaan.
+// Jika request gagal total, tidak ada record yang diklaim (lebih baik tertunda daripada terkirim dobel).
+func (s *ElasticStore) ClaimWatchAlerts(userID string, recordIDs []string) []string {
+	if len(recordIDs) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	now := time.Now()
+	for _, id := range recordIDs {
+		enc.Encode(map[string]map[string]string{"create": {"_index": "watch_alerts", "_id": userID + ":" + id}})
+		enc.Encode(map[string]interface{}{"user_id": userID, "record_id": id, "alerted_at": now})
+	}
+
+	res, err := s.es.Bulk(
+		&buf,
+		s.es.Bulk.WithContext(context.Background()),
+		s.es.Bulk.WithRefresh("false"),
+	)
+	if err != nil {
+		return nil
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil
+	}
+
+	var response struct {
+		Items []map[string]struct {
+			Status int `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil
+	}
+	// Urutan items sama dengan urutan action di request
+	var fresh []string
+	for i, item := range response.Items {
+		if i < len(recordIDs) && item["create"].Status == http.StatusCreated {
+			fresh = append(fresh, recordIDs[i])
+		}
+	}
+	return fresh
+}
+
+// ReleaseWatchAlerts menghapus klaim (bulk delete, 404 diabaikan) agar record dikirim lagi di evaluasi berikutnya
+func (s *ElasticStore) ReleaseWatchAlerts(userID string, recordIDs []string) {
+	if len(recordIDs) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, id := range recordIDs {
+		enc.Encode(map[string]map[string]string{"delete": {"_index": "watch_alerts", "_id": userID + ":" + id}})
+	}
+	res, err := s.es.Bulk(
+		&buf,
+		s.es.Bulk.WithContext(context.Background()),
+		s.es.Bulk.WithRefresh("false"),
+	)
+	if err != nil {
+		log.Printf("⚠️ Gagal melepas %d klaim watch user %s: %v", len(recordIDs), userID, err)
+		return
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		log.Printf("⚠️ Gagal melepas %d klaim watch user %s: %s", len(recordIDs), userID, res.Status())
+	}
+}
+
+func (s *ElasticStore) DeleteBySource(filename string) int {
+	// Query: Hapus semua data yang leak_source == filename
+	query := SearchRequest{Query: Term("leak_source.keyword", filename)}
 
 	req := esapi.DeleteByQueryRequest{
 		Index:   []string{"breach_data"},
-		Body:    strings.NewReader(query),
+		Body:    query.Reader(),
 		Refresh: boolPtr(true), // Paksa refresh index agar data hilang seketika
 	}
 
-	res, err := req.Do(context.Background(), es)
+	res, err := req.Do(context.Background(), s.es)
 	if err != nil || res.IsError() {
 		return 0
 	}
@@ -609,6 +1356,60 @@ func deleteBySource(es *elasticsearch.Client, filename string) int {
 	return 0
 }
 
+// --- INGEST JOBS (Checkpoint & Resume) ---
+
+func (s *ElasticStore) SaveIngestJob(job IngestJobRecord) {
+	job.UpdatedAt = time.Now()
+	body, _ := json.Marshal(job)
+	req := esapi.IndexRequest{
+		Index:      "ingest_jobs",
+		DocumentID: job.ID,
+		Body:       bytes.NewReader(body),
+		Refresh:    "false",
+	}
+	res, err := req.Do(context.Background(), s.es)
+	if err == nil {
+		res.Body.Close()
+	}
+}
+
+func (s *ElasticStore) ListIngestJobs() []IngestJobRecord {
+	var jobs []IngestJobRecord
+
+	query := SearchRequest{
+		Query: MatchAll(),
+		Sort:  []SortField{SortBy("created_at", "desc")},
+		Size:  intPtr(1000),
+	}
+
+	res, err := s.es.Search(
+		s.es.Search.WithContext(context.Background()),
+		s.es.Search.WithIndex("ingest_jobs"),
+		s.es.Search.WithBody(query.Reader()),
+	)
+	if err != nil {
+		return jobs
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return jobs
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source IngestJobRecord `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	json.NewDecoder(res.Body).Decode(&result)
+
+	for _, hit := range result.Hits.Hits {
+		jobs = append(jobs, hit.Source)
+	}
+	return jobs
+}
+
 func boolPtr(b bool) *bool {
 	return &b
 }
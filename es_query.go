@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// --- QUERY BUILDER ---
+// Semua body request ke Elasticsearch dibangun dari struct/map lalu di-marshal dengan
+// encoding/json. Input user selalu menjadi nilai string yang ter-escape, tidak pernah
+// disambung ke teks JSON, jadi tidak bisa menyisipkan clause / script baru.
+
+// Query adalah satu clause query DSL, misal {"match": {...}} atau {"bool": {...}}
+type Query map[string]interface{}
+
+// Aggregation adalah satu definisi aggregation, misal {"terms": {...}, "aggs": {...}}
+type Aggregation map[string]interface{}
+
+// SortField: {"timestamp": {"order": "desc"}}
+type SortField map[string]SortOrder
+
+type SortOrder struct {
+	Order string `json:"order"`
+}
+
+type SearchRequest struct {
+	Query          Query                  `json:"query,omitempty"`
+	Source         interface{}            `json:"_source,omitempty"`
+	Sort           []SortField            `json:"sort,omitempty"`
+	Size           *int                   `json:"size,omitempty"`
+	Aggs           map[string]Aggregation `json:"aggs,omitempty"`
+	PIT            *PointInTime           `json:"pit,omitempty"`
+	SearchAfter    []interface{}          `json:"search_after,omitempty"`
+	TrackTotalHits bool                   `json:"track_total_hits,omitempty"`
+
+	RuntimeMappings map[string]RuntimeField `json:"runtime_mappings,omitempty"`
+}
+
+// PointInTime: snapshot index untuk paging search_after yang konsisten
+type PointInTime struct {
+	ID        string `json:"id"`
+	KeepAlive string `json:"keep_alive,omitempty"`
+}
+
+// JSON mengembalikan body request. Semua isi request berupa string/angka/map,
+// jadi Marshal tidak mungkin gagal.
+func (r SearchRequest) JSON() []byte {
+	body, _ := json.Marshal(r)
+	return body
+}
+
+func (r SearchRequest) Reader() io.Reader {
+	return bytes.NewReader(r.JSON())
+}
+
+type matchQuery struct {
+	Query     string `json:"query"`
+	Operator  string `json:"operator,omitempty"`
+	Fuzziness string `json:"fuzziness,omitempty"`
+}
+
+type multiMatchQuery struct {
+	Query     string   `json:"query"`
+	Fields    []string `json:"fields"`
+	Type      string   `json:"type,omitempty"`
+	Operator  string   `json:"operator,omitempty"`
+	Fuzziness string   `json:"fuzziness,omitempty"`
+}
+
+func MatchAll() Query {
+	return Query{"match_all": struct{}{}}
+}
+
+// Match: operator "" / "and" / "or", fuzziness "" (default ES) / "0" / "AUTO"
+func Match(field, text, operator, fuzziness string) Query {
+	return Query{"match": map[string]matchQuery{
+		field: {Query: text, Operator: operator, Fuzziness: fuzziness},
+	}}
+}
+
+func MultiMatch(text string, fields []string, operator, fuzziness string) Query {
+	return Query{"multi_match": multiMatchQuery{Query: text, Fields: fields, Operator: operator, Fuzziness: fuzziness}}
+}
+
+func MatchPhrase(field, text string) Query {
+	return Query{"match_phrase": map[string]string{field: text}}
+}
+
+func MultiMatchPhrase(text string, fields []string) Query {
+	return Query{"multi_match": multiMatchQuery{Query: text, Fields: fields, Type: "phrase"}}
+}
+
+func Exists(field string) Query {
+	return Query{"exists": map[string]string{"field": field}}
+}
+
+// BoolQuery: must = AND, should = OR, must_not = NOT
+type BoolQuery struct {
+	Must               []Query `json:"must,omitempty"`
+	Should             []Query `json:"should,omitempty"`
+	MustNot            []Query `json:"must_not,omitempty"`
+	MinimumShouldMatch int     `json:"minimum_should_match,omitempty"`
+}
+
+func (b BoolQuery) Query() Query {
+	return Query{"bool": b}
+}
+
+func Term(field string, value interface{}) Query {
+	return Query{"term": map[string]interface{}{field: value}}
+}
+
+// Range: bounds berisi gt/gte/lt/lte, contoh Range("expires_at", map[string]interface{}{"gt": "now"})
+func Range(field string, bounds map[string]interface{}) Query {
+	return Query{"range": map[string]interface{}{field: bounds}}
+}
+
+func SortBy(field, order string) SortField {
+	return SortField{field: {Order: order}}
+}
+
+func TermsAgg(field string, size int) Aggregation {
+	return Aggregation{"terms": map[string]interface{}{"field": field, "size": size}}
+}
+
+func CardinalityAgg(field string) Aggregation {
+	return Aggregation{"cardinality": map[string]interface{}{"field": field}}
+}
+
+func MaxAgg(field string) Aggregation {
+	return Aggregation{"max": map[string]interface{}{"field": field}}
+}
+
+// FilterAgg: hitung hanya dokumen yang cocok dengan q (biasanya dipakai bersama With)
+func FilterAgg(q Query) Aggregation {
+	return Aggregation{"filter": q}
+}
+
+func TopHitsAgg(size int, sort []SortField, includes []string) Aggregation {
+	return Aggregation{"top_hits": map[string]interface{}{
+		"size":    size,
+		"sort":    sort,
+		"_source": map[string][]string{"includes": includes},
+	}}
+}
+
+// RuntimeField: field yang dihitung script saat query, tanpa mengubah mapping index
+type RuntimeField map[string]interface{}
+
+// KeywordRuntimeField: script memanggil emit(String) untuk setiap nilai
+func KeywordRuntimeField(script string) RuntimeField {
+	return RuntimeField{"type": "keyword", "script": map[string]string{"source": script}}
+}
+
+// With menambahkan sub-aggregation
+func (a Aggregation) With(sub map[string]Aggregation) Aggregation {
+	a["aggs"] = sub
+	return a
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/elastic/go-elasticsearch/v9"
+)
+
+// hostileKeywords are inputs that used to break or inject into hand-written query JSON.
+var hostileKeywords = []string{
+	`rudi`,
+	`"`,
+	`\`,
+	`\"`,
+	`foo" } }, "script": { "source": "ctx._source.x = 1" } }, "x": { "y": "`,
+	`a"b\c`,
+	"tab\there\nnewline\r\x00nul\x1f",
+	"   <script>&amp;",
+	`email:evil"@x.com`,
+	`ema"il:x@y.com`,
+	`password\:*`,
+	`{"match_all":{}}`,
+	`用户@例子.公司`,
+}
+
+// decodeQuery unmarshals a request body and fails the test if it is not valid JSON.
+func decodeQuery(t *testing.T, body []byte) map[string]interface{} {
+	t.Helper()
+	if !json.Valid(body) {
+		t.Fatalf("invalid JSON body: %s", body)
+	}
+	var out map[string]interface{}
+	json.Unmarshal(body, &out)
+	return out
+}
+
+// dig walks nested maps by key.
+func dig(m map[string]interface{}, keys ...string) interface{} {
+	var cur interface{} = m
+	for _, k := range keys {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = obj[k]
+	}
+	return cur
+}
+
+// checkSearchQuery asserts that any keyword yields either a syntax error or valid JSON,
+// and that the keyword quoted as a phrase is sent to Elasticsearch unchanged.
+func checkSearchQuery(t *testing.T, keyword string) {
+	t.Helper()
+	req, err := buildSearchQuery(keyword, true)
+	if err != nil {
+		var syntaxErr *QuerySyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("buildSearchQuery(%q) error = %v, want *QuerySyntaxError", keyword, err)
+		}
+	} else {
+		decodeQuery(t, req.JSON())
+	}
+
+	req, err = buildSearchQuery(quoteQueryValue(keyword), true)
+	if err != nil {
+		t.Fatalf("quoted keyword %q failed to parse: %v", keyword, err)
+	}
+	q := decodeQuery(t, req.JSON())
+	if len(q) != 1 || len(q["query"].(map[string]interface{})) != 1 {
+		t.Fatalf("unexpected clauses for %q: %v", keyword, q)
+	}
+	if got := dig(q, "query", "match_phrase", "full_text"); got != keyword {
+		t.Errorf("match_phrase.full_text = %q, want %q", got, keyword)
+	}
+
+	req, err = buildSearchQuery("email:"+quoteQueryValue(keyword), true)
+	if err != nil {
+		t.Fatalf("quoted field value %q failed to parse: %v", keyword, err)
+	}
+	q = decodeQuery(t, req.JSON())
+	canonical := dig(q, "query", "bool", "should").([]interface{})[0].(map[string]interface{})
+	if got := dig(canonical, "multi_match", "query"); got != keyword {
+		t.Errorf("multi_match.query = %q, want %q", got, keyword)
+	}
+}
+
+// TestBuildSearchQueryEscaping tests that hostile keywords always produce valid, equivalent JSON.
+func TestBuildSearchQueryEscaping(t *testing.T) {
+	for _, kw := range hostileKeywords {
+		checkSearchQuery(t, kw)
+	}
+
+	// Keyword sederhana tetap menghasilkan query yang sama seperti sebelum ada query language
+	req, _ := buildSearchQuery("rudi", true)
+	if got := dig(decodeQuery(t, req.JSON()), "query", "match", "full_text", "query"); got != "rudi" {
+		t.Errorf("match.full_text.query = %v, want rudi", got)
+	}
+	req, _ = buildSearchQuery(`ema"il:x@y.com`, true)
+	if req.Query != nil {
+		t.Errorf("unterminated quote should not build a query: %s", req.JSON())
+	}
+	// Field kanonik, atau kolom mirip untuk record lama yang belum punya field kanonik
+	req, _ = buildSearchQuery("email:x@y.com", true)
+	q := decodeQuery(t, req.JSON())
+	should := dig(q, "query", "bool", "should").([]interface{})
+	canonical := should[0].(map[string]interface{})
+	legacy := dig(should[1].(map[string]interface{}), "bool").(map[string]interface{})
+	fields := dig(canonical, "multi_match", "fields").([]interface{})
+	if len(should) != 2 || dig(canonical, "multi_match", "query") != "x@y.com" || len(fields) != 1 || fields[0] != "email" {
+		t.Errorf("canonical field query = %s", req.JSON())
+	}
+	fields = dig(legacy["must"].([]interface{})[0].(map[string]interface{}), "multi_match", "fields").([]interface{})
+	if fields[0] != "*email*" || dig(legacy["must_not"].([]interface{})[0].(map[string]interface{}), "exists", "field") != "email" {
+		t.Errorf("legacy fallback = %s", req.JSON())
+	}
+	req, _ = buildSearchQuery("source:old.txt", true)
+	q = decodeQuery(t, req.JSON())
+	fields = dig(q, "query", "multi_match", "fields").([]interface{})
+	if dig(q, "query", "multi_match", "query") != "old.txt" || fields[0] != "*source*" || fields[1] != "*SOURCE*" {
+		t.Errorf("field query = %s", req.JSON())
+	}
+}
+
+// FuzzBuildSearchQuery fuzzes buildSearchQuery with arbitrary keywords.
+func FuzzBuildSearchQuery(f *testing.F) {
+	for _, kw := range hostileKeywords {
+		f.Add(kw)
+	}
+	f.Fuzz(func(t *testing.T, keyword string) {
+		// Telegram selalu mengirim UTF-8 valid, encoding/json mengganti byte rusak dengan U+FFFD
+		if !utf8.ValidString(keyword) {
+			t.Skip()
+		}
+		checkSearchQuery(t, keyword)
+	})
+}
+
+// TestElasticStoreQueryBodies tests the bodies sent by SearchActivity and DeleteBySource
+// against a fake Elasticsearch server.
+func TestElasticStoreQueryBodies(t *testing.T) {
+	var mu sync.Mutex
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.URL.Path, "_delete_by_query") {
+			io.WriteString(w, `{"deleted": 3}`)
+			return
+		}
+		io.WriteString(w, `{"hits": {"total": {"value": 0}, "hits": []}}`)
+	}))
+	defer srv.Close()
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewElasticStore(es)
+
+	for _, kw := range hostileKeywords {
+		bodies = nil
+		store.SearchActivity(kw, 20)
+		if n := store.DeleteBySource(kw); n != 3 {
+			t.Errorf("DeleteBySource() = %d, want 3", n)
+		}
+		if len(bodies) != 2 {
+			t.Fatalf("got %d requests, want 2", len(bodies))
+		}
+
+		audit := decodeQuery(t, bodies[0])
+		if got := dig(audit, "query", "multi_match", "query"); got != kw {
+			t.Errorf("SearchActivity query = %q, want %q", got, kw)
+		}
+		del := decodeQuery(t, bodies[1])
+		if got := dig(del, "query", "term", "leak_source.keyword"); got != kw {
+			t.Errorf("DeleteBySource term = %q, want %q", got, kw)
+		}
+		if len(del) != 1 {
+			t.Errorf("DeleteBySource body has extra clauses: %v", del)
+		}
+	}
+}
+
+// TestElasticStoreRedeemConcurrent tests that concurrent redeems of a single-use key
+// against a fake Elasticsearch with if_seq_no checks produce exactly one winner.
+func TestElasticStoreRedeemConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	seqNo := 0
+	doc, _ := json.Marshal(AccessKey{Key: "BR-RACE", Active: true, MaxUses: 1})
+	conflicts := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method == http.MethodGet {
+			fmt.Fprintf(w, `{"found": true, "_seq_no": %d, "_primary_term": 1, "_source": %s}`, seqNo, doc)
+			return
+		}
+		if r.URL.Query().Get("if_seq_no") != strconv.Itoa(seqNo) || r.URL.Query().Get("if_primary_term") != "1" {
+			conflicts++
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, `{"error": {"type": "version_conflict_engine_exception"}, "status": 409}`)
+			return
+		}
+		doc, _ = io.ReadAll(r.Body)
+		seqNo++
+		io.WriteString(w, `{"result": "updated"}`)
+	}))
+	defer srv.Close()
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewElasticStore(es)
+
+	const users = 20
+	var wg sync.WaitGroup
+	var winners []string
+	var winMu sync.Mutex
+	start := make(chan struct{})
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(uid string) {
+			defer wg.Done()
+			<-start
+			if _, err := store.RedeemAccessKey("BR-RACE", uid, time.Now()); err == nil {
+				winMu.Lock()
+				winners = append(winners, uid)
+				winMu.Unlock()
+			}
+		}(strconv.Itoa(i))
+	}
+	close(start)
+	wg.Wait()
+
+	if len(winners) != 1 {
+		t.Fatalf("got %d winners %v, want exactly 1", len(winners), winners)
+	}
+	var saved AccessKey
+	json.Unmarshal(doc, &saved)
+	if saved.Uses != 1 || saved.Active || len(saved.RedeemedBy) != 1 || saved.RedeemedBy[0] != winners[0] {
+		t.Errorf("saved key = %+v, want consumed by %s", saved, winners[0])
+	}
+	t.Logf("%d version conflicts resolved", conflicts)
+}
+
+// TestElasticStoreRedeemUnavailable tests that an Elasticsearch failure is not reported as an unknown key.
+func TestElasticStoreRedeemUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/BR-NONE") {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"found": false}`)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, `{"error": "unavailable"}`)
+	}))
+	defer srv.Close()
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}, DisableRetry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewElasticStore(es)
+	if _, err := store.RedeemAccessKey("BR-NONE", "7", time.Now()); err != errKeyNotFound {
+		t.Errorf("missing key error = %v, want errKeyNotFound", err)
+	}
+	if _, err := store.RedeemAccessKey("BR-DOWN", "7", time.Now()); err != errKeyUnavailable {
+		t.Errorf("ES failure error = %v, want errKeyUnavailable", err)
+	}
+}
+
+// TestElasticStoreGetUserQuota tests that a missing quota document differs from an Elasticsearch failure.
+func TestElasticStoreGetUserQuota(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/new"):
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"found": false}`)
+		case strings.HasSuffix(r.URL.Path, "/down"):
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, `{"error": "unavailable"}`)
+		default:
+			io.WriteString(w, `{"found": true, "_source": {"user_id": "7", "searches": 3}}`)
+		}
+	}))
+	defer srv.Close()
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}, DisableRetry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewElasticStore(es)
+
+	if q, found, err := store.GetUserQuota("7"); !found || err != nil || q.Searches != 3 {
+		t.Errorf("GetUserQuota(7) = %+v, %v, %v", q, found, err)
+	}
+	if _, found, err := store.GetUserQuota("new"); found || err != nil {
+		t.Errorf("GetUserQuota(new) = %v, %v, want not found without error", found, err)
+	}
+	if _, _, err := store.GetUserQuota("down"); err == nil {
+		t.Error("GetUserQuota(down) error = nil, want error")
+	}
+}
+
+// TestElasticStoreUpdateUserQuotaConcurrent tests that concurrent quota updates never
+// lose an increment or pass the limit, starting from a missing document.
+func TestElasticStoreUpdateUserQuotaConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	seqNo := 0
+	var doc []byte // nil = dokumen belum ada
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method == http.MethodGet {
+			if doc == nil {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, `{"found": false}`)
+				return
+			}
+			fmt.Fprintf(w, `{"found": true, "_seq_no": %d, "_primary_term": 1, "_source": %s}`, seqNo, doc)
+			return
+		}
+		q := r.URL.Query()
+		created := q.Get("op_type") == "create" || strings.HasSuffix(r.URL.Path, "/_create/7")
+		if created && doc != nil || !created && q.Get("if_seq_no") != strconv.Itoa(seqNo) {
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, `{"error": {"type": "version_conflict_engine_exception"}, "status": 409}`)
+			return
+		}
+		doc, _ = io.ReadAll(r.Body)
+		seqNo++
+		io.WriteString(w, `{"result": "updated"}`)
+	}))
+	defer srv.Close()
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewElasticStore(es)
+
+	const limit = 3
+	var wg sync.WaitGroup
+	var wins, exhausted int
+	var winMu sync.Mutex
+	start := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := store.UpdateUserQuota("7", func(q *UserQuota) error {
+				if q.Searches >= limit {
+					return errQuotaExhausted
+				}
+				q.Searches++
+				return nil
+			})
+			winMu.Lock()
+			defer winMu.Unlock()
+			switch err {
+			case nil:
+				wins++
+			case errQuotaExhausted:
+				exhausted++
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	var saved UserQuota
+	json.Unmarshal(doc, &saved)
+	if wins == 0 || wins > limit || saved.Searches != wins || saved.UserID != "7" {
+		t.Errorf("%d updates succeeded, saved %+v, want at most %d and no lost increments", wins, saved, limit)
+	}
+	t.Logf("%d succeeded, %d exhausted", wins, exhausted)
+}
+
+// TestElasticStoreDomainReportAccounts tests that accounts are counted case-insensitively
+// over the canonical email field.
+func TestElasticStoreDomainReportAccounts(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"hits": {"total": {"value": 4}}, "aggregations": {"accounts": {"value": 3}}}`)
+	}))
+	defer srv.Close()
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := NewElasticStore(es).DomainReport("acme.com")
+	if err != nil || report.Records != 4 || report.Accounts != 3 {
+		t.Fatalf("DomainReport() = %+v, %v", report, err)
+	}
+
+	q := decodeQuery(t, body)
+	if got := dig(q, "aggs", "accounts", "cardinality", "field"); got != domainAccountField {
+		t.Errorf("accounts field = %v, want %s", got, domainAccountField)
+	}
+	script, _ := dig(q, "runtime_mappings", domainAccountField, "script", "source").(string)
+	if !strings.Contains(script, "doc['email.keyword']") || !strings.Contains(script, "toLowerCase()") {
+		t.Errorf("account runtime field = %v, want lowercase canonical email", dig(q, "runtime_mappings"))
+	}
+}
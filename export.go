@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// --- STREAMING EXPORT ---
+// Hasil pencarian diambil per halaman (PIT + search_after) dan ditulis ke spool file
+// sebagai JSON lines sambil mengumpulkan nama kolom. Setelah semua halaman selesai,
+// spool dibaca ulang oleh Exporter untuk menulis file (dipecah jika melebihi batas Telegram).
+
+const (
+	exportPageSize  = 1000
+	exportPartLimit = 45 << 20 // Batas upload bot Telegram 50MB, sisakan margin
+	exportMaxGroups = 100      // Maksimal sheet/tabel per leak_source (XLSX & HTML)
+
+	exportOtherSource = "(lainnya)"
+	exportNoSource    = "(tanpa source)"
+)
+
+// Field internal yang tidak ikut di-export
+var exportSkipFields = map[string]bool{"full_text": true, "raw_content": true}
+
+var (
+	errExportTooLarge = errors.New("file export melebihi batas ukuran Telegram")
+	errStopIteration  = errors.New("stop")
+)
+
+type exportSpool struct {
+	dir     string // Temp dir berisi spool & file hasil export
+	file    *os.File
+	headers map[string]bool
+	sources map[string]*exportSource
+
+	Keyword string
+	Rows    int  // Jumlah dokumen yang benar-benar terambil
+	Total   int  // Total hit menurut Store
+	Capped  bool // Berhenti karena maxRows (sisa kuota baris), bukan karena error
+}
+
+// exportSource: statistik satu leak_source di dalam spool
+type exportSource struct {
+	Name    string
+	Rows    int
+	headers map[string]bool
+}
+
+// spoolSearchResults mengambil SEMUA hasil keyword (maksimal maxRows, 0 = tanpa batas).
+// onPage dipanggil setiap halaman (untuk progress).
+// Jika error di tengah jalan, spool tetap dikembalikan berisi data yang sudah terambil.
+// Kolom sensitif sudah diproses sesuai policy sebelum ditulis ke disk.
+func spoolSearchResults(store Store, keyword string, policy SensitivePolicy, hashKey []byte, maxRows int, onPage func(rows, total int)) (*exportSpool, error) {
+	dir, err := os.MkdirTemp("", "export-*")
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(filepath.Join(dir, "spool.jsonl"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	sp := &exportSpool{dir: dir, file: file, headers: make(map[string]bool), sources: make(map[string]*exportSource), Keyword: keyword}
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	cursor := SearchCursor{}
+	defer func() { store.CloseSearchCursor(cursor) }()
+
+	for {
+		result, next, err := store.SearchBreachesAfter(keyword, exportPageSize, cursor)
+		if err != nil {
+			w.Flush()
+			return sp, err
+		}
+		cursor = next
+		sp.Total = result.Hits.Total.Value
+
+		hits := result.Hits.Hits
+		if maxRows > 0 && sp.Rows+len(hits) >= maxRows {
+			hits = hits[:maxRows-sp.Rows]
+		}
+		for _, hit := range hits {
+			doc := exportRow(hit.Source)
+			policy.Apply(doc, hashKey)
+			src := sp.source(docSource(doc))
+			for k := range doc {
+				sp.headers[k] = true
+				src.headers[k] = true
+			}
+			if err := enc.Encode(doc); err != nil {
+				return sp, err
+			}
+			src.Rows++
+			sp.Rows++
+		}
+		if onPage != nil {
+			onPage(sp.Rows, sp.Total)
+		}
+		if maxRows > 0 && sp.Rows >= maxRows {
+			sp.Capped = sp.Rows < sp.Total
+			break
+		}
+		if len(result.Hits.Hits) < exportPageSize {
+			break
+		}
+	}
+	return sp, w.Flush()
+}
+
+// exportRow membuang field internal dari dokumen
+func exportRow(src map[string]interface{}) map[string]interface{} {
+	doc := make(map[string]interface{}, len(src))
+	for k, v := range src {
+		if !exportSkipFields[k] {
+			doc[k] = v
+		}
+	}
+	return doc
+}
+
+func docSource(doc map[string]interface{}) string {
+	if name := cellText(doc["leak_source"]); name != "" {
+		return name
+	}
+	return exportNoSource
+}
+
+func (sp *exportSpool) source(name string) *exportSource {
+	src, ok := sp.sources[name]
+	if !ok {
+		src = &exportSource{Name: name, headers: make(map[string]bool)}
+		sp.sources[name] = src
+	}
+	return src
+}
+
+// Headers mengembalikan semua kolom yang ditemukan (dipakai semua format export)
+func (sp *exportSpool) Headers() []string {
+	return sortedHeaders(sp.headers)
+}
+
+// Kolom selalu urut A-Z agar konsisten antar export
+func sortedHeaders(set map[string]bool) []string {
+	headers := make([]string, 0, len(set))
+	for k := range set {
+		headers = append(headers, k)
+	}
+	sort.Strings(headers)
+	return headers
+}
+
+// Sources mengembalikan statistik per leak_source, terbanyak dulu
+func (sp *exportSpool) Sources() []exportSource {
+	var out []exportSource
+	for _, src := range sp.sources {
+		out = append(out, *src)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Rows != out[j].Rows {
+			return out[i].Rows > out[j].Rows
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Each membaca ulang spool dari awal
+func (sp *exportSpool) Each(fn func(doc map[string]interface{}) error) error {
+	if _, err := sp.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return eachJSONLine(sp.file, fn)
+}
+
+func eachJSONLine(r io.Reader, fn func(doc map[string]interface{}) error) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.UseNumber() // Angka tetap persis (nomor HP, ID) tanpa notasi 1e+06
+	for {
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+}
+
+// Close menghapus spool beserta semua file export
+func (sp *exportSpool) Close() {
+	sp.file.Close()
+	os.RemoveAll(sp.dir)
+}
+
+// --- GROUP PER LEAK SOURCE ---
+
+// exportGroup: dokumen satu leak_source (atau gabungan source kecil) di file sementara sendiri
+type exportGroup struct {
+	Source  string
+	Rows    int
+	Headers []string
+	path    string
+}
+
+func (g *exportGroup) Each(fn func(doc map[string]interface{}) error) error {
+	f, err := os.Open(g.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return eachJSONLine(f, fn)
+}
+
+// Groups memecah spool per leak_source. Jika source lebih dari maxGroups, source terkecil
+// digabung ke satu grup exportOtherSource agar jumlah sheet/tabel tetap wajar.
+func (sp *exportSpool) Groups(maxGroups int) ([]*exportGroup, error) {
+	sources := sp.Sources()
+	var groups []*exportGroup
+	byName := make(map[string]*exportGroup)
+	var other *exportGroup
+	otherHeaders := make(map[string]bool)
+
+	for i, src := range sources {
+		if len(sources) > maxGroups && i >= maxGroups-1 {
+			if other == nil {
+				other = &exportGroup{Source: exportOtherSource}
+				groups = append(groups, other)
+			}
+			for k := range src.headers {
+				otherHeaders[k] = true
+			}
+			other.Rows += src.Rows
+			byName[src.Name] = other
+			continue
+		}
+		g := &exportGroup{Source: src.Name, Rows: src.Rows, Headers: sortedHeaders(src.headers)}
+		groups = append(groups, g)
+		byName[src.Name] = g
+	}
+	if other != nil {
+		other.Headers = sortedHeaders(otherHeaders)
+	}
+
+	writers := make(map[*exportGroup]*bufio.Writer, len(groups))
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for i, g := range groups {
+		g.path = filepath.Join(sp.dir, fmt.Sprintf("group%d.jsonl", i))
+		f, err := os.Create(g.path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		writers[g] = bufio.NewWriter(f)
+	}
+
+	err := sp.Each(func(doc map[string]interface{}) error {
+		line, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		w := writers[byName[docSource(doc)]]
+		w.Write(line)
+		return w.WriteByte('\n')
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range writers {
+		if err := w.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// --- EXPORTER ---
+
+// Exporter menulis isi spool ke satu atau lebih file di temp dir spool
+type Exporter interface {
+	Ext() string
+	Export(sp *exportSpool, baseName string, limit int64) ([]exportPart, error)
+}
+
+type exportPart struct {
+	Path string
+	Rows int
+}
+
+// exporters: format yang bisa dipilih di `/export <format> <keyword>`, csv = default
+var exporters = map[string]Exporter{
+	"csv":    rowExporter{ext: "csv", open: newCSVRowWriter},
+	"json":   rowExporter{ext: "json", open: newJSONRowWriter},
+	"ndjson": rowExporter{ext: "ndjson", open: newNDJSONRowWriter},
+	"xlsx":   xlsxExporter{},
+	"html":   htmlExporter{},
+}
+
+// parseExportArgs membaca opsi di depan keyword: format ("xlsx") dan/atau "secure" (zip terenkripsi).
+// Contoh: "secure xlsx rudi". Kata terakhir selalu dianggap keyword walau sama dengan nama opsi.
+func parseExportArgs(args string) ExportRequest {
+	req := ExportRequest{Format: "csv", Keyword: strings.TrimSpace(args)}
+	for {
+		first, rest, found := strings.Cut(req.Keyword, " ")
+		rest = strings.TrimSpace(rest)
+		if !found || rest == "" {
+			return req
+		}
+		opt := strings.ToLower(first)
+		if _, ok := exporters[opt]; ok {
+			req.Format = opt
+		} else if opt == "secure" {
+			req.Encrypt = true
+		} else {
+			return req
+		}
+		req.Keyword = rest
+	}
+}
+
+// rowWriter menulis satu format baris demi baris. Setiap bagian file berdiri sendiri
+// (header/pembuka ditulis saat dibuat, penutup saat Close).
+type rowWriter interface {
+	WriteRow(doc map[string]interface{}) error
+	Close() error
+}
+
+// rowExporter: format streaming yang bisa dipecah menjadi beberapa bagian file
+type rowExporter struct {
+	ext  string
+	open func(w io.Writer, headers []string) (rowWriter, error)
+}
+
+func (e rowExporter) Ext() string { return e.ext }
+
+// Export memulai file baru setiap ukuran bagian mencapai limit
+func (e rowExporter) Export(sp *exportSpool, baseName string, limit int64) ([]exportPart, error) {
+	headers := sp.Headers()
+	var parts []exportPart
+
+	var file *os.File
+	var buf *bufio.Writer
+	var cw *countingWriter
+	var rw rowWriter
+
+	closePart := func() error {
+		if file == nil {
+			return nil
+		}
+		if err := rw.Close(); err != nil {
+			return err
+		}
+		if err := buf.Flush(); err != nil {
+			return err
+		}
+		return file.Close()
+	}
+	openPart := func() error {
+		if err := closePart(); err != nil {
+			return err
+		}
+		path := filepath.Join(sp.dir, fmt.Sprintf("%s_part%d.%s", baseName, len(parts)+1, e.ext))
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		file, buf = f, bufio.NewWriter(f)
+		cw = &countingWriter{w: buf}
+		parts = append(parts, exportPart{Path: path})
+		rw, err = e.open(cw, headers)
+		return err
+	}
+
+	err := sp.Each(func(doc map[string]interface{}) error {
+		if file == nil || cw.n >= limit {
+			if err := openPart(); err != nil {
+				return err
+			}
+		}
+		parts[len(parts)-1].Rows++
+		return rw.WriteRow(doc)
+	})
+	if cerr := closePart(); err == nil {
+		err = cerr
+	}
+
+	// Hanya satu bagian -> nama file tanpa suffix _part1
+	if err == nil && len(parts) == 1 {
+		single := filepath.Join(sp.dir, baseName+"."+e.ext)
+		if os.Rename(parts[0].Path, single) == nil {
+			parts[0].Path = single
+		}
+	}
+	return parts, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Nilai sel sebagai teks; nil -> kosong
+func cellText(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --- ENCRYPTED EXPORT (WinZip AES-256 / AE-2) ---
+// Zip terenkripsi AES bisa dibuka 7-Zip, WinRAR, Keka, bsdtar, dll tanpa tool khusus.
+// archive/zip tidak mendukung enkripsi, jadi data di-deflate & dienkripsi sendiri lalu
+// ditulis lewat CreateRaw. Format: salt(16) + verifier(2) + ciphertext + HMAC-SHA1(10).
+
+const (
+	aesZipMethod     = 99     // Method khusus WinZip AES
+	aesZipExtraID    = 0x9901 // Extra field AES
+	aesZipSaltLen    = 16     // AES-256
+	aesZipKeyLen     = 32
+	aesZipMACLen     = 10
+	aesZipIterations = 1000 // Ditetapkan spesifikasi WinZip
+
+	exportPasswordLen = 16
+)
+
+// Tanpa huruf/angka yang mirip (0/O, 1/l/I) agar mudah diketik ulang
+const exportPasswordChars = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz23456789"
+
+func generateExportPassword() string {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(exportPasswordChars)))
+	for i := 0; i < exportPasswordLen; i++ {
+		n, _ := rand.Int(rand.Reader, max)
+		sb.WriteByte(exportPasswordChars[n.Int64()])
+	}
+	return sb.String()
+}
+
+// encryptExportParts membungkus setiap bagian export menjadi zip AES terpisah
+// (setiap zip tetap di bawah batas ukuran Telegram karena isinya sudah dibatasi)
+func encryptExportParts(parts []exportPart, password string) ([]exportPart, error) {
+	var out []exportPart
+	for _, part := range parts {
+		zipPath := strings.TrimSuffix(part.Path, filepath.Ext(part.Path)) + ".zip"
+		if err := writeAESZipFile(zipPath, part.Path, password); err != nil {
+			return nil, err
+		}
+		os.Remove(part.Path) // File plaintext tidak perlu disimpan lebih lama
+		out = append(out, exportPart{Path: zipPath, Rows: part.Rows})
+	}
+	return out, nil
+}
+
+func writeAESZipFile(zipPath, srcPath, password string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := bufio.NewWriter(f)
+	zw := zip.NewWriter(buf)
+	if err := writeAESZipEntry(zw, filepath.Base(srcPath), src, password, time.Now()); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeAESZipEntry menulis satu file terenkripsi. Ukuran baru diketahui setelah streaming,
+// jadi dipakai data descriptor (flag 0x8) dan header diisi sebelum entry ditutup.
+func writeAESZipEntry(zw *zip.Writer, name string, r io.Reader, password string, modTime time.Time) error {
+	salt := make([]byte, aesZipSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	keys := pbkdf2SHA1([]byte(password), salt, aesZipIterations, 2*aesZipKeyLen+2)
+	encKey, authKey, verifier := keys[:aesZipKeyLen], keys[aesZipKeyLen:2*aesZipKeyLen], keys[2*aesZipKeyLen:]
+
+	// Extra field AES: versi AE-2 (tanpa CRC), vendor "AE", kekuatan 3 = AES-256, method asli deflate
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], aesZipExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], 2)
+	copy(extra[6:], "AE")
+	extra[8] = 3
+	binary.LittleEndian.PutUint16(extra[9:], zip.Deflate)
+
+	fh := &zip.FileHeader{
+		Name:          name,
+		Method:        aesZipMethod,
+		Flags:         0x1 | 0x8, // Terenkripsi + data descriptor
+		Extra:         extra,
+		ReaderVersion: 51,
+	}
+	fh.ModifiedDate, fh.ModifiedTime = msDosTime(modTime)
+
+	w, err := zw.CreateRaw(fh)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(append(salt, verifier...)); err != nil {
+		return err
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha1.New, authKey)
+	enc := &aesZipWriter{ctr: newWinZipCTR(block), mac: mac, w: w}
+	fw, _ := flate.NewWriter(enc, flate.DefaultCompression)
+	plain, err := io.Copy(fw, r)
+	if err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	if _, err := w.Write(mac.Sum(nil)[:aesZipMACLen]); err != nil {
+		return err
+	}
+
+	compressed := uint64(aesZipSaltLen+2+aesZipMACLen) + uint64(enc.n)
+	fh.CompressedSize64, fh.UncompressedSize64 = compressed, uint64(plain)
+	fh.CompressedSize, fh.UncompressedSize = uint32(min(compressed, 0xffffffff)), uint32(min(uint64(plain), 0xffffffff))
+	fh.CRC32 = 0 // AE-2: integritas dijamin HMAC
+	return nil
+}
+
+// aesZipWriter mengenkripsi aliran deflate lalu menghitung HMAC dari ciphertext
+type aesZipWriter struct {
+	ctr *winZipCTR
+	mac hash.Hash
+	w   io.Writer
+	n   int64
+}
+
+func (a *aesZipWriter) Write(p []byte) (int, error) {
+	out := make([]byte, len(p))
+	a.ctr.XORKeyStream(out, p)
+	a.mac.Write(out)
+	n, err := a.w.Write(out)
+	a.n += int64(n)
+	return n, err
+}
+
+// winZipCTR: mode CTR versi WinZip, counter 128-bit little-endian dimulai dari 1
+// (berbeda dari cipher.NewCTR yang big-endian)
+type winZipCTR struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int
+}
+
+func newWinZipCTR(block cipher.Block) *winZipCTR {
+	return &winZipCTR{block: block, used: aes.BlockSize}
+}
+
+func (c *winZipCTR) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if c.used == aes.BlockSize {
+			for j := range c.counter {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.stream[:], c.counter[:])
+			c.used = 0
+		}
+		dst[i] = src[i] ^ c.stream[c.used]
+		c.used++
+	}
+}
+
+// pbkdf2SHA1 (RFC 8018). go.mod masih Go 1.23, crypto/pbkdf2 baru ada di 1.24.
+func pbkdf2SHA1(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	var out []byte
+	for block := uint32(1); len(out) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.Write(prf, binary.BigEndian, block)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iter; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
+
+func msDosTime(t time.Time) (date, clock uint16) {
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	date = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	clock = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, clock
+}
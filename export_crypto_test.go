@@ -0,0 +1,117 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPBKDF2SHA1 tests key derivation against RFC 6070 vectors.
+func TestPBKDF2SHA1(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iter, keyLen   int
+		want           string
+	}{
+		{"password", "salt", 1, 20, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"password", "salt", 4096, 20, "4b007901b765489abead49d926f721d065a429c1"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 25, "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2SHA1([]byte(tt.password), []byte(tt.salt), tt.iter, tt.keyLen))
+		if got != tt.want {
+			t.Errorf("pbkdf2SHA1(%q, %q, %d) = %s, want %s", tt.password, tt.salt, tt.iter, got, tt.want)
+		}
+	}
+}
+
+// TestAESZipRoundTrip tests that encrypted exports decrypt back to the original file.
+func TestAESZipRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	plain := []byte(strings.Repeat("email,password\nrudi@acme.com,rahasia123\n", 5000))
+	src := filepath.Join(dir, "result_acme.csv")
+	os.WriteFile(src, plain, 0o600)
+
+	password := generateExportPassword()
+	if len(password) != exportPasswordLen || password == generateExportPassword() {
+		t.Fatalf("generateExportPassword() = %q", password)
+	}
+	parts, err := encryptExportParts([]exportPart{{Path: src, Rows: 5000}}, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("plaintext part was not removed")
+	}
+	zipPath := parts[0].Path
+	if filepath.Base(zipPath) != "result_acme.zip" || parts[0].Rows != 5000 {
+		t.Errorf("parts = %+v", parts)
+	}
+
+	got, err := readAESZip(zipPath, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Error("decrypted content differs from original")
+	}
+	if _, err := readAESZip(zipPath, "salah"); err == nil {
+		t.Error("wrong password was accepted")
+	}
+
+	// Cek silang dengan implementasi lain jika tersedia (libarchive)
+	if bsdtar, err := exec.LookPath("bsdtar"); err == nil {
+		out, err := exec.Command(bsdtar, "-xOf", zipPath, "--passphrase", password).Output()
+		if err != nil || !bytes.Equal(out, plain) {
+			t.Errorf("bsdtar could not extract archive: %v", err)
+		}
+	}
+}
+
+// readAESZip decrypts the single AE-2 entry of an archive written by writeAESZipEntry.
+func readAESZip(path, password string) ([]byte, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	f := zr.File[0]
+	if f.Method != aesZipMethod || f.Flags&0x1 == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	rc, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, verifier := raw[:aesZipSaltLen], raw[aesZipSaltLen:aesZipSaltLen+2]
+	data, tag := raw[aesZipSaltLen+2:len(raw)-aesZipMACLen], raw[len(raw)-aesZipMACLen:]
+	keys := pbkdf2SHA1([]byte(password), salt, aesZipIterations, 2*aesZipKeyLen+2)
+	if !bytes.Equal(keys[2*aesZipKeyLen:], verifier) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	mac := hmac.New(sha1.New, keys[aesZipKeyLen:2*aesZipKeyLen])
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil)[:aesZipMACLen], tag) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	block, _ := aes.NewCipher(keys[:aesZipKeyLen])
+	compressed := make([]byte, len(data))
+	newWinZipCTR(block).XORKeyStream(compressed, data)
+	return io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// --- EXPORT FORMATS: CSV / JSON / NDJSON / HTML ---
+
+// CSV: header sekali di baris pertama, sel kosong jika dokumen tidak punya kolom tsb
+type csvRowWriter struct {
+	w       *csv.Writer
+	headers []string
+}
+
+func newCSVRowWriter(w io.Writer, headers []string) (rowWriter, error) {
+	cw := &csvRowWriter{w: csv.NewWriter(w), headers: headers}
+	cw.w.Write(headers)
+	return cw, nil
+}
+
+func (c *csvRowWriter) WriteRow(doc map[string]interface{}) error {
+	record := make([]string, len(c.headers))
+	for i, col := range c.headers {
+		record[i] = cellText(doc[col])
+	}
+	c.w.Write(record)
+	c.w.Flush() // Agar ukuran bagian terhitung akurat
+	return c.w.Error()
+}
+
+func (c *csvRowWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// NDJSON: satu dokumen per baris, cocok untuk jq / ingest ulang
+type ndjsonRowWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONRowWriter(w io.Writer, headers []string) (rowWriter, error) {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &ndjsonRowWriter{enc: enc}, nil
+}
+
+func (n *ndjsonRowWriter) WriteRow(doc map[string]interface{}) error {
+	return n.enc.Encode(doc)
+}
+
+func (n *ndjsonRowWriter) Close() error { return nil }
+
+// JSON: satu array per file, setiap bagian tetap JSON valid
+type jsonRowWriter struct {
+	w     io.Writer
+	enc   *json.Encoder
+	first bool
+}
+
+func newJSONRowWriter(w io.Writer, headers []string) (rowWriter, error) {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	_, err := io.WriteString(w, "[\n")
+	return &jsonRowWriter{w: w, enc: enc, first: true}, err
+}
+
+func (j *jsonRowWriter) WriteRow(doc map[string]interface{}) error {
+	if !j.first {
+		if _, err := io.WriteString(j.w, ","); err != nil {
+			return err
+		}
+	}
+	j.first = false
+	return j.enc.Encode(doc) // Encode menambah "\n" sendiri
+}
+
+func (j *jsonRowWriter) Close() error {
+	_, err := io.WriteString(j.w, "]\n")
+	return err
+}
+
+// --- HTML REPORT ---
+// Satu file mandiri (CSS inline, tanpa JS/asset luar): ringkasan jumlah per source
+// lalu satu tabel per source. Baris per tabel dibatasi agar file tetap bisa dibuka browser.
+
+const htmlMaxRowsPerSource = 1000
+
+type htmlExporter struct{}
+
+func (htmlExporter) Ext() string { return "html" }
+
+func (htmlExporter) Export(sp *exportSpool, baseName string, limit int64) ([]exportPart, error) {
+	groups, err := sp.Groups(exportMaxGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(sp.dir, baseName+".html")
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := bufio.NewWriter(f)
+	cw := &countingWriter{w: buf}
+	esc := html.EscapeString
+
+	fmt.Fprintf(cw, `<!DOCTYPE html>
+<html lang="id"><head><meta charset="utf-8"><title>Laporan: %s</title>
+<style>
+body{font-family:system-ui,sans-serif;margin:2em;color:#222}
+table{border-collapse:collapse;margin:1em 0;font-size:13px}
+th,td{border:1px solid #ccc;padding:4px 8px;text-align:left;vertical-align:top}
+th{background:#f0f0f0}
+.note{color:#a60}
+</style></head><body>
+<h1>Laporan Kebocoran Data</h1>
+<table>
+<tr><th>Keyword</th><td>%s</td></tr>
+<tr><th>Dibuat</th><td>%s</td></tr>
+<tr><th>Total Hit</th><td>%d</td></tr>
+<tr><th>Data Diexport</th><td>%d</td></tr>
+<tr><th>Jumlah Source</th><td>%d</td></tr>
+</table>
+`, esc(sp.Keyword), esc(sp.Keyword), time.Now().Format("2006-01-02 15:04:05"), sp.Total, sp.Rows, len(sp.sources))
+	if sp.Rows < sp.Total {
+		fmt.Fprintf(cw, "<p class=\"note\">⚠️ Hanya %d dari %d data yang berhasil diambil.</p>\n", sp.Rows, sp.Total)
+	}
+
+	io.WriteString(cw, "<h2>Ringkasan per Source</h2>\n<table>\n<tr><th>Source</th><th>Jumlah</th></tr>\n")
+	for i, g := range groups {
+		fmt.Fprintf(cw, "<tr><td><a href=\"#src-%d\">%s</a></td><td>%d</td></tr>\n", i, esc(g.Source), g.Rows)
+	}
+	io.WriteString(cw, "</table>\n")
+
+	for i, g := range groups {
+		fmt.Fprintf(cw, "<h2 id=\"src-%d\">%s (%d)</h2>\n<table>\n<tr>", i, esc(g.Source), g.Rows)
+		for _, h := range g.Headers {
+			fmt.Fprintf(cw, "<th>%s</th>", esc(h))
+		}
+		io.WriteString(cw, "</tr>\n")
+
+		n := 0
+		err := g.Each(func(doc map[string]interface{}) error {
+			if n >= htmlMaxRowsPerSource {
+				return errStopIteration
+			}
+			n++
+			io.WriteString(cw, "<tr>")
+			for _, h := range g.Headers {
+				fmt.Fprintf(cw, "<td>%s</td>", esc(cellText(doc[h])))
+			}
+			_, err := io.WriteString(cw, "</tr>\n")
+			return err
+		})
+		if err != nil && err != errStopIteration {
+			return nil, err
+		}
+		io.WriteString(cw, "</table>\n")
+		if g.Rows > n {
+			fmt.Fprintf(cw, "<p class=\"note\">… %d baris lainnya tidak ditampilkan, gunakan /export csv untuk data lengkap.</p>\n", g.Rows-n)
+		}
+		if cw.n > limit {
+			return nil, errExportTooLarge
+		}
+	}
+	io.WriteString(cw, "</body></html>\n")
+	if err := buf.Flush(); err != nil {
+		return nil, err
+	}
+	return []exportPart{{Path: path, Rows: sp.Rows}}, f.Close()
+}
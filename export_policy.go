@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// --- EXPORT POLICY ---
+// Kolom sensitif (lihat isSensitive) pada file export diperlakukan sesuai role peminta:
+// disensor, di-hash (masih bisa dicocokkan antar data tanpa membuka nilainya) atau apa adanya.
+// Hash memakai HMAC dengan kunci yang hanya ada di server (SystemConfig.ExportHashKey): SHA-256 biasa
+// dari password umum bisa langsung dibalik dengan kamus / rainbow table.
+
+type SensitivePolicy string
+
+const (
+	SensitiveMask    SensitivePolicy = "mask"
+	SensitiveHash    SensitivePolicy = "hash"
+	SensitiveInclude SensitivePolicy = "include"
+)
+
+// Dipakai jika admin belum mengatur policy role tersebut
+var defaultExportPolicy = map[Role]SensitivePolicy{
+	roleOwner:     SensitiveInclude,
+	roleAdmin:     SensitiveInclude,
+	roleModerator: SensitiveMask,
+	roleAnalyst:   SensitiveHash,
+	roleUser:      SensitiveMask,
+}
+
+// Label untuk pesan bot
+var sensitivePolicyLabels = map[SensitivePolicy]string{
+	SensitiveMask:    "disensor",
+	SensitiveHash:    "di-hash (HMAC-SHA256)",
+	SensitiveInclude: "lengkap",
+}
+
+func parseSensitivePolicy(s string) (SensitivePolicy, bool) {
+	p := SensitivePolicy(strings.ToLower(s))
+	_, ok := sensitivePolicyLabels[p]
+	return p, ok
+}
+
+// exportPolicyFor: policy tersimpan di config, fallback ke default, role tak dikenal = mask
+func exportPolicyFor(config *SystemConfig, role Role) SensitivePolicy {
+	if p, ok := config.ExportPolicy[role]; ok {
+		return p
+	}
+	if p, ok := defaultExportPolicy[role]; ok {
+		return p
+	}
+	return SensitiveMask
+}
+
+// Apply mengubah nilai kolom sensitif di dokumen (dokumen diubah langsung).
+// Tanpa hashKey policy hash jatuh ke mask, bukan ke hash tanpa kunci.
+func (p SensitivePolicy) Apply(doc map[string]interface{}, hashKey []byte) {
+	if p == SensitiveInclude {
+		return
+	}
+	if len(hashKey) == 0 {
+		p = SensitiveMask
+	}
+	for k, v := range doc {
+		if !isSensitive(k) || v == nil {
+			continue
+		}
+		if p == SensitiveHash {
+			doc[k] = "hmac:" + hmacFingerprint(hashKey, fmt.Sprintf("%v", v))
+		} else {
+			doc[k] = "********"
+		}
+	}
+}
+
+// exportHashKey: kunci HMAC dari config, nil jika belum dibuat (lihat ensureExportHashKey)
+func (c SystemConfig) exportHashKey() []byte {
+	key, err := hex.DecodeString(c.ExportHashKey)
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+// ensureExportHashKey membuat kunci HMAC sekali lalu menyimpannya di config, sehingga nilai
+// yang di-hash tetap sama antar export & setelah restart
+func ensureExportHashKey(store Store, globalConfig *LiveConfig) {
+	if len(globalConfig.Get().exportHashKey()) > 0 {
+		return
+	}
+	globalConfig.Update(store, func(c *SystemConfig) {
+		if len(c.exportHashKey()) == 0 {
+			c.ExportHashKey = generateSecretKey()
+		}
+	})
+	log.Println("🔑 Kunci HMAC export policy dibuat")
+}
+
+// ExportRequest: semua opsi satu permintaan export
+type ExportRequest struct {
+	Format    string
+	Keyword   string
+	Sensitive SensitivePolicy
+	HashKey   string // Kunci HMAC (byte mentah) untuk policy hash
+	Encrypt   bool   // Kirim sebagai zip AES + password sekali pakai
+	UserID    int64  // Peminta (pesan callback berasal dari bot, jadi tidak diambil dari msg.From)
+	Role      Role   // Role peminta, menentukan kuota export (lihat quota.go)
+}
+
+// withPolicy melengkapi request dengan policy role peminta & enkripsi wajib dari config
+func (req ExportRequest) withPolicy(ctx *CommandContext, config *SystemConfig) ExportRequest {
+	return req.forUser(ctx.User.ID, ctx.Role, config)
+}
+
+// forUser: sama dengan withPolicy untuk peminta di luar Telegram (REST API)
+func (req ExportRequest) forUser(userID int64, role Role, config *SystemConfig) ExportRequest {
+	req.UserID = userID
+	req.Role = role
+	req.Sensitive = exportPolicyFor(config, role)
+	req.HashKey = string(config.exportHashKey())
+	req.Encrypt = req.Encrypt || config.EncryptExports
+	return req
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestSpoolSearchResults tests that exports page past the old 1000-row cap.
+func TestSpoolSearchResults(t *testing.T) {
+	store := NewMemoryStore()
+	n := exportPageSize*2 + 37
+	for i := 0; i < n; i++ {
+		doc := map[string]interface{}{"leak_source": "big.csv", "email": fmt.Sprintf("user%d@acme.com", i), "full_text": "acme"}
+		if i%2 == 0 {
+			doc["phone"] = 628123000000 + i
+		}
+		store.IndexDocument(doc, fmt.Sprint(i))
+	}
+	store.IndexDocument(map[string]interface{}{"leak_source": "other.csv", "email": "x@other.com", "full_text": "other"}, "other")
+
+	pages := 0
+	sp, err := spoolSearchResults(store, "acme", SensitiveInclude, nil, 0, func(rows, total int) { pages++ })
+	if err != nil {
+		t.Fatalf("spoolSearchResults() error = %v", err)
+	}
+	defer sp.Close()
+
+	if sp.Rows != n || sp.Total != n {
+		t.Errorf("Rows/Total = %d/%d, want %d/%d", sp.Rows, sp.Total, n, n)
+	}
+	if pages != 3 {
+		t.Errorf("onPage called %d times, want 3", pages)
+	}
+	if got := strings.Join(sp.Headers(), ","); got != "email,leak_source,phone" {
+		t.Errorf("Headers() = %s", got)
+	}
+
+	// Angka besar tidak boleh berubah jadi notasi ilmiah
+	parts, err := exporters["csv"].Export(sp, "result_acme", exportPartLimit)
+	if err != nil || len(parts) != 1 {
+		t.Fatalf("Export() = %d parts, %v", len(parts), err)
+	}
+	rows := readCSV(t, parts[0].Path)
+	if len(rows) != n+1 || rows[1][2] != "628123000000" {
+		t.Errorf("CSV has %d rows, first phone %q", len(rows), rows[1][2])
+	}
+
+	// Sisa kuota baris membatasi jumlah data yang diambil
+	capped, err := spoolSearchResults(store, "acme", SensitiveInclude, nil, exportPageSize+5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer capped.Close()
+	if capped.Rows != exportPageSize+5 || !capped.Capped || capped.Total != n {
+		t.Errorf("capped spool Rows/Total/Capped = %d/%d/%v, want %d/%d/true", capped.Rows, capped.Total, capped.Capped, exportPageSize+5, n)
+	}
+}
+
+// TestExportCSVSplit tests that large exports are split with a header in every part.
+func TestExportCSVSplit(t *testing.T) {
+	store := NewMemoryStore()
+	for i := 0; i < 500; i++ {
+		store.IndexDocument(map[string]interface{}{"email": fmt.Sprintf("user%d@acme.com", i), "full_text": "acme"}, fmt.Sprint(i))
+	}
+	sp, err := spoolSearchResults(store, "acme", SensitiveInclude, nil, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+
+	parts, err := exporters["csv"].Export(sp, "result_acme", 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) < 2 {
+		t.Fatalf("got %d parts, want split", len(parts))
+	}
+
+	total := 0
+	for _, part := range parts {
+		rows := readCSV(t, part.Path)
+		if rows[0][0] != "email" {
+			t.Errorf("%s header = %v", part.Path, rows[0])
+		}
+		if len(rows)-1 != part.Rows {
+			t.Errorf("%s has %d rows, Rows = %d", part.Path, len(rows)-1, part.Rows)
+		}
+		if info, _ := os.Stat(part.Path); info.Size() > 2048+100 {
+			t.Errorf("%s size %d exceeds limit", part.Path, info.Size())
+		}
+		total += part.Rows
+	}
+	if total != 500 {
+		t.Errorf("parts hold %d rows, want 500", total)
+	}
+}
+
+// TestExportFormats tests JSON, NDJSON, XLSX and HTML output on a multi-source result.
+func TestExportFormats(t *testing.T) {
+	store := NewMemoryStore()
+	store.IndexDocument(map[string]interface{}{"leak_source": "a.csv", "email": "rudi@acme.com", "password": "<b>x</b>", "full_text": "acme"}, "1")
+	store.IndexDocument(map[string]interface{}{"leak_source": "a.csv", "email": "sudi@acme.com", "full_text": "acme"}, "2")
+	store.IndexDocument(map[string]interface{}{"leak_source": "dump/[old]:users.sql", "username": "budi", "phone": "0812", "full_text": "acme"}, "3")
+
+	sp, err := spoolSearchResults(store, "acme", SensitiveInclude, nil, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+
+	export := func(format string) []byte {
+		t.Helper()
+		parts, err := exporters[format].Export(sp, "result_acme", exportPartLimit)
+		if err != nil || len(parts) != 1 {
+			t.Fatalf("%s Export() = %d parts, %v", format, len(parts), err)
+		}
+		data, err := os.ReadFile(parts[0].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	var docs []map[string]interface{}
+	if err := json.Unmarshal(export("json"), &docs); err != nil || len(docs) != 3 {
+		t.Fatalf("json export = %d docs, %v", len(docs), err)
+	}
+	if _, ok := docs[0]["full_text"]; ok {
+		t.Error("json export contains full_text")
+	}
+	if lines := strings.Split(strings.TrimSpace(string(export("ndjson"))), "\n"); len(lines) != 3 {
+		t.Errorf("ndjson export has %d lines, want 3", len(lines))
+	}
+
+	// XLSX: satu sheet per source dengan kolom milik source itu saja
+	data := export("xlsx")
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(body)
+		if err := xml.Unmarshal(body, new(struct{})); err != nil {
+			t.Errorf("%s is not well-formed XML: %v", f.Name, err)
+		}
+	}
+	book := files["xl/workbook.xml"]
+	if !strings.Contains(book, `name="a.csv"`) || !strings.Contains(book, `name="dump__old__users.sql"`) {
+		t.Errorf("workbook sheets = %s", book)
+	}
+	if sheet := files["xl/worksheets/sheet1.xml"]; strings.Count(sheet, "<row>") != 3 || !strings.Contains(sheet, "&lt;b&gt;x&lt;/b&gt;") {
+		t.Errorf("sheet1 = %s", sheet)
+	}
+	if sheet := files["xl/worksheets/sheet2.xml"]; !strings.Contains(sheet, "0812") || strings.Contains(sheet, "email") {
+		t.Errorf("sheet2 = %s", sheet)
+	}
+
+	report := string(export("html"))
+	if strings.Contains(report, "<b>x</b>") || !strings.Contains(report, "&lt;b&gt;x&lt;/b&gt;") {
+		t.Error("html report does not escape values")
+	}
+	if !strings.Contains(report, ">a.csv</a></td><td>2</td>") {
+		t.Error("html report is missing per-source summary")
+	}
+}
+
+// TestParseExportArgs tests the optional format and secure prefixes of /export.
+func TestParseExportArgs(t *testing.T) {
+	tests := []struct {
+		args string
+		want ExportRequest
+	}{
+		{"rudi", ExportRequest{Format: "csv", Keyword: "rudi"}},
+		{"xlsx rudi hartono", ExportRequest{Format: "xlsx", Keyword: "rudi hartono"}},
+		{"JSON email:a@b.com", ExportRequest{Format: "json", Keyword: "email:a@b.com"}},
+		{"secure xlsx rudi", ExportRequest{Format: "xlsx", Keyword: "rudi", Encrypt: true}},
+		{"html secure", ExportRequest{Format: "html", Keyword: "secure"}},
+		{"html", ExportRequest{Format: "csv", Keyword: "html"}},
+		{"pdf rudi", ExportRequest{Format: "csv", Keyword: "pdf rudi"}},
+	}
+	for _, tt := range tests {
+		if got := parseExportArgs(tt.args); got != tt.want {
+			t.Errorf("parseExportArgs(%q) = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestExportSensitivePolicy tests that sensitive fields are masked, hashed or kept per role.
+func TestExportSensitivePolicy(t *testing.T) {
+	store := NewMemoryStore()
+	store.IndexDocument(map[string]interface{}{"email": "rudi@acme.com", "password": "rahasia123", "full_text": "acme"}, "1")
+
+	config := &SystemConfig{ExportPolicy: map[Role]SensitivePolicy{roleUser: SensitiveHash}}
+	if got := exportPolicyFor(config, roleAdmin); got != SensitiveInclude {
+		t.Errorf("admin default policy = %s, want include", got)
+	}
+	if got := exportPolicyFor(config, "unknown"); got != SensitiveMask {
+		t.Errorf("unknown role policy = %s, want mask", got)
+	}
+
+	key := []byte("server-secret")
+	want := map[SensitivePolicy]string{
+		SensitiveMask:    "********",
+		SensitiveHash:    "hmac:" + hmacFingerprint(key, "rahasia123"),
+		SensitiveInclude: "rahasia123",
+	}
+	for policy, password := range want {
+		sp, err := spoolSearchResults(store, "acme", policy, key, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sp.Each(func(doc map[string]interface{}) error {
+			if doc["password"] != password || doc["email"] != "rudi@acme.com" {
+				t.Errorf("%s: doc = %v", policy, doc)
+			}
+			return nil
+		})
+		sp.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestExportHashPolicyKeyed tests that hashed values cannot be looked up with a plain SHA-256 table.
+func TestExportHashPolicyKeyed(t *testing.T) {
+	hash := func(key []byte) interface{} {
+		doc := map[string]interface{}{"password": "rahasia123"}
+		SensitiveHash.Apply(doc, key)
+		return doc["password"]
+	}
+	got := hash([]byte("server-secret"))
+	if s, _ := got.(string); strings.Contains(s, generateFingerprint("rahasia123")) || !strings.HasPrefix(s, "hmac:") {
+		t.Errorf("hashed password = %v, want keyed HMAC, not raw SHA-256", got)
+	}
+	if got != hash([]byte("server-secret")) || got == hash([]byte("other-secret")) {
+		t.Error("hash must be stable per key and differ across keys")
+	}
+	if got := hash(nil); got != "********" {
+		t.Errorf("hash without key = %v, want masked", got)
+	}
+
+	// Kunci dibuat sekali lalu dipakai ulang
+	store := NewMemoryStore()
+	config := NewLiveConfig(SystemConfig{})
+	ensureExportHashKey(store, config)
+	first := config.Get().ExportHashKey
+	ensureExportHashKey(store, NewLiveConfig(store.GetSystemConfig()))
+	if len(config.Get().exportHashKey()) != 32 || store.GetSystemConfig().ExportHashKey != first {
+		t.Errorf("hash key = %q, stored %q, want one persisted 32-byte key", first, store.GetSystemConfig().ExportHashKey)
+	}
+}
+
+func readCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- XLSX EXPORT ---
+// Writer SpreadsheetML minimal (tanpa library luar): satu sheet per leak_source,
+// semua sel berupa inline string agar nomor HP / ID tidak kehilangan nol di depan.
+
+const (
+	xlsxMaxRows      = 1048575 // Batas Excel 1.048.576 baris termasuk header
+	xlsxMaxCellChars = 32767
+	xlsxMaxSheetName = 31
+)
+
+const (
+	xlsxNS    = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	xlsxRelNS = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	xlsxPkgNS = "http://schemas.openxmlformats.org/package/2006/relationships"
+	xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+)
+
+type xlsxExporter struct{}
+
+func (xlsxExporter) Ext() string { return "xlsx" }
+
+func (xlsxExporter) Export(sp *exportSpool, baseName string, limit int64) ([]exportPart, error) {
+	groups, err := sp.Groups(exportMaxGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(sp.dir, baseName+".xlsx")
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := bufio.NewWriter(f)
+	cw := &countingWriter{w: buf}
+	zw := zip.NewWriter(cw)
+
+	var sheets []string
+	usedNames := make(map[string]bool)
+	for _, g := range groups {
+		var sw *xlsxSheetWriter
+		rows := 0
+		err := g.Each(func(doc map[string]interface{}) error {
+			// Sheet baru untuk grup baru atau jika sheet sebelumnya sudah penuh
+			if sw == nil || rows >= xlsxMaxRows {
+				if sw != nil {
+					if err := sw.Close(); err != nil {
+						return err
+					}
+				}
+				name := xlsxSheetName(g.Source, len(sheets)+1, usedNames)
+				sheets = append(sheets, name)
+				w, err := zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(sheets)))
+				if err != nil {
+					return err
+				}
+				sw = newXLSXSheetWriter(w)
+				sw.WriteRow(g.Headers)
+				rows = 0
+			}
+			record := make([]string, len(g.Headers))
+			for i, col := range g.Headers {
+				record[i] = cellText(doc[col])
+			}
+			rows++
+			if cw.n > limit {
+				return errExportTooLarge
+			}
+			return sw.WriteRow(record)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if sw != nil {
+			if err := sw.Close(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(sheets) == 0 {
+		// Workbook wajib punya minimal satu sheet
+		sheets = append(sheets, "Sheet1")
+		w, _ := zw.Create("xl/worksheets/sheet1.xml")
+		sw := newXLSXSheetWriter(w)
+		sw.WriteRow(sp.Headers())
+		sw.Close()
+	}
+
+	if err := writeXLSXPackage(zw, sheets); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if err := buf.Flush(); err != nil {
+		return nil, err
+	}
+	if cw.n > limit {
+		return nil, errExportTooLarge
+	}
+	return []exportPart{{Path: path, Rows: sp.Rows}}, f.Close()
+}
+
+// writeXLSXPackage menulis file pendukung workbook (content types, relasi, daftar sheet)
+func writeXLSXPackage(zw *zip.Writer, sheets []string) error {
+	var types, rels, book strings.Builder
+
+	types.WriteString(xmlHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	rels.WriteString(xmlHeader + `<Relationships xmlns="` + xlsxPkgNS + `">`)
+	book.WriteString(xmlHeader + `<workbook xmlns="` + xlsxNS + `" xmlns:r="` + xlsxRelNS + `"><sheets>`)
+
+	for i, name := range sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="%s/worksheet" Target="worksheets/sheet%d.xml"/>`, n, xlsxRelNS, n)
+		fmt.Fprintf(&book, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(name), n, n)
+	}
+	types.WriteString(`</Types>`)
+	rels.WriteString(`</Relationships>`)
+	book.WriteString(`</sheets></workbook>`)
+
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", types.String()},
+		{"_rels/.rels", xmlHeader + `<Relationships xmlns="` + xlsxPkgNS + `"><Relationship Id="rId1" Type="` + xlsxRelNS + `/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", book.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+	}
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, file.body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type xlsxSheetWriter struct {
+	w io.Writer
+}
+
+func newXLSXSheetWriter(w io.Writer) *xlsxSheetWriter {
+	io.WriteString(w, xmlHeader+`<worksheet xmlns="`+xlsxNS+`"><sheetData>`)
+	return &xlsxSheetWriter{w: w}
+}
+
+func (s *xlsxSheetWriter) WriteRow(cells []string) error {
+	var sb strings.Builder
+	sb.WriteString("<row>")
+	for _, cell := range cells {
+		if cell == "" {
+			sb.WriteString("<c/>")
+			continue
+		}
+		if utf8.RuneCountInString(cell) > xlsxMaxCellChars {
+			cell = string([]rune(cell)[:xlsxMaxCellChars])
+		}
+		sb.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		sb.WriteString(xmlEscape(cell))
+		sb.WriteString("</t></is></c>")
+	}
+	sb.WriteString("</row>")
+	_, err := io.WriteString(s.w, sb.String())
+	return err
+}
+
+func (s *xlsxSheetWriter) Close() error {
+	_, err := io.WriteString(s.w, "</sheetData></worksheet>")
+	return err
+}
+
+// xmlEscape juga mengganti karakter kontrol yang tidak valid di XML dengan U+FFFD
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// xlsxSheetName: maksimal 31 karakter, tanpa []:*?/\, unik (Excel tidak membedakan huruf besar/kecil)
+func xlsxSheetName(source string, n int, used map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, source)
+	name = strings.Trim(name, "'")
+	if name == "" {
+		name = fmt.Sprintf("Sheet%d", n)
+	}
+
+	base := name
+	for i := 2; ; i++ {
+		if runes := []rune(name); len(runes) > xlsxMaxSheetName {
+			name = string(runes[:xlsxMaxSheetName])
+		}
+		if !used[strings.ToLower(name)] {
+			break
+		}
+		suffix := fmt.Sprintf(" (%d)", i)
+		runes := []rune(base)
+		if len(runes)+len(suffix) > xlsxMaxSheetName {
+			runes = runes[:xlsxMaxSheetName-len(suffix)]
+		}
+		name = string(runes) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --- FIELD ALIAS NORMALIZATION ---
+// Setiap sumber memakai nama kolom sendiri (Email, EMAIL, e-mail, user_email, mail). Sebelum di-index,
+// BulkIngester menyalin nilai kolom yang dikenal ke field kanonik di bawah ini. Kolom asli tetap disimpan,
+// field kanonik yang sudah terisi tidak pernah ditimpa. domain diturunkan dari email.
+// Query `email:...` (dan field kanonik lain) mencari di field kanonik, record lama yang belum punya field
+// kanonik tetap dicocokkan dengan kolom yang mirip seperti sebelumnya (lihat QueryNode.toQuery).
+
+var canonicalFields = []string{"email", "username", "password", "password_hash", "phone", "ip", "name", "domain"}
+
+// Varian nama kolom dalam bentuk aliasKey (huruf kecil, tanpa pemisah)
+var defaultAliasList = map[string][]string{
+	"email":         {"email", "mail", "emailaddress", "emailaddr", "useremail", "usermail", "correo", "courriel"},
+	"username":      {"username", "user", "uname", "login", "loginname", "userlogin", "nickname", "nick", "screenname"},
+	"password":      {"password", "pass", "passwd", "pwd", "pw", "passwort", "sandi", "katasandi", "plaintext", "plainpassword", "userpassword", "userpass"},
+	"password_hash": {"passwordhash", "hash", "passhash", "pwdhash", "hashedpassword", "md5", "sha1", "sha256", "bcrypt"},
+	"phone":         {"phone", "phonenumber", "phoneno", "mobile", "mobilephone", "mobilenumber", "msisdn", "cell", "cellphone", "tel", "telp", "telepon", "telephone", "hp", "nohp", "nomorhp", "handphone", "whatsapp"},
+	"ip":            {"ip", "ipaddress", "ipaddr", "lastip", "loginip", "regip", "registrationip", "userip", "clientip", "remoteip"},
+	"name":          {"name", "fullname", "nama", "namalengkap", "displayname", "realname"},
+	"domain":        {"domain", "emaildomain"},
+}
+
+// FieldAliases: aliasKey(nama kolom) -> field kanonik
+type FieldAliases map[string]string
+
+func defaultFieldAliases() FieldAliases {
+	fa := make(FieldAliases)
+	for canonical, aliases := range defaultAliasList {
+		for _, a := range aliases {
+			fa[a] = canonical
+		}
+	}
+	return fa
+}
+
+// aliasKey: "E-Mail" / "user_email" / "EMAIL" -> "email" / "useremail" / "email"
+func aliasKey(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func isCanonicalField(name string) bool {
+	for _, f := range canonicalFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFieldAliases menambahkan alias dari FIELD_ALIASES ke salinan base.
+// Format: "email:correo_e,contact; phone:no_wa" (kanonik:alias,alias; ...)
+func parseFieldAliases(spec string, base FieldAliases) (FieldAliases, error) {
+	fa := make(FieldAliases, len(base))
+	for k, v := range base {
+		fa[k] = v
+	}
+	for _, entry := range strings.Split(spec, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		canonical, aliases, ok := strings.Cut(entry, ":")
+		canonical = strings.ToLower(strings.TrimSpace(canonical))
+		if !ok || !isCanonicalField(canonical) {
+			return base, fmt.Errorf("entry %q: field kanonik harus salah satu dari %s", strings.TrimSpace(entry), strings.Join(canonicalFields, ", "))
+		}
+		for _, a := range strings.Split(aliases, ",") {
+			if key := aliasKey(a); key != "" {
+				fa[key] = canonical
+			}
+		}
+	}
+	return fa, nil
+}
+
+// Normalize mengisi field kanonik dari kolom yang dikenal, kolom asli tidak diubah
+func (fa FieldAliases) Normalize(doc map[string]interface{}) {
+	// Urutan kolom tetap agar hasil sama setiap kali (kolom pertama yang berisi menang)
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		canonical, ok := fa[aliasKey(k)]
+		if !ok || canonical == k || aliasValue(doc[canonical]) != "" {
+			continue
+		}
+		if val := aliasValue(doc[k]); val != "" {
+			doc[canonical] = val
+		}
+	}
+
+	// first_name + last_name -> name
+	if aliasValue(doc["name"]) == "" {
+		var first, last string
+		for _, k := range keys {
+			switch aliasKey(k) {
+			case "firstname", "namadepan":
+				first = aliasValue(doc[k])
+			case "lastname", "namabelakang":
+				last = aliasValue(doc[k])
+			}
+		}
+		if full := strings.TrimSpace(first + " " + last); full != "" {
+			doc["name"] = full
+		}
+	}
+
+	if aliasValue(doc["domain"]) == "" {
+		if email := aliasValue(doc["email"]); strings.Contains(email, "@") {
+			if domain := normalizeDomain(email); domain != "" {
+				doc["domain"] = domain
+			}
+		}
+	}
+}
+
+// aliasValue: nilai kolom sebagai teks, "" untuk kosong / null / nested
+func aliasValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return strings.TrimSpace(val)
+	case float64:
+		// Angka dari JSON (misal nomor HP) jangan sampai jadi notasi eksponen
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case json.Number:
+		return val.String()
+	case int, int64:
+		return fmt.Sprintf("%d", val)
+	}
+	return ""
+}
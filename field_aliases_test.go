@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestFieldAliasesNormalize tests canonical field mapping, precedence and domain derivation.
+func TestFieldAliasesNormalize(t *testing.T) {
+	fa := defaultFieldAliases()
+	doc := map[string]interface{}{
+		"E-Mail":     "Rudi@ACME.com",
+		"user_email": "other@x.com",
+		"Passwd":     "rahasia",
+		"MSISDN":     6281234567890.0,
+		"first_name": "Rudi",
+		"last_name":  "Hartono",
+		"ip_address": "10.0.0.1",
+		"zip":        "12345",
+		"Hash":       "",
+	}
+	fa.Normalize(doc)
+
+	want := map[string]interface{}{
+		"email":    "Rudi@ACME.com", // "E-Mail" terurut sebelum "user_email"
+		"password": "rahasia",
+		"phone":    "6281234567890",
+		"name":     "Rudi Hartono",
+		"ip":       "10.0.0.1",
+		"domain":   "acme.com",
+		"E-Mail":   "Rudi@ACME.com",
+		"Passwd":   "rahasia",
+	}
+	for k, v := range want {
+		if doc[k] != v {
+			t.Errorf("doc[%q] = %v, want %v", k, doc[k], v)
+		}
+	}
+	if _, ok := doc["password_hash"]; ok {
+		t.Errorf("empty Hash column should not create password_hash: %v", doc["password_hash"])
+	}
+
+	// Field kanonik yang sudah ada (misal dari parser combo) tidak ditimpa
+	combo := map[string]interface{}{"email": "a@b.co", "mail": "c@d.co", "domain": "b.co"}
+	fa.Normalize(combo)
+	if combo["email"] != "a@b.co" || combo["domain"] != "b.co" {
+		t.Errorf("existing canonical fields overwritten: %v", combo)
+	}
+}
+
+func TestParseFieldAliases(t *testing.T) {
+	fa, err := parseFieldAliases("email: Correo_E , contact ; phone:no_wa", defaultFieldAliases())
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]interface{}{"CORREO-E": "x@y.com", "No WA": "0812"}
+	fa.Normalize(doc)
+	if doc["email"] != "x@y.com" || doc["phone"] != "0812" || doc["domain"] != "y.com" {
+		t.Errorf("custom aliases not applied: %v", doc)
+	}
+
+	if _, err := parseFieldAliases("mail:foo", defaultFieldAliases()); err == nil || !strings.Contains(err.Error(), "kanonik") {
+		t.Errorf("unknown canonical field error = %v", err)
+	}
+}
+
+// TestIngestNormalizesFields tests that ingested records are searchable through canonical fields.
+func TestIngestNormalizesFields(t *testing.T) {
+	store := NewMemoryStore()
+	input := "User_Email,Pass\nceo@acme.com,hunter2\n"
+	p := NewIngestProgress("dump.csv", int64(len(input)))
+	ing := NewBulkIngester(store, defaultBulkConfig())
+	ingestStreamCSV(p.Reader(strings.NewReader(input)), "dump.csv", ing, p)
+	ing.Close()
+
+	result, _ := store.SearchBreaches("domain:acme.com AND password:hunter2", 10)
+	if result.Hits.Total.Value != 1 {
+		t.Fatalf("canonical search = %d hits, want 1", result.Hits.Total.Value)
+	}
+	src := result.Hits.Hits[0].Source
+	if src["User_Email"] != "ceo@acme.com" || src["email"] != "ceo@acme.com" || src["upload_date"] == nil {
+		t.Errorf("stored record = %v, want original and canonical columns", src)
+	}
+}
+
+// TestCanonicalFieldFallback tests that records indexed before normalization are still found
+// through similar columns, while normalized records only match their canonical field.
+func TestCanonicalFieldFallback(t *testing.T) {
+	store := NewMemoryStore()
+	store.IndexDocument(map[string]interface{}{"User_Email": "old@acme.com"}, "old")
+	store.IndexDocument(map[string]interface{}{"email": "ceo@other.org", "backup_email": "ceo@acme.com"}, "new")
+
+	result, _ := store.SearchBreaches("email:acme.com", 10)
+	if result.Hits.Total.Value != 1 || result.Hits.Hits[0].ID != "old" {
+		t.Errorf("email:acme.com = %+v, want only the legacy record", result.Hits.Hits)
+	}
+	if result, _ = store.SearchBreaches("email:other.org", 10); result.Hits.Total.Value != 1 {
+		t.Errorf("email:other.org = %d hits, want the normalized record", result.Hits.Total.Value)
+	}
+}
@@ -7,27 +7,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/elastic/go-elasticsearch/v9"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-func handleAuditLog(bot *tgbotapi.BotAPI, chatID int64, es *elasticsearch.Client, keyword string) {
+func handleAuditLog(bot *tgbotapi.BotAPI, chatID int64, store Store, keyword string) {
 	bot.Send(tgbotapi.NewMessage(chatID, "🕵️‍♂️ _Mengaudit Log Aktivitas..._"))
 
-	queryBody := fmt.Sprintf(`{
-		"query": {
-			"multi_match": { "query": "%s", "fields": ["username", "first_name", "last_name", "query_content"], "fuzziness": "AUTO" }
-		},
-		"sort": [ { "timestamp": "desc" } ]
-	}`, keyword)
-
-	result, err := executeSearch(es, "user_logs", queryBody, 20)
+	result, err := store.SearchActivity(keyword, 20)
 	if err != nil || len(result.Hits.Hits) == 0 {
 		bot.Send(tgbotapi.NewMessage(chatID, "❌ Data log tidak ditemukan."))
 		return
@@ -61,32 +54,168 @@ func handleAuditLog(bot *tgbotapi.BotAPI, chatID int64, es *elasticsearch.Client
 
 // --- ACCESS CONTROL HANDLERS (ADMIN) ---
 
-func handleAccessControl(bot *tgbotapi.BotAPI, chatID int64, es *elasticsearch.Client, command string) {
+// /genkey [jumlah] [durasi] [uses:N] [tier:nama] [valid:durasi]
+func handleGenKey(ctx *CommandContext) {
+	now := time.Now()
+	template, count, err := parseGenKeyArgs(ctx.Args, now)
+	if err != nil {
+		ctx.Reply("⚠️ " + err.Error())
+		ctx.ReplyUsage()
+		return
+	}
+	template.CreatedBy = strconv.FormatInt(ctx.User.ID, 10)
+
+	// Key acak 5 karakter: hindari bentrok dengan batch ini maupun key lama
+	keys := make([]AccessKey, 0, count)
+	seen := make(map[string]bool, count)
+	for len(keys) < count {
+		key := template
+		key.Key = generateInviteKey()
+		if seen[key.Key] {
+			continue
+		}
+		if _, exists := ctx.Store.GetAccessKey(key.Key); exists {
+			continue
+		}
+		seen[key.Key] = true
+		ctx.Store.SaveAccessKey(key)
+		keys = append(keys, key)
+	}
+	ctx.Store.LogActivity(ctx.User, "GENKEY", fmt.Sprintf("%d key %s x%d %s", count, formatAccessDuration(template.Duration), template.MaxUses, template.Tier))
+
+	summary := fmt.Sprintf("⏳ Akses: %s\n👥 Pemakaian: %d user/key\n🏷 Tier: %s\n📅 Key berlaku s/d: %s",
+		formatAccessDuration(template.Duration), template.MaxUses, template.Tier, formatExpiry(template.ExpiresAt))
+
+	if count == 1 {
+		key := keys[0].Key
+		reply := tgbotapi.NewMessage(ctx.ChatID, fmt.Sprintf("🎟 **NEW ACCESS KEY**\nKey: `%s`\n%s\n\nBerikan key ini ke user. Gunakan `/redeem %s`", key, summary, key))
+		reply.ParseMode = "Markdown"
+		ctx.Bot.Send(reply)
+		return
+	}
+
+	fileName := fmt.Sprintf("keys_%s.csv", now.Format("20060102_150405"))
+	docMsg := tgbotapi.NewDocument(ctx.ChatID, tgbotapi.FileBytes{Name: fileName, Bytes: accessKeysCSV(keys, now)})
+	docMsg.Caption = fmt.Sprintf("🎟 **%d ACCESS KEY DIBUAT**\n%s", count, summary)
+	docMsg.ParseMode = "Markdown"
+	ctx.Bot.Send(docMsg)
+}
+
+// Jumlah key yang ditampilkan di chat, sisanya dikirim sebagai CSV
+const keysListLimit = 30
+
+// /keys [aktif|habis|kedaluwarsa|dicabut]
+func handleListKeys(ctx *CommandContext) {
+	now := time.Now()
+	filter := strings.ToLower(strings.TrimSpace(ctx.Args))
+	if filter != "" && !slices.Contains(keyStatusOrder, filter) {
+		ctx.ReplyUsage()
+		return
+	}
+
+	all := ctx.Store.ListAccessKeys()
+	counts := make(map[string]int)
+	var keys []AccessKey
+	for _, k := range all {
+		status := k.Status(now)
+		counts[status]++
+		if filter == "" || status == filter {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		ctx.Reply("ℹ️ Tidak ada key.")
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🎟 *DAFTAR KEY* (%d)\n", len(all)))
+	for _, status := range keyStatusOrder {
+		sb.WriteString(fmt.Sprintf("%s: %d  ", status, counts[status]))
+	}
+	sb.WriteString("\n\n")
+	for i, k := range keys {
+		if i == keysListLimit {
+			sb.WriteString(fmt.Sprintf("… dan %d key lain (lihat file CSV).\n", len(keys)-keysListLimit))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("▪️ `%s` — %s %d/%d, %s, %s\n", k.Key, k.Status(now), k.Uses, k.maxUses(), formatAccessDuration(k.Duration), k.Tier))
+		if len(k.RedeemedBy) > 0 {
+			sb.WriteString(fmt.Sprintf("   👤 %s\n", strings.Join(k.RedeemedBy, ", ")))
+		}
+	}
+	reply := tgbotapi.NewMessage(ctx.ChatID, sb.String())
+	reply.ParseMode = "Markdown"
+	ctx.Bot.Send(reply)
+
+	if len(keys) > keysListLimit {
+		fileName := fmt.Sprintf("keys_%s.csv", now.Format("20060102_150405"))
+		ctx.Bot.Send(tgbotapi.NewDocument(ctx.ChatID, tgbotapi.FileBytes{Name: fileName, Bytes: accessKeysCSV(keys, now)}))
+	}
+}
+
+// accessKeysCSV: satu baris per key, dipakai /genkey massal dan /keys
+func accessKeysCSV(keys []AccessKey, now time.Time) []byte {
+	b := &bytes.Buffer{}
+	w := csv.NewWriter(b)
+	w.Write([]string{"KEY", "STATUS", "TIER", "ACCESS DURATION", "USES", "MAX USES", "KEY EXPIRES", "CREATED AT", "CREATED BY", "REDEEMED BY"})
+	for _, k := range keys {
+		w.Write([]string{
+			k.Key, k.Status(now), k.Tier, formatAccessDuration(k.Duration),
+			strconv.Itoa(k.Uses), strconv.Itoa(k.maxUses()), formatExpiry(k.ExpiresAt),
+			k.CreatedAt.Format("2006-01-02 15:04"), k.CreatedBy, strings.Join(k.RedeemedBy, " "),
+		})
+	}
+	w.Flush()
+	return b.Bytes()
+}
+
+// /revokekey <key>: key tidak bisa di-redeem lagi, user yang sudah redeem tetap aktif
+func handleRevokeKey(ctx *CommandContext) {
+	key := strings.TrimSpace(ctx.Args)
+	if key == "" {
+		ctx.ReplyUsage()
+		return
+	}
+	if !ctx.Store.RevokeAccessKey(key) {
+		ctx.Reply(fmt.Sprintf("❌ Key `%s` tidak ditemukan.", key))
+		return
+	}
+	ctx.Store.LogActivity(ctx.User, "REVOKEKEY", key)
+	ctx.Reply(fmt.Sprintf("🚫 **KEY DICABUT** `%s`\nUser yang sudah redeem tetap aktif, gunakan `/deauth <id>` untuk mengeluarkan.", key))
+}
+
+// /deauth <id>: keluarkan satu user dari whitelist tanpa reset global
+func handleDeauth(ctx *CommandContext) {
+	target := strings.TrimSpace(ctx.Args)
+	uid, err := strconv.ParseInt(target, 10, 64)
+	if err != nil {
+		ctx.ReplyUsage()
+		return
+	}
+	if !ctx.Store.DeauthorizeUser(target) {
+		ctx.Reply(fmt.Sprintf("❌ User `%s` tidak ada di whitelist.", target))
+		return
+	}
+	ctx.Store.LogActivity(ctx.User, "DEAUTH", target)
+	ctx.Reply(fmt.Sprintf("🔒 **AKSES DICABUT** `%s`", target))
+	ctx.Bot.Send(tgbotapi.NewMessage(uid, "🔒 Akses Anda telah dicabut oleh admin."))
+}
+
+func handleAccessControl(bot *tgbotapi.BotAPI, chatID int64, store Store, command string) {
 	// command di sini berisi msg.Text dari main.go
 
 	switch {
+	// Mode sudah diubah & disimpan lewat LiveConfig.Update di commands.go, di sini hanya balasan
 	case command == "/open":
-		config := getSystemConfig(es)
-		config.Mode = "OPEN"
-		saveSystemConfig(es, config)
 		bot.Send(tgbotapi.NewMessage(chatID, "🔓 **SYSTEM OPEN**\nSekarang semua orang bisa mengakses bot."))
 
 	case command == "/close":
-		config := getSystemConfig(es)
-		config.Mode = "CLOSE"
-		saveSystemConfig(es, config)
 		bot.Send(tgbotapi.NewMessage(chatID, "🔒 **SYSTEM CLOSED**\nHanya Admin & User yang memiliki Key yang bisa akses."))
 
-	case command == "/genkey":
-		key := generateInviteKey()
-		saveAccessKey(es, key)
-		msg := fmt.Sprintf("🎟 **NEW ACCESS KEY**\nKey: `%s`\n\nBerikan key ini ke user. Gunakan `/redeem %s`", key, key)
-		reply := tgbotapi.NewMessage(chatID, msg)
-		reply.ParseMode = "Markdown"
-		bot.Send(reply)
-
 	case command == "/delkey":
-		resetAllAccess(es)
+		store.ResetAllAccess()
 		bot.Send(tgbotapi.NewMessage(chatID, "💥 **RESET SUCCESS**\nSemua Key dihapus.\nSemua User (kecuali Admin) telah dikeluarkan dari whitelist."))
 
 	// FITUR BERSIH-BERSIH (FIXED ERROR MSG)
@@ -102,7 +231,7 @@ func handleAccessControl(bot *tgbotapi.BotAPI, chatID int64, es *elasticsearch.C
 		loadingMsg, _ := bot.Send(tgbotapi.NewMessage(chatID, "⏳ _Sedang menghapus data dari database..._"))
 
 		// 2. Eksekusi Penghapusan
-		deletedCount := deleteBySource(es, filename)
+		deletedCount := store.DeleteBySource(filename)
 
 		// 3. Edit Pesan Jadi Sukses
 		resultText := ""
@@ -118,10 +247,10 @@ func handleAccessControl(bot *tgbotapi.BotAPI, chatID int64, es *elasticsearch.C
 	}
 }
 
-func handleStats(bot *tgbotapi.BotAPI, chatID int64, es *elasticsearch.Client) {
+func handleStats(bot *tgbotapi.BotAPI, chatID int64, store Store) {
 	msgLoading, _ := bot.Send(tgbotapi.NewMessage(chatID, "📊 _Mengambil data statistik..._"))
-	stats := getClusterStats(es)
-	config := getSystemConfig(es)
+	stats := store.GetClusterStats()
+	config := store.GetSystemConfig()
 
 	statusIcon := "🔓"
 	if config.Mode == "CLOSE" {
@@ -144,14 +273,14 @@ func handleStats(bot *tgbotapi.BotAPI, chatID int64, es *elasticsearch.Client) {
 	msg := fmt.Sprintf(`📊 **SYSTEM STATUS**
 ----------------
 🔐 System Mode: *%s %s*
-⚡ Rate Limit: *%d req/menit*
+⚡ Rate Limit: *%d req/menit (burst %d)*
 💾 Total Data: *%d records*
 📁 Total Sources: *%d files*
 👥 Verified Users: *%d users*
 🔥 Top Search: %s
 🖥 RAM Usage: *%d MB*`,
 		statusIcon, config.Mode,
-		config.RateLimit,
+		config.RateLimit, config.rateBurst(),
 		stats.TotalRecords,
 		stats.TotalSources,
 		stats.TotalUsers,
@@ -163,74 +292,129 @@ func handleStats(bot *tgbotapi.BotAPI, chatID int64, es *elasticsearch.Client) {
 	bot.Send(editMsg)
 }
 
-// --- LOGIC UPLOAD (Smart Router) ---
-func handleURLUpload(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, es *elasticsearch.Client) {
-	url := msg.Text
-	parts := strings.Split(url, "/")
-	fileName := "url_" + parts[len(parts)-1]
+// /exportpolicy                 -> tampilkan policy
+// /exportpolicy user hash        -> kolom sensitif role user di-hash
+// /exportpolicy encrypt on       -> semua export wajib zip AES
+func handleExportPolicy(bot *tgbotapi.BotAPI, chatID int64, store Store, globalConfig *LiveConfig, args string) {
+	parts := strings.Fields(strings.ToLower(args))
+	config := globalConfig.Get()
 
-	bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "🌐 _Downloading stream..._"))
-
-	resp, err := http.Get(url)
-	if err != nil {
-		bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "❌ Gagal download URL."))
+	switch {
+	case len(parts) == 0:
+		// Hanya tampilkan
+	case len(parts) == 2 && parts[0] == "encrypt" && (parts[1] == "on" || parts[1] == "off"):
+		config = globalConfig.Update(store, func(c *SystemConfig) { c.EncryptExports = parts[1] == "on" })
+	case len(parts) == 2:
+		role, ok := parseRole(parts[0])
+		if !ok {
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Role tidak dikenal. Pilihan: owner, "+roleNames()))
+			return
+		}
+		policy, ok := parseSensitivePolicy(parts[1])
+		if !ok {
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Policy harus salah satu: mask, hash, include"))
+			return
+		}
+		config = globalConfig.Update(store, func(c *SystemConfig) {
+			if c.ExportPolicy == nil {
+				c.ExportPolicy = make(map[Role]SensitivePolicy)
+			}
+			c.ExportPolicy[role] = policy
+		})
+	default:
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Gunakan: `/exportpolicy <role> mask|hash|include` atau `/exportpolicy encrypt on|off`"))
 		return
 	}
-	defer resp.Body.Close()
-
-	// ROUTING PINTAR BERDASARKAN EKSTENSI
-	total := 0
-	lowerName := strings.ToLower(fileName)
 
-	if strings.HasSuffix(lowerName, ".csv") {
-		total = ingestStreamCSV(resp.Body, fileName, es)
-	} else if strings.HasSuffix(lowerName, ".json") {
-		// JSON Array [...] -> Pakai Decoder Baru
-		total = ingestStandardJSON(resp.Body, fileName, es)
-	} else {
-		// TXT, SQL, JSONL, Combo List -> Pakai Scanner Pintar
-		total = ingestStreamText(resp.Body, fileName, es)
+	var sb strings.Builder
+	sb.WriteString("🔒 *EXPORT POLICY*\n")
+	for i := len(roleOrder) - 1; i >= 0; i-- {
+		role := roleOrder[i]
+		policy := exportPolicyFor(&config, role)
+		sb.WriteString(fmt.Sprintf("▪️ `%s`: %s (%s)\n", role, policy, sensitivePolicyLabels[policy]))
+	}
+	encrypt := "OFF (opsional via `/export secure ...`)"
+	if config.EncryptExports {
+		encrypt = "ON (semua export)"
 	}
+	sb.WriteString("🗜 Zip terenkripsi: " + encrypt)
 
-	bot.Send(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("✅ **SELESAI!**\nFile: `%s`\nTotal: %d baris", fileName, total)))
+	reply := tgbotapi.NewMessage(chatID, sb.String())
+	reply.ParseMode = "Markdown"
+	bot.Send(reply)
 }
 
-func handleFileUpload(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, token string, es *elasticsearch.Client) {
-	bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "📥 _Menerima file..._"))
-	fileURL, err := bot.GetFileDirectURL(msg.Document.FileID)
-	if err != nil {
-		return
-	}
+// --- LOGIC UPLOAD (Smart Router) ---
+// Upload tidak lagi diproses di update loop, tapi dimasukkan ke antrian job (lihat jobs.go)
+func handleURLUpload(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, jobs *JobManager) {
+	url := strings.TrimSpace(msg.Text)
+	parts := strings.Split(url, "/")
+	fileName := "url_" + parts[len(parts)-1]
 
-	resp, err := http.Get(fileURL)
+	submitIngestJob(bot, msg.Chat.ID, jobs, IngestJobRecord{
+		Source: fileName,
+		Kind:   "url",
+		URL:    url,
+		ChatID: msg.Chat.ID,
+	})
+}
+
+func handleFileUpload(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, jobs *JobManager) {
+	submitIngestJob(bot, msg.Chat.ID, jobs, IngestJobRecord{
+		Source: msg.Document.FileName,
+		Kind:   "file",
+		FileID: msg.Document.FileID,
+		ChatID: msg.Chat.ID,
+	})
+}
+
+func submitIngestJob(bot *tgbotapi.BotAPI, chatID int64, jobs *JobManager, rec IngestJobRecord) {
+	job, err := jobs.Submit(rec)
 	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
 		return
 	}
-	defer resp.Body.Close()
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("📥 Job `%s` masuk antrian: `%s`\nCek status: /jobs • Batalkan: `/cancel %s`", job.ID, rec.Source, job.ID)))
+}
 
-	fileName := msg.Document.FileName
+// --- HELPER INGESTION ---
 
-	// ROUTING PINTAR BERDASARKAN EKSTENSI
-	total := 0
+// ROUTING PINTAR BERDASARKAN EKSTENSI
+func ingestByExtension(r io.Reader, fileName string, ing *BulkIngester, p *IngestProgress) int {
+	return ingesterFor(fileName)(r, fileName, ing, p)
+}
+
+type ingestFunc func(r io.Reader, filename string, ing *BulkIngester, p *IngestProgress) int
+
+// ingesterFor memilih ingester dari nama file (dipisah agar arsip bisa routing
+// pakai nama member/nama tanpa .gz tapi tetap mencatat leak_source aslinya)
+func ingesterFor(fileName string) ingestFunc {
 	lowerName := strings.ToLower(fileName)
 
 	if strings.HasSuffix(lowerName, ".csv") {
-		total = ingestStreamCSV(resp.Body, fileName, es)
+		return ingestStreamCSV
 	} else if strings.HasSuffix(lowerName, ".json") {
-		total = ingestStandardJSON(resp.Body, fileName, es)
-	} else {
-		total = ingestStreamText(resp.Body, fileName, es)
+		// JSON Array [...] -> Pakai Decoder Baru
+		return ingestStandardJSON
+	} else if strings.HasSuffix(lowerName, ".sql") {
+		// MySQL / PostgreSQL dump -> satu dokumen per baris tabel
+		return ingestSQLDump
 	}
-
-	bot.Send(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("✅ **UPLOAD SELESAI!**\nFile: `%s`\nTotal: %d", fileName, total)))
+	// TXT, JSONL, Combo List -> Pakai Scanner Pintar
+	return ingestStreamText
 }
 
-// --- HELPER INGESTION ---
-
 // 1. CSV
-func ingestStreamCSV(r io.Reader, filename string, es *elasticsearch.Client) int {
+func ingestStreamCSV(r io.Reader, filename string, ing *BulkIngester, p *IngestProgress) int {
 	reader := csv.NewReader(r)
-	headers, _ := reader.Read()
+	headers := p.ResumeHeader()
+	if headers == nil {
+		headers, _ = reader.Read()
+		p.SetHeader(headers)
+	} else {
+		// Resume di tengah file: header diambil dari checkpoint
+		reader.FieldsPerRecord = len(headers)
+	}
 	count := 0
 	for {
 		record, err := reader.Read()
@@ -238,6 +422,12 @@ func ingestStreamCSV(r io.Reader, filename string, es *elasticsearch.Client) int
 			break
 		}
 		if err != nil {
+			// Baris CSV rusak dilewati, error dari reader (koneksi putus / job dibatalkan) menghentikan ingest
+			if _, ok := err.(*csv.ParseError); !ok {
+				break
+			}
+			p.Skip()
+			p.Advance(reader.InputOffset())
 			continue
 		}
 		doc := make(map[string]interface{})
@@ -251,22 +441,34 @@ func ingestStreamCSV(r io.Reader, filename string, es *elasticsearch.Client) int
 			}
 		}
 		doc["full_text"] = strings.Join(txtBuf, " ")
-		indexDocument(es, doc, generateFingerprint(doc["full_text"].(string)+filename))
+		ing.Add(doc, generateFingerprint(doc["full_text"].(string)+filename))
+		p.Line(lineCSV)
+		p.Advance(reader.InputOffset())
 		count++
 	}
 	return count
 }
 
 // 2. TEXT / COMBO / JSONL
-func ingestStreamText(r io.Reader, filename string, es *elasticsearch.Client) int {
+func ingestStreamText(r io.Reader, filename string, ing *BulkIngester, p *IngestProgress) int {
 	scanner := bufio.NewScanner(r)
 	buf := make([]byte, 0, 64*1024)
 	scanner.Buffer(buf, 10*1024*1024)
 	count := 0
 
+	// Hitung offset akhir tiap baris (termasuk \r\n) untuk checkpoint
+	var offset int64
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		offset += int64(advance)
+		return advance, token, err
+	})
+
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if len(line) < 5 {
+			p.Skip()
+			p.Advance(offset)
 			continue
 		}
 
@@ -274,6 +476,7 @@ func ingestStreamText(r io.Reader, filename string, es *elasticsearch.Client) in
 		doc["leak_source"] = filename
 		doc["full_text"] = line
 		doc["raw_content"] = line
+		lineType := lineRaw
 
 		// LOGIC 1: JSON LINES (.jsonl)
 		if strings.HasPrefix(line, "{") && strings.HasSuffix(line, "}") {
@@ -281,6 +484,7 @@ func ingestStreamText(r io.Reader, filename string, es *elasticsearch.Client) in
 			if err := json.Unmarshal([]byte(line), &jsonDoc); err == nil {
 				// Flatten nested JSON agar field terbaca di root
 				flattenMap("", jsonDoc, doc)
+				lineType = lineJSONL
 				goto Indexing
 			}
 		}
@@ -289,6 +493,7 @@ func ingestStreamText(r io.Reader, filename string, es *elasticsearch.Client) in
 		if strings.Contains(line, ":") {
 			parts := strings.SplitN(line, ":", 2)
 			if len(parts) == 2 {
+				lineType = lineCombo
 				val1 := strings.TrimSpace(parts[0])
 				val2 := strings.TrimSpace(parts[1])
 				doc["identity"] = val1
@@ -305,22 +510,37 @@ func ingestStreamText(r io.Reader, filename string, es *elasticsearch.Client) in
 		} else if strings.Contains(line, "|") {
 			parts := strings.SplitN(line, "|", 2)
 			if len(parts) == 2 {
+				lineType = linePipe
 				doc["identity"] = strings.TrimSpace(parts[0])
 				doc["password"] = strings.TrimSpace(parts[1])
 			}
 		} else if strings.Contains(strings.ToUpper(line), "INSERT INTO") {
 			doc["data_type"] = "sql_query"
+			lineType = lineSQL
 		}
 
 	Indexing:
-		indexDocument(es, doc, generateFingerprint(line+filename))
+		ing.Add(doc, generateFingerprint(line+filename))
+		p.Line(lineType)
+		p.Advance(offset)
 		count++
 	}
 	return count
 }
 
 // 3. STANDARD JSON ARRAY [...] (BARU + FLATTEN)
-func ingestStandardJSON(r io.Reader, filename string, es *elasticsearch.Client) int {
+func ingestStandardJSON(r io.Reader, filename string, ing *BulkIngester, p *IngestProgress) int {
+	// Resume: stream dimulai di tengah array, jadikan array valid lagi
+	var base int64
+	if p.ResumeOffset() > 0 {
+		resumed, skipped, err := resumeJSONArray(r)
+		if err != nil {
+			return 0
+		}
+		r = resumed
+		base = skipped - 1 // "[" tambahan tidak ada di file asli
+	}
+
 	decoder := json.NewDecoder(r)
 
 	// Cek Token Awal
@@ -338,6 +558,12 @@ func ingestStandardJSON(r io.Reader, filename string, es *elasticsearch.Client)
 	for decoder.More() {
 		var rawDoc map[string]interface{}
 		if err := decoder.Decode(&rawDoc); err != nil {
+			p.Skip()
+			// Syntax error -> decoder tidak bisa lanjut, hentikan agar tidak loop selamanya
+			if _, ok := err.(*json.UnmarshalTypeError); !ok {
+				break
+			}
+			p.Advance(base + decoder.InputOffset())
 			continue
 		}
 
@@ -353,12 +579,37 @@ func ingestStandardJSON(r io.Reader, filename string, es *elasticsearch.Client)
 		}
 
 		// Index
-		indexDocument(es, finalDoc, generateFingerprint(fmt.Sprintf("%v", finalDoc)+filename))
+		ing.Add(finalDoc, generateFingerprint(fmt.Sprintf("%v", finalDoc)+filename))
+		p.Line(lineJSON)
+		p.Advance(base + decoder.InputOffset())
 		count++
 	}
 	return count
 }
 
+// resumeJSONArray mengubah sisa array `  ,{...},{...}]` menjadi `[{...},{...}]`.
+// Return jumlah byte yang dibuang dari reader asli.
+func resumeJSONArray(r io.Reader) (io.Reader, int64, error) {
+	br := bufio.NewReader(r)
+	var skipped int64
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, skipped, err
+		}
+		if b == ',' {
+			skipped++
+			break
+		}
+		if b != ' ' && b != '\n' && b != '\r' && b != '\t' {
+			br.UnreadByte()
+			break
+		}
+		skipped++
+	}
+	return io.MultiReader(strings.NewReader("["), br), skipped, nil
+}
+
 // Helper Flatten
 func flattenMap(prefix string, src map[string]interface{}, dest map[string]interface{}) {
 	for k, v := range src {
@@ -376,16 +627,14 @@ func flattenMap(prefix string, src map[string]interface{}, dest map[string]inter
 
 // --- BROADCAST & NOTIF ---
 
-func handleBroadcast(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, es *elasticsearch.Client) {
-	chatID := msg.Chat.ID
-	text := strings.TrimSpace(strings.Replace(msg.Text, "/broadcast", "", 1))
+func handleBroadcast(bot *tgbotapi.BotAPI, chatID int64, store Store, text string) {
 	if text == "" {
 		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Gunakan: `/broadcast Pesan...`"))
 		return
 	}
 
 	bot.Send(tgbotapi.NewMessage(chatID, "📢 _Memulai broadcast..._"))
-	targets := getAllVerifiedUserIDs(es)
+	targets := store.GetAllVerifiedUserIDs()
 	if len(targets) == 0 {
 		bot.Send(tgbotapi.NewMessage(chatID, "❌ Tidak ada Verified User."))
 		return
@@ -410,16 +659,14 @@ func handleBroadcast(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, es *elasticsea
 	bot.Send(tgbotapi.NewMessage(chatID, report))
 }
 
-func handleNotification(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, es *elasticsearch.Client) {
-	chatID := msg.Chat.ID
-	text := strings.TrimSpace(strings.Replace(msg.Text, "/notif", "", 1))
+func handleNotification(bot *tgbotapi.BotAPI, chatID int64, store Store, text string) {
 	if text == "" {
 		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Gunakan: `/notif Pesan...`"))
 		return
 	}
 
 	bot.Send(tgbotapi.NewMessage(chatID, "🔔 _Mengumpulkan data semua user..._"))
-	targets := getAllUniqueLogUserIDs(es)
+	targets := store.GetAllUniqueLogUserIDs()
 	if len(targets) == 0 {
 		bot.Send(tgbotapi.NewMessage(chatID, "❌ Belum ada history user."))
 		return
@@ -444,9 +691,9 @@ func handleNotification(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, es *elastic
 	bot.Send(tgbotapi.NewMessage(chatID, report))
 }
 
-func handleGetUsers(bot *tgbotapi.BotAPI, chatID int64, es *elasticsearch.Client) {
+func handleGetUsers(bot *tgbotapi.BotAPI, chatID int64, store Store) {
 	bot.Send(tgbotapi.NewMessage(chatID, "👥 _Sedang merekap data pengguna..._"))
-	users := generateUserReport(es)
+	users := store.GenerateUserReport()
 	if len(users) == 0 {
 		bot.Send(tgbotapi.NewMessage(chatID, "❌ Belum ada data pengguna."))
 		return
@@ -472,15 +719,14 @@ func handleGetUsers(bot *tgbotapi.BotAPI, chatID int64, es *elasticsearch.Client
 	bot.Send(docMsg)
 }
 
-func handleDirectMessage(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
-	chatID := msg.Chat.ID
-	parts := strings.SplitN(msg.Text, " ", 3)
-	if len(parts) < 3 {
+func handleDirectMessage(bot *tgbotapi.BotAPI, chatID int64, args string) {
+	parts := strings.SplitN(args, " ", 2)
+	if len(parts) < 2 {
 		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Gunakan: `/sendto <UserID> <Pesan>`"))
 		return
 	}
-	targetIDStr := strings.TrimSpace(parts[1])
-	content := parts[2]
+	targetIDStr := strings.TrimSpace(parts[0])
+	content := parts[1]
 	targetID, err := strconv.ParseInt(targetIDStr, 10, 64)
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(chatID, "❌ ID User harus angka."))
@@ -499,9 +745,8 @@ func handleDirectMessage(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
 	}
 }
 
-func handleBanSystem(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, es *elasticsearch.Client, cmd string) {
+func handleBanSystem(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, store Store, cmd string, args string) {
 	chatID := msg.Chat.ID
-	args := strings.TrimSpace(strings.Replace(msg.Text, cmd, "", 1))
 	if args == "" {
 		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Gunakan: `/ban <UserID> [Alasan]`"))
 		return
@@ -519,13 +764,13 @@ func handleBanSystem(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, es *elasticsea
 	}
 
 	if cmd == "/ban" {
-		banUser(es, targetID, reason)
+		store.BanUser(targetID, reason)
 		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⛔ **BANNED** `%s`", targetID)))
 		if uid, err := strconv.ParseInt(targetID, 10, 64); err == nil {
 			bot.Send(tgbotapi.NewMessage(uid, "🚫 **AKUN DIBEKUKAN**\nAlasan: "+reason))
 		}
 	} else if cmd == "/unban" {
-		unbanUser(es, targetID)
+		store.UnbanUser(targetID)
 		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ **UNBANNED** `%s`", targetID)))
 		if uid, err := strconv.ParseInt(targetID, 10, 64); err == nil {
 			bot.Send(tgbotapi.NewMessage(uid, "✅ **AKSES DIPULIHKAN**"))
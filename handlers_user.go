@@ -1,139 +1,195 @@
 package main
 
 import (
-	"bytes"
-	"encoding/csv"
+	"errors"
 	"fmt"
-	"sort"
+	"html"
+	"log"
 	"strings"
+	"time"
 
-	"github.com/elastic/go-elasticsearch/v9"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-func handleSearch(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, es *elasticsearch.Client, keyword string) {
+func handleSearch(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, store Store, sessions *SearchSessions, keyword string) {
 	// query := msg.Text
 	chatID := msg.Chat.ID
+	if !validateQuery(bot, chatID, keyword) {
+		return
+	}
 	loading, _ := bot.Send(tgbotapi.NewMessage(chatID, "🔍 _Sedang mencari..._"))
 
-	// Gunakan fungsi dari es_queries.go
-	esQuery := buildSearchQuery(keyword, true)
-	result, err := executeSearch(es, "breach_data", esQuery, 10) // Ambil 10
+	// Halaman pertama, halaman berikutnya lewat tombol (lihat search_pager.go)
+	session := sessions.Start(store, msg.From.ID, chatID, keyword)
+	replyText, markup, err := fetchSearchPage(store, session, 0)
 
+	bot.Request(tgbotapi.NewDeleteMessage(chatID, loading.MessageID))
 	if err != nil {
+		sessions.End(store, session)
 		bot.Send(tgbotapi.NewMessage(chatID, "❌ Error Database."))
 		return
 	}
 
-	totalFound := result.Hits.Total.Value
-	var replyText string
-
-	if totalFound > 0 {
-		replyText = fmt.Sprintf("🚨 *DATA FOUND!*\nKeyword: `%s`\nResult: %d Data\n\n", escapeMarkdown(keyword), totalFound)
-		for i, hit := range result.Hits.Hits {
-			if i >= 5 {
-				break
-			} // Limit tampilan chat
-			replyText += "📂 *RECORD:*\n"
-			for k, v := range hit.Source {
-				if k == "full_text" || k == "raw_content" || k == "upload_date" || k == "leak_source" {
-					continue
-				}
-				valStr := fmt.Sprintf("%v", v)
-				if isSensitive(k) {
-					valStr = maskPassword(valStr)
-				}
-				replyText += fmt.Sprintf("▪️ `%s`: `%s`\n", escapeMarkdown(strings.ToUpper(k)), escapeMarkdown(valStr))
-			}
-			sourceName := fmt.Sprintf("%v", hit.Source["leak_source"])
-			replyText += fmt.Sprintf("📁 Source: `%s`\n", escapeMarkdown(sourceName))
-			replyText += "------------------\n"
+	msgRep := tgbotapi.NewMessage(chatID, replyText)
+	msgRep.ParseMode = "Markdown"
+	if markup != nil {
+		msgRep.ReplyMarkup = markup
+	}
+	sent, err := bot.Send(msgRep)
+	if err != nil {
+		// Tanpa pesan hasil tidak ada tombol yang bisa memakai sesi ini, PIT langsung ditutup
+		log.Printf("⚠️ Gagal mengirim hasil pencarian ke %d: %v", chatID, err)
+		sessions.End(store, session)
+		return
+	}
+	session.setMessageID(sent.MessageID)
+}
+
+// formatSearchRecord menampilkan satu hit (field sensitif di-mask)
+func formatSearchRecord(hit ESHit) string {
+	var sb strings.Builder
+	sb.WriteString("📂 *RECORD:*\n")
+	for k, v := range hit.Source {
+		if k == "full_text" || k == "raw_content" || k == "upload_date" || k == "leak_source" {
+			continue
 		}
-		if totalFound > 5 {
-			replyText += fmt.Sprintf("_(...%d data lainnya. Gunakan /export untuk download)_", totalFound-5)
+		valStr := fmt.Sprintf("%v", v)
+		if isSensitive(k) {
+			// maskPassword bekerja pada baris "kolom: nilai", nilai saja tidak pernah di-mask
+			_, valStr, _ = strings.Cut(maskPassword(k+": "+valStr), ": ")
 		}
-	} else {
-		replyText = fmt.Sprintf("✅ *AMAN!*\nNihil: `%s`", escapeMarkdown(keyword))
+		sb.WriteString(fmt.Sprintf("▪️ `%s`: `%s`\n", escapeMarkdown(strings.ToUpper(k)), escapeMarkdown(valStr)))
 	}
-
-	bot.Request(tgbotapi.NewDeleteMessage(chatID, loading.MessageID))
-	msgRep := tgbotapi.NewMessage(chatID, replyText)
-	msgRep.ParseMode = "Markdown"
-	bot.Send(msgRep)
+	sourceName := fmt.Sprintf("%v", hit.Source["leak_source"])
+	sb.WriteString(fmt.Sprintf("📁 Source: `%s`\n", escapeMarkdown(sourceName)))
+	sb.WriteString("------------------\n")
+	return sb.String()
 }
 
-func handleExport(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, es *elasticsearch.Client, keyword string) {
+func handleExport(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, store Store, req ExportRequest) {
 	chatID := msg.Chat.ID
-	bot.Send(tgbotapi.NewMessage(chatID, "📄 _Menyiapkan file laporan..._"))
-
-	// 1. Query ES
-	esQuery := buildSearchQuery(keyword, true)
-	result, err := executeSearch(es, "breach_data", esQuery, 1000)
+	keyword := req.Keyword
+	exporter, ok := exporters[req.Format]
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Format tidak dikenal. Pilihan: csv, json, ndjson, xlsx, html"))
+		return
+	}
+	if !validateQuery(bot, chatID, keyword) {
+		return
+	}
 
-	if err != nil || result.Hits.Total.Value == 0 {
+	// 0. Kuota bulanan dipesan di depan (1 export + sisa baris), yang tidak terpakai dikembalikan di akhir
+	now := time.Now()
+	reservation, quota, err := reserveExport(store, req.UserID, req.Role, now)
+	if err == errQuotaExhausted {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("📉 **KUOTA EXPORT HABIS**\nExport: %s, baris: %s bulan ini. Reset: %s\nCek /quota",
+			formatQuota(int64(quota.Exports), int64(quota.Limits.ExportsPerMonth)), formatQuota(quota.ExportRows, quota.Limits.ExportRows),
+			nextQuotaMonth(now).Format("2006-01-02"))))
+		return
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	sentRows := int64(-1) // Tetap -1 jika file tidak sampai terkirim = kuota dikembalikan penuh
+	defer func() { reservation.settle(sentRows) }()
+	statusMsg, _ := bot.Send(tgbotapi.NewMessage(chatID, "📄 Menyiapkan file laporan..."))
+
+	// 1. Ambil SEMUA hasil per halaman (PIT + search_after) ke spool file
+	lastEdit := time.Now()
+	sp, err := spoolSearchResults(store, keyword, req.Sensitive, []byte(req.HashKey), reservation.MaxRows(), func(rows, total int) {
+		if time.Since(lastEdit) < progressInterval {
+			return
+		}
+		lastEdit = time.Now()
+		bot.Send(tgbotapi.NewEditMessageText(chatID, statusMsg.MessageID, fmt.Sprintf("📄 Mengambil data... %d / %d", rows, total)))
+	})
+	if sp == nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Gagal export: "+err.Error()))
+		return
+	}
+	defer sp.Close()
+	if err != nil {
+		// Data yang sudah terambil tetap dikirim, jumlahnya dilaporkan apa adanya
+		log.Printf("⚠️ Export %q terhenti di %d/%d: %v", keyword, sp.Rows, sp.Total, err)
+	}
+	if sp.Rows == 0 {
 		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Gagal export atau data kosong."))
 		return
 	}
 
-	// 2. ANALISA KOLOM (Cari semua kemungkinan kolom)
-	// Kita pakai Map agar tidak ada duplikat nama kolom
-	headerMap := make(map[string]bool)
+	// 2. Tulis file sesuai format, dipecah per ~45MB agar lolos batas upload Telegram
+	parts, werr := exporter.Export(sp, "result_"+sanitizeFileName(keyword), exportPartLimit)
+	if errors.Is(werr, errExportTooLarge) {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ File %s terlalu besar untuk Telegram. Gunakan `/export csv` atau `/export ndjson` (otomatis dipecah).", strings.ToUpper(exporter.Ext()))))
+		return
+	}
+	if werr != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Gagal menulis file export: "+werr.Error()))
+		return
+	}
 
-	for _, hit := range result.Hits.Hits {
-		for k := range hit.Source {
-			// Kita skip field internal yang bikin CSV berantakan/berat
-			if k == "full_text" || k == "raw_content" {
-				continue
-			}
-			headerMap[k] = true
+	// 3. Opsional: bungkus jadi zip AES, password dikirim terpisah setelah file
+	password := ""
+	if req.Encrypt {
+		password = generateExportPassword()
+		if parts, werr = encryptExportParts(parts, password); werr != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Gagal mengenkripsi file export: "+werr.Error()))
+			return
 		}
 	}
 
-	// 3. URUTKAN HEADER (Agar konsisten A-Z)
-	var headers []string
-	for k := range headerMap {
-		headers = append(headers, k)
+	// 4. Kirim file
+	for i, part := range parts {
+		docMsg := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(part.Path))
+		if len(parts) > 1 {
+			docMsg.Caption = fmt.Sprintf("📦 Bagian %d/%d: %d data", i+1, len(parts), part.Rows)
+		}
+		if _, err := bot.Send(docMsg); err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ Gagal mengirim bagian %d/%d: %v", i+1, len(parts), err)))
+			return
+		}
 	}
-	sort.Strings(headers) // Wajib sort agar urutan tidak acak!
 
-	// 4. BUAT CSV
-	b := &bytes.Buffer{}
-	w := csv.NewWriter(b)
+	sentRows = int64(sp.Rows)
 
-	// Tulis Header Sekali Saja
-	w.Write(headers)
+	if password != "" {
+		pwMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🔑 Password ZIP (sekali pakai): `%s`\n_Simpan lalu hapus pesan ini._", password))
+		pwMsg.ParseMode = "Markdown"
+		bot.Send(pwMsg)
+	}
 
-	// Tulis Isi Data (Mapping Sesuai Header)
-	for _, hit := range result.Hits.Hits {
-		src := hit.Source
-		var record []string
+	summary := fmt.Sprintf("✅ Export Selesai: %d dari %d data\n🔒 Kolom sensitif: %s", sp.Rows, sp.Total, sensitivePolicyLabels[req.Sensitive])
+	if sp.Capped {
+		summary += "\n📉 Dibatasi sisa kuota baris export bulan ini (cek /quota)."
+	} else if sp.Rows < sp.Total {
+		summary += "\n⚠️ Sebagian data tidak terambil, coba ulangi export."
+	}
+	bot.Send(tgbotapi.NewEditMessageText(chatID, statusMsg.MessageID, summary))
+}
 
-		// Loop berdasarkan HEADERS yang sudah diurutkan (Bukan loop map src)
-		for _, colName := range headers {
-			val, exists := src[colName]
-			if exists {
-				// Ambil datanya jika ada
-				record = append(record, fmt.Sprintf("%v", val))
-			} else {
-				// Isi kosong jika data ini tidak punya kolom tersebut
-				record = append(record, "")
-			}
-		}
-		w.Write(record)
+// validateQuery mem-parse keyword sebelum dikirim ke Store. Jika gagal, user dikirimi
+// pesan error dengan penanda posisi (HTML <pre> agar ^ sejajar & input tidak perlu di-escape Markdown).
+func validateQuery(bot *tgbotapi.BotAPI, chatID int64, keyword string) bool {
+	_, err := parseSearchQuery(keyword)
+	if err == nil {
+		return true
 	}
-	w.Flush()
 
-	// 5. KIRIM FILE
-	fileName := fmt.Sprintf("result_%s.csv", strings.ReplaceAll(keyword, " ", "_"))
-	fileBytes := tgbotapi.FileBytes{Name: fileName, Bytes: b.Bytes()}
-	docMsg := tgbotapi.NewDocument(chatID, fileBytes)
-	docMsg.Caption = fmt.Sprintf("✅ Export Selesai: %d data", len(result.Hits.Hits))
-	bot.Send(docMsg)
+	text := "❌ Query tidak valid."
+	var syntaxErr *QuerySyntaxError
+	if errors.As(err, &syntaxErr) {
+		text = fmt.Sprintf("❌ <b>Query tidak valid:</b> %s\n<pre>%s</pre>\nContoh: <code>domain:acme.com AND password:* NOT source:old.txt</code>",
+			html.EscapeString(syntaxErr.Msg), html.EscapeString(syntaxErr.Pretty()))
+	}
+	reply := tgbotapi.NewMessage(chatID, text)
+	reply.ParseMode = "HTML"
+	bot.Send(reply)
+	return false
 }
 
-func handleRedeem(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, es *elasticsearch.Client) {
+func handleRedeem(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, store Store, input string) {
 	chatID := msg.Chat.ID
-	input := strings.TrimSpace(strings.Replace(msg.Text, "/redeem", "", 1))
 	input = strings.TrimSpace(input) // Bersihkan spasi
 
 	if input == "" {
@@ -141,84 +197,44 @@ func handleRedeem(bot *tgbotapi.BotAPI, msg *tgbotapi.Message, es *elasticsearch
 		return
 	}
 
-	// 1. Cek Apakah Key Valid?
-	if getKeyStatus(es, input) {
-		// 2. Masukkan User ke Whitelist
-		userID := fmt.Sprintf("%d", msg.From.ID)
-		authorizeUser(es, userID, input)
+	// 1. Validasi & catat pemakaian key dalam satu operasi atomik (anti double-spend)
+	now := time.Now()
+	userID := fmt.Sprintf("%d", msg.From.ID)
+	key, err := store.RedeemAccessKey(input, userID, now)
+	if err == errKeyUnavailable || err == errKeyConflict {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ "+err.Error()))
+		return
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ **KEY INVALID**\nAlasan: %v.", err)))
+		return
+	}
 
-		// 3. Hapus Key (Agar tidak bisa dipakai orang lain)
-		deleteAccessKey(es, input)
+	// 2. Masukkan User ke Whitelist / perpanjang langganan
+	current, exists := store.GetAuthorizedUser(userID)
+	user := extendAuthorization(current, exists, key, userID, now)
+	store.AuthorizeUser(user)
 
-		bot.Send(tgbotapi.NewMessage(chatID, "✅ **AKSES DITERIMA!**\nSelamat, Anda sekarang bisa menggunakan bot ini sepuasnya."))
-	} else {
-		bot.Send(tgbotapi.NewMessage(chatID, "❌ **KEY INVALID**\nKode salah atau sudah digunakan."))
+	text := fmt.Sprintf("✅ **AKSES DITERIMA!**\n🏷 Tier: %s\n📅 Berlaku s/d: %s", user.Tier, formatExpiry(user.ExpiresAt))
+	if exists && current.Active(now) {
+		text = fmt.Sprintf("🔄 **LANGGANAN DIPERPANJANG!**\n🏷 Tier: %s\n📅 Berlaku s/d: %s", user.Tier, formatExpiry(user.ExpiresAt))
 	}
+	bot.Send(tgbotapi.NewMessage(chatID, text))
 }
 
-func handleHelp(bot *tgbotapi.BotAPI, chatID int64, isAdmin bool) {
+func handleHelp(bot *tgbotapi.BotAPI, chatID int64, router *Router, role Role) {
 	var msgText string
 
-	// Helper untuk membuat backtick (`) agar code block valid
-	code := func(s string) string {
-		return "`" + s + "`"
-	}
-
-	if isAdmin {
-		// === TAMPILAN KHUSUS ADMIN ===
+	// Daftar command digenerate dari registry router (lihat commands.go)
+	if role.Can(PermPanel) {
+		// === TAMPILAN KHUSUS STAFF (owner/admin/moderator) ===
 		// Gunakan *text* untuk Bold (Bukan **text**)
-		msgText = fmt.Sprintf(`🛡️ *ADMIN CONTROL PANEL*
-
-⚙️ *System Control*
-%s — Buka bot untuk publik
-%s — Kunci bot (Mode Privat)
-%s — Set rate limit (cth: 300)
-%s — Cek status server & data
-
-🔑 *Access Management*
-%s — Buat kode invite baru
-%s — Hapus semua key & whitelist
-%s — Download data user (CSV)
-%s — Cek log aktivitas user
-%s — Ban user
-%s — Unban user
-
-📢 *Communication*
-%s — Kirim ke Verified Users
-%s — Kirim ke Semua Users
-%s — Kirim pesan personal
-
-📥 *Data Management*
-• *Upload File:* Kirim file CSV/TXT langsung
-• *Upload URL:* Kirim Link Direct Download`,
-			code("/open"), code("/close"), code("/setlimit <n>"), code("/stats"),
-			code("/genkey"), code("/delkey"), code("/getusers"), code("/audit <user>"),
-			code("/ban <user>"), code("/unban <user>"),
-			code("/broadcast <msg>"), code("/notif <msg>"), code("/sendto <id> <msg>"))
-
+		msgText = fmt.Sprintf("🛡️ *CONTROL PANEL* (%s)\n", role) + router.HelpText(role)
 	} else {
 		// === TAMPILAN UNTUK USER BIASA ===
-		// Perbaikan: Hapus ** ganda, ganti jadi * tunggal.
-		// Gunakan fmt.Sprintf agar backtick tercetak sempurna sebagai Code Block.
-
-		msgText = fmt.Sprintf(`🤖 *PANDUAN PENGGUNAAN*
-
-🔍 *Cara Mencari Data*
-Cukup ketik kata kunci yang ingin dicari di awali dengan tanda %s.
-• *Pencarian Dasar:* %s
-• *Spesifik:* %s
-• *Spesifik:* %s
-• *Wildcard:* %s
-
-🛠️ *Fitur & Tools*
-%s — Download hasil lengkap (CSV)
-%s — Masukkan kode akses VIP
-%s — Menampilkan pesan ini
-
+		msgText = "🤖 *PANDUAN PENGGUNAAN*\n" + router.HelpText(role) + `
 🔒 *Status Akses*
-Jika bot dalam mode *CLOSE*, Anda memerlukan *Key* dari Admin untuk menggunakan fitur pencarian.`, code("/s <keyword>"),
-			code("rudi"), code("email:rudi@gmail.com"), code("ip:192.168.1.1"), code("*@yahoo.com"),
-			code("/export <keyword>"), code("/redeem <kode>"), code("/help"))
+Jika bot dalam mode *CLOSE*, Anda memerlukan *Key* dari Admin untuk menggunakan fitur pencarian.`
 	}
 
 	// Kirim Pesan
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// --- ARCHIVE INGESTION (gzip, zip, tar, tar.gz) ---
+
+// Batas anti zip-bomb untuk satu source (semua member & arsip bersarang dijumlah)
+type ArchiveLimits struct {
+	MaxExpandedBytes int64   // Total byte hasil ekstrak
+	MaxMembers       int     // Jumlah entry zip/tar
+	MaxRatio         float64 // Rasio hasil ekstrak / byte terkompresi
+}
+
+const (
+	maxArchiveDepth    = 3       // Arsip di dalam arsip (misal .zip berisi .tar.gz)
+	ratioCheckMinBytes = 1 << 20 // File kecil wajar rasionya tinggi, cek rasio setelah 1MB
+	archiveSniffSize   = 512     // Cukup untuk magic tar di offset 257
+)
+
+const (
+	archiveGzip = "gzip"
+	archiveZip  = "zip"
+	archiveTar  = "tar"
+)
+
+var errArchiveLimit = errors.New("batas arsip terlampaui")
+
+func defaultArchiveLimits() ArchiveLimits {
+	return ArchiveLimits{
+		MaxExpandedBytes: 20 << 30, // 20GB
+		MaxMembers:       10000,
+		MaxRatio:         200,
+	}
+}
+
+// archiveLimitsFromEnv membaca ARCHIVE_MAX_EXPANDED_BYTES, ARCHIVE_MAX_MEMBERS dan ARCHIVE_MAX_RATIO
+func archiveLimitsFromEnv() ArchiveLimits {
+	limits := defaultArchiveLimits()
+	if v, err := strconv.ParseInt(os.Getenv("ARCHIVE_MAX_EXPANDED_BYTES"), 10, 64); err == nil && v > 0 {
+		limits.MaxExpandedBytes = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("ARCHIVE_MAX_MEMBERS")); err == nil && v > 0 {
+		limits.MaxMembers = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("ARCHIVE_MAX_RATIO"), 64); err == nil && v > 0 {
+		limits.MaxRatio = v
+	}
+	return limits
+}
+
+// ingestSource adalah pintu masuk ingest job: deteksi arsip dari magic bytes
+// (bukan ekstensi), lalu setiap member diarahkan ke ingester yang sesuai.
+// File biasa langsung diteruskan ke ingestByExtension.
+func ingestSource(ctx context.Context, r io.Reader, fileName string, ing *BulkIngester, p *IngestProgress, limits ArchiveLimits) (int, error) {
+	// Resume selalu dari file biasa (offset arsip tidak pernah disimpan), jangan sniff di tengah file
+	if p.ResumeOffset() > 0 {
+		return ingestByExtension(r, fileName, ing, p), nil
+	}
+
+	a := &archiveIngest{ctx: ctx, limits: limits, ing: ing, p: p}
+	count := a.ingest(&countingReader{r: r, n: &a.compressed}, fileName, fileName, 0)
+	return count, a.err
+}
+
+type archiveIngest struct {
+	ctx    context.Context
+	limits ArchiveLimits
+	ing    *BulkIngester
+	p      *IngestProgress
+
+	compressed atomic.Int64 // Byte yang sudah dibaca dari source asli
+	expanded   int64
+	members    int
+	err        error
+}
+
+// ingest memproses satu stream. routeName dipakai untuk memilih ingester,
+// source dicatat sebagai leak_source (misal `leak.zip/users.csv`).
+func (a *archiveIngest) ingest(r io.Reader, routeName, source string, depth int) int {
+	br := bufio.NewReaderSize(r, archiveSniffSize)
+	head, _ := br.Peek(archiveSniffSize)
+
+	kind := detectArchive(head)
+	if kind == "" || depth >= maxArchiveDepth {
+		return ingesterFor(routeName)(br, source, a.ing, a.p)
+	}
+
+	// Offset di stream terkompresi tidak bisa dipakai untuk resume
+	a.p.DisableResume()
+
+	switch kind {
+	case archiveGzip:
+		return a.ingestGzip(br, routeName, source, depth)
+	case archiveZip:
+		return a.ingestZip(br, source, depth)
+	default:
+		return a.ingestTar(br, source, depth)
+	}
+}
+
+func (a *archiveIngest) ingestGzip(r io.Reader, routeName, source string, depth int) int {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		a.fail(fmt.Errorf("gzip rusak: %w", err))
+		return 0
+	}
+	defer zr.Close()
+
+	// File tunggal (dump.csv.gz) tetap tercatat dengan nama aslinya,
+	// tar.gz akan menambahkan nama member di belakangnya
+	return a.ingest(a.guard(zr), gunzippedName(routeName, zr.Name), source, depth+1)
+}
+
+func (a *archiveIngest) ingestTar(r io.Reader, source string, depth int) int {
+	tr := tar.NewReader(r)
+	count := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			a.fail(fmt.Errorf("tar rusak: %w", err))
+			break
+		}
+		if !a.addMember() {
+			break
+		}
+		if !hdr.FileInfo().Mode().IsRegular() || skipArchiveMember(hdr.Name) {
+			continue
+		}
+		count += a.ingest(tr, hdr.Name, source+"/"+hdr.Name, depth+1)
+		if a.err != nil {
+			break
+		}
+	}
+	return count
+}
+
+func (a *archiveIngest) ingestZip(r io.Reader, source string, depth int) int {
+	// archive/zip butuh random access (central directory ada di akhir file) -> spool ke temp file
+	tmp, err := os.CreateTemp("", "ingest-*.zip")
+	if err != nil {
+		a.fail(err)
+		return 0
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, io.LimitReader(r, a.limits.MaxExpandedBytes+1))
+	if err != nil {
+		a.fail(err)
+		return 0
+	}
+	if size > a.limits.MaxExpandedBytes {
+		a.fail(fmt.Errorf("%w: zip lebih dari %s", errArchiveLimit, formatBytes(a.limits.MaxExpandedBytes)))
+		return 0
+	}
+
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		a.fail(fmt.Errorf("zip rusak: %w", err))
+		return 0
+	}
+
+	// Tolak lebih awal dari header (murah). Header bisa dipalsukan, jadi guard tetap aktif saat ekstrak.
+	if a.members+len(zr.File) > a.limits.MaxMembers {
+		a.fail(fmt.Errorf("%w: %d member (maks %d)", errArchiveLimit, len(zr.File), a.limits.MaxMembers))
+		return 0
+	}
+	var declared uint64
+	for _, f := range zr.File {
+		declared += f.UncompressedSize64
+	}
+	if declared > uint64(a.limits.MaxExpandedBytes) {
+		a.fail(fmt.Errorf("%w: hasil ekstrak %s (maks %s)", errArchiveLimit, formatBytes(int64(declared)), formatBytes(a.limits.MaxExpandedBytes)))
+		return 0
+	}
+
+	count := 0
+	for _, f := range zr.File {
+		if !a.addMember() {
+			break
+		}
+		if f.FileInfo().IsDir() || skipArchiveMember(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			// Member terenkripsi / metode kompresi tidak didukung
+			a.p.Skip()
+			continue
+		}
+		count += a.ingest(a.guard(rc), f.Name, source+"/"+f.Name, depth+1)
+		rc.Close()
+		if a.err != nil {
+			break
+		}
+	}
+	return count
+}
+
+func (a *archiveIngest) addMember() bool {
+	if a.ctx.Err() != nil {
+		return false
+	}
+	a.members++
+	if a.members > a.limits.MaxMembers {
+		a.fail(fmt.Errorf("%w: lebih dari %d member", errArchiveLimit, a.limits.MaxMembers))
+		return false
+	}
+	return true
+}
+
+func (a *archiveIngest) fail(err error) {
+	if a.err == nil {
+		a.err = err
+	}
+}
+
+// guard membungkus output dekompresi: hitung byte hasil ekstrak, cek batas & pembatalan job.
+// Arsip bersarang dihitung di setiap lapisan (lebih ketat, bukan lebih longgar).
+func (a *archiveIngest) guard(r io.Reader) io.Reader {
+	return &bombGuard{a: a, r: r}
+}
+
+type bombGuard struct {
+	a *archiveIngest
+	r io.Reader
+}
+
+func (g *bombGuard) Read(b []byte) (int, error) {
+	a := g.a
+	if a.err != nil {
+		return 0, a.err
+	}
+	if err := a.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := g.r.Read(b)
+	a.expanded += int64(n)
+
+	if a.expanded > a.limits.MaxExpandedBytes {
+		a.fail(fmt.Errorf("%w: hasil ekstrak lebih dari %s", errArchiveLimit, formatBytes(a.limits.MaxExpandedBytes)))
+		return n, a.err
+	}
+	if a.expanded > ratioCheckMinBytes {
+		if ratio := float64(a.expanded) / float64(a.compressed.Load()+1); ratio > a.limits.MaxRatio {
+			a.fail(fmt.Errorf("%w: rasio kompresi %.0f:1 (maks %.0f:1)", errArchiveLimit, ratio, a.limits.MaxRatio))
+			return n, a.err
+		}
+	}
+	if err != nil && err != io.EOF {
+		a.fail(err)
+	}
+	return n, err
+}
+
+func detectArchive(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return archiveGzip
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return archiveZip
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return archiveTar
+	}
+	return ""
+}
+
+// gunzippedName: leak.tgz -> leak.tar, dump.csv.gz -> dump.csv, selain itu pakai nama di header gzip
+func gunzippedName(name string, headerName string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tgz"):
+		return name[:len(name)-4] + ".tar"
+	case strings.HasSuffix(lower, ".gz"):
+		return name[:len(name)-3]
+	case headerName != "":
+		return headerName
+	}
+	return name
+}
+
+// Sampah metadata dari macOS / Finder tidak perlu di-index
+func skipArchiveMember(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, "._") || base == ".DS_Store"
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- BULK INGESTION PIPELINE ---
+
+type BulkConfig struct {
+	BatchDocs  int           // Flush jika jumlah dokumen di batch mencapai angka ini
+	BatchBytes int           // Flush jika ukuran payload batch mencapai angka ini
+	Workers    int           // Jumlah flusher yang jalan paralel
+	MaxRetries int           // Retry untuk item yang ditolak (429 / 5xx)
+	Backoff    time.Duration // Jeda awal retry, dikali 2 setiap percobaan
+	Aliases    FieldAliases  // Normalisasi nama kolom ke field kanonik (nil = default)
+}
+
+func defaultBulkConfig() BulkConfig {
+	return BulkConfig{
+		BatchDocs:  1000,
+		BatchBytes: 5 * 1024 * 1024,
+		Workers:    4,
+		MaxRetries: 3,
+		Backoff:    500 * time.Millisecond,
+		Aliases:    defaultFieldAliases(),
+	}
+}
+
+// bulkConfigFromEnv membaca BULK_BATCH_DOCS, BULK_BATCH_BYTES, BULK_WORKERS, BULK_MAX_RETRIES dan FIELD_ALIASES
+func bulkConfigFromEnv() BulkConfig {
+	cfg := defaultBulkConfig()
+	envInt := func(name string, dest *int) {
+		if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+			*dest = v
+		}
+	}
+	envInt("BULK_BATCH_DOCS", &cfg.BatchDocs)
+	envInt("BULK_BATCH_BYTES", &cfg.BatchBytes)
+	envInt("BULK_WORKERS", &cfg.Workers)
+	envInt("BULK_MAX_RETRIES", &cfg.MaxRetries)
+	if spec := os.Getenv("FIELD_ALIASES"); spec != "" {
+		aliases, err := parseFieldAliases(spec, cfg.Aliases)
+		if err != nil {
+			log.Printf("⚠️ FIELD_ALIASES diabaikan: %v", err)
+		}
+		cfg.Aliases = aliases
+	}
+	return cfg
+}
+
+// Contoh item gagal yang disimpan; jumlah totalnya tetap dihitung di Failed
+const bulkFailureSamples = 10
+
+// Ringkasan hasil satu sesi ingest
+type BulkStats struct {
+	Submitted    int
+	Indexed      int
+	Failed       int
+	FailedSample []BulkItemResult // Maks bulkFailureSamples item pertama
+}
+
+// BulkIngester mengumpulkan dokumen dari ingester lalu mengirimnya per batch ke Store.
+// Add() dipanggil dari satu goroutine (ingester), flush berjalan di beberapa worker.
+type BulkIngester struct {
+	ctx        context.Context // Dibatalkan -> jeda retry berhenti, sisa item dicatat gagal
+	store      Store
+	cfg        BulkConfig
+	uploadDate string // Waktu mulai sesi ingest, dicap ke setiap dokumen (upload_date)
+
+	batch     []BulkDocument
+	batchSize int
+	submitted int
+
+	queue    chan []BulkDocument
+	wg       sync.WaitGroup
+	inflight sync.WaitGroup // Batch yang sudah dikirim ke queue tapi belum selesai
+
+	mu       sync.Mutex
+	indexed  int
+	failed   int
+	failures []BulkItemResult
+}
+
+func NewBulkIngester(store Store, cfg BulkConfig) *BulkIngester {
+	return NewBulkIngesterContext(context.Background(), store, cfg)
+}
+
+// NewBulkIngesterContext: sama seperti NewBulkIngester, retry berhenti saat ctx dibatalkan (job di-/cancel)
+func NewBulkIngesterContext(ctx context.Context, store Store, cfg BulkConfig) *BulkIngester {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.Aliases == nil {
+		cfg.Aliases = defaultFieldAliases()
+	}
+	b := &BulkIngester{
+		ctx:        ctx,
+		store:      store,
+		cfg:        cfg,
+		uploadDate: time.Now().UTC().Format(time.RFC3339),
+		queue:      make(chan []BulkDocument, cfg.Workers),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		b.wg.Add(1)
+		go b.worker()
+	}
+	return b
+}
+
+func (b *BulkIngester) Add(doc map[string]interface{}, id string) {
+	b.cfg.Aliases.Normalize(doc)
+	doc["upload_date"] = b.uploadDate
+
+	// Perkiraan ukuran payload = JSON dokumen + metadata action
+	body, err := json.Marshal(doc)
+	if err != nil {
+		b.recordFailures([]BulkItemResult{{ID: id, Error: "marshal: " + err.Error()}})
+		return
+	}
+
+	b.batch = append(b.batch, BulkDocument{ID: id, Doc: doc})
+	b.batchSize += len(body) + len(id) + 64
+	b.submitted++
+
+	if len(b.batch) >= b.cfg.BatchDocs || b.batchSize >= b.cfg.BatchBytes {
+		b.flush()
+	}
+}
+
+// Close mengirim sisa batch, menunggu semua worker selesai, lalu mengembalikan ringkasan
+func (b *BulkIngester) Close() BulkStats {
+	b.flush()
+	close(b.queue)
+	b.wg.Wait()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BulkStats{Submitted: b.submitted, Indexed: b.indexed, Failed: b.failed, FailedSample: b.failures}
+}
+
+// Indexed & FailedCount aman dibaca selama ingest berjalan (untuk progress)
+func (b *BulkIngester) Indexed() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.indexed
+}
+
+func (b *BulkIngester) FailedCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failed
+}
+
+// Flush mengirim batch yang tersisa lalu menunggu SEMUA batch selesai.
+// Setelah Flush return, semua dokumen yang sudah di-Add pasti sudah diproses (untuk checkpoint).
+func (b *BulkIngester) Flush() {
+	b.flush()
+	b.inflight.Wait()
+}
+
+func (b *BulkIngester) flush() {
+	if len(b.batch) == 0 {
+		return
+	}
+	b.inflight.Add(1)
+	b.queue <- b.batch
+	b.batch = nil
+	b.batchSize = 0
+}
+
+func (b *BulkIngester) worker() {
+	defer b.wg.Done()
+	for batch := range b.queue {
+		b.send(batch)
+		b.inflight.Done()
+	}
+}
+
+// send mengirim satu batch, retry item yang ditolak sementara dengan exponential backoff
+func (b *BulkIngester) send(batch []BulkDocument) {
+	pending := batch
+	backoff := b.cfg.Backoff
+
+	for attempt := 0; ; attempt++ {
+		failed, err := b.store.BulkIndex(pending)
+		if err != nil {
+			// Seluruh request gagal (koneksi putus, cluster overload, dll)
+			if attempt >= b.cfg.MaxRetries {
+				log.Printf("⚠️ Bulk gagal setelah %d percobaan: %v", attempt+1, err)
+				b.recordLost(pending, err)
+				return
+			}
+			if err := b.wait(backoff); err != nil {
+				b.recordLost(pending, err)
+				return
+			}
+			backoff *= 2
+			continue
+		}
+
+		// Item dicocokkan lewat posisinya di request, bukan ID: ID duplikat dalam
+		// satu batch (baris identik) tetap dihitung & di-retry masing-masing
+		var retry []BulkDocument
+		var permanent []BulkItemResult
+		for _, f := range failed {
+			if isRetryableStatus(f.Status) && attempt < b.cfg.MaxRetries && f.Index >= 0 && f.Index < len(pending) {
+				retry = append(retry, pending[f.Index])
+			} else {
+				permanent = append(permanent, f)
+			}
+		}
+
+		b.mu.Lock()
+		b.indexed += len(pending) - len(failed)
+		b.mu.Unlock()
+		b.recordFailures(permanent)
+
+		if len(retry) == 0 {
+			return
+		}
+		pending = retry
+		if err := b.wait(backoff); err != nil {
+			b.recordLost(pending, err)
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// wait menjeda retry, berhenti lebih awal jika ctx dibatalkan
+func (b *BulkIngester) wait(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-b.ctx.Done():
+		return b.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// recordLost mencatat semua dokumen yang tidak jadi dikirim sebagai gagal
+func (b *BulkIngester) recordLost(docs []BulkDocument, err error) {
+	lost := make([]BulkItemResult, 0, len(docs))
+	for _, d := range docs {
+		lost = append(lost, BulkItemResult{ID: d.ID, Error: err.Error()})
+	}
+	b.recordFailures(lost)
+}
+
+// recordFailures menghitung semua item gagal tapi hanya menyimpan beberapa contoh,
+// agar sumber yang ditolak seluruhnya (mapping salah, dll) tidak menghabiskan memori
+func (b *BulkIngester) recordFailures(items []BulkItemResult) {
+	if len(items) == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.failed += len(items)
+	if room := bulkFailureSamples - len(b.failures); room > 0 {
+		if len(items) > room {
+			items = items[:room]
+		}
+		b.failures = append(b.failures, items...)
+	}
+	b.mu.Unlock()
+}
+
+// 429 (queue penuh) dan 5xx layak dicoba lagi, 4xx lain (mapping error, dll) tidak
+func isRetryableStatus(status int) bool {
+	return status == 429 || status >= 500
+}
+
+// formatBulkFailures menampilkan ringkasan item gagal (maks 3 contoh) untuk laporan Telegram
+func formatBulkFailures(stats BulkStats) string {
+	if stats.Failed == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n⚠️ Gagal di-index: %d", stats.Failed))
+	for i, f := range stats.FailedSample {
+		if i >= 3 {
+			break
+		}
+		sb.WriteString(fmt.Sprintf("\n• `%s`: %s", shortID(f.ID), f.Error))
+	}
+	if stats.Failed > 3 {
+		sb.WriteString(fmt.Sprintf("\n_(...%d error lainnya)_", stats.Failed-3))
+	}
+	return sb.String()
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- LIVE PROGRESS INGESTION ---
+
+// Jenis baris yang dideteksi ingester (untuk breakdown di ringkasan akhir)
+const (
+	lineJSONL = "JSONL"
+	lineCombo = "COMBO (:)"
+	linePipe  = "PIPE (|)"
+	lineSQL   = "SQL"
+	lineRaw   = "RAW"
+	lineCSV   = "CSV"
+	lineJSON  = "JSON"
+)
+
+// Interval edit pesan progress (jangan terlalu cepat, Telegram membatasi edit)
+const progressInterval = 5 * time.Second
+
+// IngestProgress dihitung oleh ingester & dibaca oleh reporter dari goroutine lain.
+// Semua method aman dipanggil pada pointer nil (ingest tanpa progress).
+type IngestProgress struct {
+	Source    string
+	TotalSize int64 // Content-Length, -1 jika tidak diketahui
+	StartedAt time.Time
+
+	bytesRead atomic.Int64
+	lines     atomic.Int64
+	skipped   atomic.Int64
+
+	mu        sync.Mutex
+	lineTypes map[string]int64
+
+	// Checkpoint (lihat jobs.go)
+	resume          IngestCheckpoint
+	offset          atomic.Int64
+	header          []string
+	checkpointEvery int64
+	sinceCheckpoint int64
+	onCheckpoint    func(IngestCheckpoint)
+	noResume        bool
+}
+
+// IngestCheckpoint adalah posisi terakhir di source yang datanya sudah pasti ter-index
+type IngestCheckpoint struct {
+	Offset int64
+	Line   int64
+	Header []string
+}
+
+func NewIngestProgress(source string, totalSize int64) *IngestProgress {
+	return &IngestProgress{
+		Source:    source,
+		TotalSize: totalSize,
+		StartedAt: time.Now(),
+		lineTypes: make(map[string]int64),
+	}
+}
+
+// Line mencatat satu baris/record yang berhasil di-parse
+func (p *IngestProgress) Line(kind string) {
+	if p == nil {
+		return
+	}
+	p.lines.Add(1)
+	p.mu.Lock()
+	p.lineTypes[kind]++
+	p.mu.Unlock()
+}
+
+// Skip mencatat baris yang dilewati (terlalu pendek, rusak, gagal decode)
+func (p *IngestProgress) Skip() {
+	if p == nil {
+		return
+	}
+	p.skipped.Add(1)
+}
+
+// Reader membungkus r agar jumlah byte yang dibaca ikut terhitung
+func (p *IngestProgress) Reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &countingReader{r: r, n: &p.bytesRead}
+}
+
+// ResumeFrom dipanggil sebelum ingest jika source dibuka mulai dari cp.Offset
+func (p *IngestProgress) ResumeFrom(cp IngestCheckpoint) {
+	p.resume = cp
+	p.header = cp.Header
+	p.offset.Store(cp.Offset)
+	p.bytesRead.Store(cp.Offset)
+	p.lines.Store(cp.Line)
+}
+
+func (p *IngestProgress) ResumeOffset() int64 {
+	if p == nil {
+		return 0
+	}
+	return p.resume.Offset
+}
+
+// ResumeHeader mengembalikan header CSV dari checkpoint (nil jika mulai dari awal)
+func (p *IngestProgress) ResumeHeader() []string {
+	if p == nil || p.resume.Offset == 0 {
+		return nil
+	}
+	return p.resume.Header
+}
+
+func (p *IngestProgress) SetHeader(header []string) {
+	if p == nil {
+		return
+	}
+	p.header = header
+}
+
+// OnCheckpoint mendaftarkan fn yang dipanggil setiap `every` record.
+// fn berjalan di goroutine ingester, jadi boleh blocking (flush bulk).
+func (p *IngestProgress) OnCheckpoint(every int64, fn func(IngestCheckpoint)) {
+	p.checkpointEvery = every
+	p.onCheckpoint = fn
+}
+
+// Advance dipanggil ingester setelah selesai memproses satu record/baris.
+// relOffset = posisi akhir record relatif terhadap reader yang diterima ingester.
+func (p *IngestProgress) Advance(relOffset int64) {
+	if p == nil {
+		return
+	}
+	if !p.noResume {
+		p.offset.Store(p.resume.Offset + relOffset)
+	}
+
+	if p.onCheckpoint == nil {
+		return
+	}
+	p.sinceCheckpoint++
+	if p.sinceCheckpoint >= p.checkpointEvery {
+		p.sinceCheckpoint = 0
+		p.onCheckpoint(p.Checkpoint())
+	}
+}
+
+// DisableResume dipanggil untuk source terkompresi: checkpoint tetap berjalan (flush & jumlah baris)
+// tapi offset tetap 0, jadi restart mengulang dari awal. Aman karena ID dokumen = fingerprint.
+func (p *IngestProgress) DisableResume() {
+	if p == nil {
+		return
+	}
+	p.noResume = true
+}
+
+func (p *IngestProgress) Checkpoint() IngestCheckpoint {
+	return IngestCheckpoint{Offset: p.offset.Load(), Line: p.lines.Load(), Header: p.header}
+}
+
+func (p *IngestProgress) BytesRead() int64 { return p.bytesRead.Load() }
+func (p *IngestProgress) Lines() int64     { return p.lines.Load() }
+func (p *IngestProgress) Skipped() int64   { return p.skipped.Load() }
+
+func (p *IngestProgress) LineTypes() map[string]int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string]int64, len(p.lineTypes))
+	for k, v := range p.lineTypes {
+		out[k] = v
+	}
+	return out
+}
+
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// startProgressReporter mengedit pesan msgID secara berkala sampai stop() dipanggil
+func startProgressReporter(bot *tgbotapi.BotAPI, chatID int64, msgID int, p *IngestProgress, ing *BulkIngester) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		lastText := ""
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				text := formatProgress(p, ing.Indexed(), ing.FailedCount())
+				if text == lastText {
+					continue
+				}
+				lastText = text
+				bot.Send(tgbotapi.NewEditMessageText(chatID, msgID, text))
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+func formatProgress(p *IngestProgress, indexed int, rejected int) string {
+	elapsed := time.Since(p.StartedAt)
+	read := p.BytesRead()
+	lines := p.Lines()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("⏳ INGESTING `%s`\n", p.Source))
+	if p.TotalSize > 0 {
+		pct := float64(read) / float64(p.TotalSize) * 100
+		sb.WriteString(fmt.Sprintf("📦 Dibaca: %s / %s (%.1f%%)\n", formatBytes(read), formatBytes(p.TotalSize), pct))
+	} else {
+		sb.WriteString(fmt.Sprintf("📦 Dibaca: %s\n", formatBytes(read)))
+	}
+	sb.WriteString(fmt.Sprintf("📄 Baris: %d\n", lines))
+	sb.WriteString(fmt.Sprintf("✅ Ter-index: %d\n", indexed))
+	sb.WriteString(fmt.Sprintf("⏭️ Dilewati: %d | ❌ Ditolak: %d\n", p.Skipped(), rejected))
+
+	// Kecepatan & ETA dihitung dari sesi ini saja (tanpa bagian yang sudah di-resume)
+	sessionRead := read - p.resume.Offset
+	sessionLines := lines - p.resume.Line
+	if secs := elapsed.Seconds(); secs > 0 {
+		sb.WriteString(fmt.Sprintf("⚡ Kecepatan: %.0f baris/s (%s/s)\n", float64(sessionLines)/secs, formatBytes(int64(float64(sessionRead)/secs))))
+		if p.TotalSize > 0 && sessionRead > 0 && read < p.TotalSize {
+			eta := time.Duration(float64(elapsed) * float64(p.TotalSize-read) / float64(sessionRead))
+			sb.WriteString(fmt.Sprintf("🕒 ETA: %s\n", eta.Round(time.Second)))
+		}
+	}
+	return sb.String()
+}
+
+// formatIngestSummary adalah laporan akhir setelah ingest berakhir, icon sesuai status job
+func formatIngestSummary(icon, title string, p *IngestProgress, stats BulkStats) string {
+	elapsed := time.Since(p.StartedAt).Round(time.Second)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s **%s**\nFile: `%s`\n", icon, title, p.Source))
+	sb.WriteString(fmt.Sprintf("📦 Ukuran: %s\n", formatBytes(p.BytesRead())))
+	sb.WriteString(fmt.Sprintf("📄 Total: %d baris\n", p.Lines()))
+	sb.WriteString(fmt.Sprintf("✅ Ter-index: %d\n", stats.Indexed))
+	sb.WriteString(fmt.Sprintf("⏭️ Dilewati: %d | ❌ Ditolak: %d\n", p.Skipped(), stats.Failed))
+	sb.WriteString(fmt.Sprintf("⏱️ Durasi: %s\n", elapsed))
+
+	types := p.LineTypes()
+	if len(types) > 0 {
+		var kinds []string
+		for k := range types {
+			kinds = append(kinds, k)
+		}
+		sort.Slice(kinds, func(i, j int) bool { return types[kinds[i]] > types[kinds[j]] })
+
+		sb.WriteString("\n🧩 Jenis Baris:\n")
+		for _, k := range kinds {
+			sb.WriteString(fmt.Sprintf("• %s: %d\n", k, types[k]))
+		}
+	}
+	sb.WriteString(formatBulkFailures(stats))
+	return sb.String()
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,758 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// --- SQL DUMP INGESTION (MySQL & PostgreSQL) ---
+// Dump dibaca statement per statement secara streaming (INSERT extended bisa ratusan MB).
+// Setiap baris dari INSERT VALUES / COPY FROM stdin menjadi satu dokumen dengan field
+// sesuai nama kolom (dari daftar kolom INSERT/COPY, atau dari CREATE TABLE sebelumnya).
+
+const (
+	sqlWord   = iota // Keyword, angka, identifier tanpa quote
+	sqlIdent         // `ident` (MySQL) atau "ident" (PostgreSQL / string MySQL)
+	sqlString        // 'string', E'string', $$string$$
+	sqlPunct         // ( ) , ; .
+)
+
+// Penanda dialek string di header checkpoint (lihat sqlHeader)
+const (
+	sqlEscapeStrings   = "escape"   // MySQL: backslash adalah escape
+	sqlStandardStrings = "standard" // PostgreSQL standard_conforming_strings = on
+)
+
+var errSQLSyntax = errors.New("sql syntax")
+
+// Kata pembuka definisi di CREATE TABLE yang bukan kolom
+var sqlConstraintWords = map[string]bool{
+	"PRIMARY": true, "KEY": true, "UNIQUE": true, "INDEX": true, "CONSTRAINT": true,
+	"FOREIGN": true, "CHECK": true, "FULLTEXT": true, "SPATIAL": true, "EXCLUDE": true,
+}
+
+type sqlToken struct {
+	kind int
+	text string
+}
+
+func (t sqlToken) is(word string) bool {
+	return t.kind == sqlWord && strings.EqualFold(t.text, word)
+}
+
+func (t sqlToken) isPunct(p string) bool {
+	return t.kind == sqlPunct && t.text == p
+}
+
+type sqlValue struct {
+	text string
+	null bool
+}
+
+// 4. SQL DUMP (.sql)
+func ingestSQLDump(r io.Reader, filename string, ing *BulkIngester, p *IngestProgress) int {
+	d := &sqlDump{
+		lex:      &sqlLexer{r: bufio.NewReaderSize(r, 64*1024)},
+		tables:   make(map[string][]string),
+		filename: filename,
+		ing:      ing,
+		p:        p,
+	}
+
+	// Resume: checkpoint selalu di awal statement, skema tabel statement itu ada di header
+	if h := p.ResumeHeader(); len(h) >= 2 {
+		d.lex.standardStrings = h[0] == sqlStandardStrings
+		d.tables[h[1]] = h[2:]
+	}
+
+	for {
+		if err := d.lex.skipSpace(); err != nil {
+			break
+		}
+		d.stmtStart = d.lex.offset
+
+		first, err := d.lex.next()
+		if err != nil {
+			break
+		}
+		switch {
+		case first.isPunct(";"):
+			continue
+		case first.is("CREATE"):
+			err = d.createTable()
+		case first.is("INSERT"), first.is("REPLACE"):
+			err = d.insert()
+		case first.is("COPY"):
+			err = d.copyFrom()
+		case first.is("SET"):
+			err = d.set()
+		default:
+			err = d.skipStatement()
+		}
+
+		if errors.Is(err, errSQLSyntax) {
+			// Statement rusak / tidak didukung (INSERT ... SELECT, dll) dilewati
+			p.Skip()
+			if !d.lex.last.isPunct(";") {
+				err = d.skipStatement()
+			} else {
+				err = nil
+			}
+		}
+		if err != nil {
+			// EOF atau error dari reader (koneksi putus / job dibatalkan)
+			break
+		}
+	}
+	return d.count
+}
+
+type sqlDump struct {
+	lex       *sqlLexer
+	tables    map[string][]string // Kolom per tabel dari CREATE TABLE
+	stmtStart int64
+
+	filename string
+	ing      *BulkIngester
+	p        *IngestProgress
+	count    int
+}
+
+// CREATE [TEMPORARY] TABLE [IF NOT EXISTS] name ( col type ..., PRIMARY KEY (...), ... ) ...;
+func (d *sqlDump) createTable() error {
+	for {
+		t, err := d.lex.next()
+		if err != nil {
+			return err
+		}
+		if t.is("TABLE") {
+			break
+		}
+		if !t.is("TEMPORARY") && !t.is("TEMP") && !t.is("UNLOGGED") && !t.is("GLOBAL") && !t.is("LOCAL") {
+			// CREATE INDEX / VIEW / FUNCTION / DATABASE, dll
+			return d.skipStatement()
+		}
+	}
+
+	table, err := d.readName()
+	if err != nil {
+		return err
+	}
+	t, err := d.lex.next()
+	if err != nil {
+		return err
+	}
+	if !t.isPunct("(") {
+		return errSQLSyntax
+	}
+
+	var cols []string
+	depth, atStart := 1, true
+	for depth > 0 {
+		t, err := d.lex.next()
+		if err != nil {
+			return err
+		}
+		if atStart {
+			atStart = false
+			if t.kind == sqlIdent || (t.kind == sqlWord && !sqlConstraintWords[strings.ToUpper(t.text)]) {
+				cols = append(cols, t.text)
+			}
+		}
+		switch {
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			depth--
+		case t.isPunct(",") && depth == 1:
+			atStart = true
+		case t.isPunct(";"):
+			return errSQLSyntax
+		}
+	}
+	d.tables[table] = cols
+
+	// Sisa statement: ENGINE=InnoDB DEFAULT CHARSET=...;
+	return d.skipStatement()
+}
+
+// INSERT [IGNORE] INTO name [(cols)] VALUES (...),(...) [ON DUPLICATE KEY UPDATE ...];
+func (d *sqlDump) insert() error {
+	for {
+		t, err := d.lex.next()
+		if err != nil {
+			return err
+		}
+		if t.is("INTO") {
+			break
+		}
+		if t.kind != sqlWord {
+			return errSQLSyntax
+		}
+	}
+
+	table, err := d.readName()
+	if err != nil {
+		return err
+	}
+	cols := d.tables[table]
+
+	t, err := d.lex.next()
+	if err != nil {
+		return err
+	}
+	if t.isPunct("(") {
+		if cols, err = d.readNameList(); err != nil {
+			return err
+		}
+		if t, err = d.lex.next(); err != nil {
+			return err
+		}
+	}
+	if !t.is("VALUES") && !t.is("VALUE") {
+		return errSQLSyntax
+	}
+	d.p.SetHeader(d.sqlHeader(table, cols))
+
+	for {
+		t, err := d.lex.next()
+		if err != nil {
+			return err
+		}
+		if !t.isPunct("(") {
+			return errSQLSyntax
+		}
+		values, err := d.readTuple()
+		if err != nil {
+			return err
+		}
+		d.emit(table, cols, values)
+
+		if t, err = d.lex.next(); err != nil {
+			return err
+		}
+		if t.isPunct(",") {
+			continue
+		}
+		if t.isPunct(";") {
+			return nil
+		}
+		// ON DUPLICATE KEY UPDATE / ON CONFLICT / RETURNING
+		return d.skipStatement()
+	}
+}
+
+// COPY name [(cols)] FROM stdin; diikuti baris tab-separated sampai `\.`
+// Checkpoint tetap di awal statement COPY, resume mengulang blok ini (ID dokumen deterministik).
+func (d *sqlDump) copyFrom() error {
+	table, err := d.readName()
+	if err != nil {
+		return err
+	}
+	cols := d.tables[table]
+
+	t, err := d.lex.next()
+	if err != nil {
+		return err
+	}
+	if t.isPunct("(") {
+		if cols, err = d.readNameList(); err != nil {
+			return err
+		}
+		if t, err = d.lex.next(); err != nil {
+			return err
+		}
+	}
+	if !t.is("FROM") {
+		return errSQLSyntax
+	}
+	if t, err = d.lex.next(); err != nil {
+		return err
+	}
+	if !t.is("STDIN") {
+		// COPY ... FROM 'file' tidak membawa data di dump
+		return errSQLSyntax
+	}
+	for !t.isPunct(";") {
+		if t, err = d.lex.next(); err != nil {
+			return err
+		}
+	}
+	if err := d.lex.skipLine(); err != nil {
+		return err
+	}
+	d.p.SetHeader(d.sqlHeader(table, cols))
+
+	for {
+		line, err := d.lex.readLine()
+		if err != nil && line == "" {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == `\.` {
+			return nil
+		}
+
+		fields := strings.Split(line, "\t")
+		values := make([]sqlValue, len(fields))
+		for i, f := range fields {
+			if f == `\N` {
+				values[i].null = true
+			} else {
+				values[i].text = unescapeCopyField(f)
+			}
+		}
+		d.emit(table, cols, values)
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// SET standard_conforming_strings = on; menentukan arti backslash di string PostgreSQL
+func (d *sqlDump) set() error {
+	var tokens []sqlToken
+	for {
+		t, err := d.lex.next()
+		if err != nil {
+			return err
+		}
+		if t.isPunct(";") {
+			break
+		}
+		tokens = append(tokens, t)
+	}
+	if len(tokens) >= 2 && strings.EqualFold(tokens[0].text, "standard_conforming_strings") {
+		d.lex.standardStrings = strings.EqualFold(tokens[len(tokens)-1].text, "on")
+	}
+	return nil
+}
+
+func (d *sqlDump) skipStatement() error {
+	for {
+		t, err := d.lex.next()
+		if err != nil {
+			return err
+		}
+		if t.isPunct(";") {
+			return nil
+		}
+	}
+}
+
+// readName membaca nama tabel (boleh `db`.`tabel` / "public"."users"), return bagian terakhir
+func (d *sqlDump) readName() (string, error) {
+	t, err := d.lex.next()
+	if err != nil {
+		return "", err
+	}
+	if t.is("IF") {
+		// IF NOT EXISTS
+		d.lex.next()
+		d.lex.next()
+		if t, err = d.lex.next(); err != nil {
+			return "", err
+		}
+	}
+	if t.is("ONLY") {
+		t, err = d.lex.next()
+		if err != nil {
+			return "", err
+		}
+	}
+	if t.kind != sqlWord && t.kind != sqlIdent {
+		return "", errSQLSyntax
+	}
+
+	name := t.text
+	for {
+		next, err := d.lex.peek()
+		if err != nil || !next.isPunct(".") {
+			return name, nil
+		}
+		d.lex.next()
+		if t, err = d.lex.next(); err != nil {
+			return "", err
+		}
+		name = t.text
+	}
+}
+
+// readNameList membaca `(a, b, c)` setelah "(" terbaca
+func (d *sqlDump) readNameList() ([]string, error) {
+	var names []string
+	for {
+		t, err := d.lex.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case t.isPunct(")"):
+			return names, nil
+		case t.isPunct(";"):
+			return nil, errSQLSyntax
+		case t.kind == sqlWord || t.kind == sqlIdent:
+			names = append(names, t.text)
+		}
+	}
+}
+
+// readTuple membaca isi `(...)` setelah "(" terbaca. Nilai bisa berupa ekspresi
+// (_binary '...', 'a'::text, NOW(), -1.5), yang diambil adalah string-nya jika ada.
+func (d *sqlDump) readTuple() ([]sqlValue, error) {
+	var values []sqlValue
+	var cur []sqlToken
+	depth := 0
+	for {
+		t, err := d.lex.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case t.isPunct(")") && depth == 0:
+			return append(values, tupleValue(cur)), nil
+		case t.isPunct(",") && depth == 0:
+			values = append(values, tupleValue(cur))
+			cur = nil
+		case t.isPunct(";"):
+			return nil, errSQLSyntax
+		default:
+			if t.isPunct("(") {
+				depth++
+			} else if t.isPunct(")") {
+				depth--
+			}
+			cur = append(cur, t)
+		}
+	}
+}
+
+func tupleValue(tokens []sqlToken) sqlValue {
+	if len(tokens) == 0 || (len(tokens) == 1 && tokens[0].is("NULL")) {
+		return sqlValue{null: true}
+	}
+	var raw strings.Builder
+	for _, t := range tokens {
+		if t.kind == sqlString || t.kind == sqlIdent {
+			return sqlValue{text: t.text}
+		}
+		raw.WriteString(t.text)
+	}
+	return sqlValue{text: raw.String()}
+}
+
+func (d *sqlDump) emit(table string, cols []string, values []sqlValue) {
+	doc := make(map[string]interface{})
+	var txtBuf []string
+	for i, v := range values {
+		if v.null {
+			continue
+		}
+		name := fmt.Sprintf("col_%d", i+1)
+		if i < len(cols) {
+			name = strings.TrimSpace(cols[i])
+		}
+		doc[name] = v.text
+		txtBuf = append(txtBuf, v.text)
+	}
+	doc["leak_source"] = d.filename
+	doc["sql_table"] = table
+	doc["data_type"] = "sql_row"
+	doc["full_text"] = strings.Join(txtBuf, " ")
+
+	d.ing.Add(doc, generateFingerprint(table+"\x00"+strings.Join(txtBuf, "\x00")+d.filename))
+	d.p.Line(lineSQL)
+	d.p.Advance(d.stmtStart)
+	d.count++
+}
+
+// sqlHeader: [dialek string, tabel, kolom...] disimpan di checkpoint untuk resume
+func (d *sqlDump) sqlHeader(table string, cols []string) []string {
+	dialect := sqlEscapeStrings
+	if d.lex.standardStrings {
+		dialect = sqlStandardStrings
+	}
+	return append([]string{dialect, table}, cols...)
+}
+
+// sqlLexer memecah dump menjadi token sambil menghitung offset byte (untuk checkpoint)
+type sqlLexer struct {
+	r      *bufio.Reader
+	offset int64
+
+	standardStrings bool // true: backslash di '...' bukan escape (PostgreSQL)
+	peeked          *sqlToken
+	last            sqlToken
+}
+
+func (l *sqlLexer) readByte() (byte, error) {
+	b, err := l.r.ReadByte()
+	if err == nil {
+		l.offset++
+	}
+	return b, err
+}
+
+func (l *sqlLexer) peekByte() (byte, bool) {
+	b, err := l.r.Peek(1)
+	if err != nil {
+		return 0, false
+	}
+	return b[0], true
+}
+
+func (l *sqlLexer) readLine() (string, error) {
+	line, err := l.r.ReadString('\n')
+	l.offset += int64(len(line))
+	return line, err
+}
+
+func (l *sqlLexer) skipLine() error {
+	_, err := l.readLine()
+	return err
+}
+
+// skipSpace melewati whitespace & komentar (-- ..., # ..., /* ... */ termasuk /*!40101 ... */)
+func (l *sqlLexer) skipSpace() error {
+	for {
+		buf, err := l.r.Peek(2)
+		if len(buf) == 0 {
+			return err
+		}
+		switch {
+		case buf[0] == ' ' || buf[0] == '\t' || buf[0] == '\n' || buf[0] == '\r':
+			l.readByte()
+		case buf[0] == '#' || string(buf) == "--":
+			if err := l.skipLine(); err != nil {
+				return err
+			}
+		case string(buf) == "/*":
+			l.readByte()
+			l.readByte()
+			if err := l.skipBlockComment(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func (l *sqlLexer) skipBlockComment() error {
+	var prev byte
+	for {
+		b, err := l.readByte()
+		if err != nil {
+			return err
+		}
+		if prev == '*' && b == '/' {
+			return nil
+		}
+		prev = b
+	}
+}
+
+func (l *sqlLexer) peek() (sqlToken, error) {
+	if l.peeked == nil {
+		t, err := l.scan()
+		if err != nil {
+			return t, err
+		}
+		l.peeked = &t
+	}
+	return *l.peeked, nil
+}
+
+func (l *sqlLexer) next() (sqlToken, error) {
+	if l.peeked != nil {
+		t := *l.peeked
+		l.peeked = nil
+		l.last = t
+		return t, nil
+	}
+	t, err := l.scan()
+	if err == nil {
+		l.last = t
+	}
+	return t, err
+}
+
+func (l *sqlLexer) scan() (sqlToken, error) {
+	if err := l.skipSpace(); err != nil {
+		return sqlToken{}, err
+	}
+	b, err := l.readByte()
+	if err != nil {
+		return sqlToken{}, err
+	}
+
+	switch b {
+	case '(', ')', ',', ';', '.':
+		return sqlToken{kind: sqlPunct, text: string(b)}, nil
+	case '\'':
+		s, err := l.readQuoted('\'', !l.standardStrings)
+		return sqlToken{kind: sqlString, text: s}, err
+	case '"':
+		// PostgreSQL: identifier, MySQL: string. Keduanya diperlakukan sebagai nama/nilai.
+		s, err := l.readQuoted('"', !l.standardStrings)
+		return sqlToken{kind: sqlIdent, text: s}, err
+	case '`':
+		s, err := l.readQuoted('`', false)
+		return sqlToken{kind: sqlIdent, text: s}, err
+	case '$':
+		if tag, ok := l.dollarTag(); ok {
+			s, err := l.readDollarQuoted(tag)
+			return sqlToken{kind: sqlString, text: s}, err
+		}
+	case 'E', 'e':
+		// E'...' PostgreSQL selalu memakai backslash escape
+		if c, ok := l.peekByte(); ok && c == '\'' {
+			l.readByte()
+			s, err := l.readQuoted('\'', true)
+			return sqlToken{kind: sqlString, text: s}, err
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteByte(b)
+	for {
+		c, ok := l.peekByte()
+		if !ok || isSQLDelimiter(c) {
+			break
+		}
+		l.readByte()
+		sb.WriteByte(c)
+	}
+	return sqlToken{kind: sqlWord, text: sb.String()}, nil
+}
+
+func isSQLDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '(', ')', ',', ';', '.', '\'', '"', '`':
+		return true
+	}
+	return false
+}
+
+// readQuoted membaca sampai quote penutup. Quote yang ditulis dua kali = quote literal.
+func (l *sqlLexer) readQuoted(quote byte, backslashEscapes bool) (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := l.readByte()
+		if err != nil {
+			return "", io.ErrUnexpectedEOF
+		}
+		if b == '\\' && backslashEscapes {
+			c, err := l.readByte()
+			if err != nil {
+				return "", io.ErrUnexpectedEOF
+			}
+			sb.WriteString(unescapeSQLChar(c))
+			continue
+		}
+		if b == quote {
+			if c, ok := l.peekByte(); ok && c == quote {
+				l.readByte()
+				sb.WriteByte(quote)
+				continue
+			}
+			return sb.String(), nil
+		}
+		sb.WriteByte(b)
+	}
+}
+
+// dollarTag mengenali pembuka $$ atau $tag$ (setelah '$' pertama terbaca)
+func (l *sqlLexer) dollarTag() (string, bool) {
+	for n := 1; n <= 64; n++ {
+		buf, err := l.r.Peek(n)
+		if err != nil {
+			return "", false
+		}
+		c := buf[n-1]
+		if c == '$' {
+			tag := "$" + string(buf)
+			l.r.Discard(n)
+			l.offset += int64(n)
+			return tag, true
+		}
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !isLetter && (n == 1 || c < '0' || c > '9') {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func (l *sqlLexer) readDollarQuoted(tag string) (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := l.readByte()
+		if err != nil {
+			return "", io.ErrUnexpectedEOF
+		}
+		sb.WriteByte(b)
+		if b == '$' && strings.HasSuffix(sb.String(), tag) {
+			s := sb.String()
+			return s[:len(s)-len(tag)], nil
+		}
+	}
+}
+
+// Escape backslash gaya MySQL (juga E'...' PostgreSQL)
+func unescapeSQLChar(c byte) string {
+	switch c {
+	case '0':
+		return "\x00"
+	case 'b':
+		return "\b"
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case 'Z':
+		return "\x1a"
+	case '%', '_':
+		// MySQL mempertahankan backslash untuk wildcard LIKE
+		return "\\" + string(c)
+	}
+	return string(c)
+}
+
+// unescapeCopyField mengubah escape format text COPY (\t, \n, \\, ...) menjadi karakter aslinya
+func unescapeCopyField(f string) string {
+	if !strings.Contains(f, `\`) {
+		return f
+	}
+	var sb strings.Builder
+	for i := 0; i < len(f); i++ {
+		if f[i] != '\\' || i+1 == len(f) {
+			sb.WriteByte(f[i])
+			continue
+		}
+		i++
+		switch f[i] {
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'v':
+			sb.WriteByte('\v')
+		default:
+			sb.WriteByte(f[i])
+		}
+	}
+	return sb.String()
+}
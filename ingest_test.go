@@ -0,0 +1,489 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyStore rejects items with a given status on the first attempt only.
+type flakyStore struct {
+	*MemoryStore
+	mu       sync.Mutex
+	attempts map[string]int
+	status   map[string]int
+}
+
+func (f *flakyStore) BulkIndex(docs []BulkDocument) ([]BulkItemResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var failed []BulkItemResult
+	var ok []BulkDocument
+	for i, d := range docs {
+		f.attempts[d.ID]++
+		if st, reject := f.status[d.ID]; reject && (st != 429 || f.attempts[d.ID] == 1) {
+			failed = append(failed, BulkItemResult{ID: d.ID, Index: i, Status: st, Error: "rejected"})
+			continue
+		}
+		ok = append(ok, d)
+	}
+	f.MemoryStore.BulkIndex(ok)
+	return failed, nil
+}
+
+// TestBulkIngesterRetry tests batching, retry of 429 items and reporting of permanent failures.
+func TestBulkIngesterRetry(t *testing.T) {
+	store := &flakyStore{
+		MemoryStore: NewMemoryStore(),
+		attempts:    make(map[string]int),
+		status:      map[string]int{"doc-3": 429, "doc-7": 400},
+	}
+	cfg := BulkConfig{BatchDocs: 4, BatchBytes: 1 << 20, Workers: 2, MaxRetries: 2, Backoff: time.Millisecond}
+	ing := NewBulkIngester(store, cfg)
+
+	for i := 0; i < 10; i++ {
+		ing.Add(map[string]interface{}{"full_text": "x"}, "doc-"+string(rune('0'+i)))
+	}
+	stats := ing.Close()
+
+	if stats.Submitted != 10 {
+		t.Errorf("Submitted = %d, want 10", stats.Submitted)
+	}
+	if stats.Indexed != 9 {
+		t.Errorf("Indexed = %d, want 9", stats.Indexed)
+	}
+	if stats.Failed != 1 || len(stats.FailedSample) != 1 || stats.FailedSample[0].ID != "doc-7" {
+		t.Errorf("Failed = %d %+v, want only doc-7", stats.Failed, stats.FailedSample)
+	}
+	if store.attempts["doc-3"] != 2 {
+		t.Errorf("doc-3 attempts = %d, want 2", store.attempts["doc-3"])
+	}
+}
+
+// rejectStore rejects every item of the first `calls` requests (0 = all) with status.
+type rejectStore struct {
+	*MemoryStore
+	mu     sync.Mutex
+	status int
+	calls  int
+	made   int
+	sent   int
+}
+
+func (r *rejectStore) BulkIndex(docs []BulkDocument) ([]BulkItemResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.made++
+	r.sent += len(docs)
+	if r.calls > 0 && r.made > r.calls {
+		return r.MemoryStore.BulkIndex(docs)
+	}
+	failed := make([]BulkItemResult, len(docs))
+	for i, d := range docs {
+		failed[i] = BulkItemResult{ID: d.ID, Index: i, Status: r.status, Error: "rejected"}
+	}
+	return failed, nil
+}
+
+// TestBulkIngesterDuplicateIDs tests that identical lines sharing an ID are retried individually.
+func TestBulkIngesterDuplicateIDs(t *testing.T) {
+	store := &rejectStore{MemoryStore: NewMemoryStore(), status: 429, calls: 1}
+	ing := NewBulkIngester(store, BulkConfig{BatchDocs: 10, BatchBytes: 1 << 20, Workers: 1, MaxRetries: 2, Backoff: time.Millisecond})
+	for i := 0; i < 3; i++ {
+		ing.Add(map[string]interface{}{"full_text": "x"}, "dup")
+	}
+	stats := ing.Close()
+
+	// Percobaan pertama menolak ketiganya, ketiganya harus dikirim ulang
+	if store.sent != 6 || stats.Indexed != 3 || stats.Failed != 0 {
+		t.Errorf("sent = %d, indexed = %d, failed = %d, want 6/3/0", store.sent, stats.Indexed, stats.Failed)
+	}
+}
+
+// TestBulkIngesterFailureSample tests that failures are counted in full but only sampled.
+func TestBulkIngesterFailureSample(t *testing.T) {
+	ing := NewBulkIngester(&rejectStore{MemoryStore: NewMemoryStore(), status: 400}, BulkConfig{BatchDocs: 7, BatchBytes: 1 << 20, Workers: 2})
+	for i := 0; i < 50; i++ {
+		ing.Add(map[string]interface{}{"full_text": "x"}, fmt.Sprint("doc-", i))
+	}
+	stats := ing.Close()
+
+	if stats.Failed != 50 || len(stats.FailedSample) != bulkFailureSamples {
+		t.Errorf("Failed = %d with %d samples, want 50 with %d", stats.Failed, len(stats.FailedSample), bulkFailureSamples)
+	}
+	if got := formatBulkFailures(stats); !strings.Contains(got, "Gagal di-index: 50") || !strings.Contains(got, "47 error lainnya") {
+		t.Errorf("formatBulkFailures() = %q", got)
+	}
+}
+
+// TestBulkIngesterCancelBackoff tests that cancelling the job stops a pending retry backoff.
+func TestBulkIngesterCancelBackoff(t *testing.T) {
+	store := &rejectStore{MemoryStore: NewMemoryStore(), status: 429}
+	ctx, cancel := context.WithCancel(context.Background())
+	ing := NewBulkIngesterContext(ctx, store, BulkConfig{BatchDocs: 1, BatchBytes: 1 << 20, Workers: 1, MaxRetries: 5, Backoff: time.Hour})
+	ing.Add(map[string]interface{}{"full_text": "x"}, "doc-1")
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	done := make(chan BulkStats)
+	go func() { done <- ing.Close() }()
+	select {
+	case stats := <-done:
+		if stats.Failed != 1 || store.sent != 1 {
+			t.Errorf("failed = %d, sent = %d, want 1/1", stats.Failed, store.sent)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() still waiting on backoff after cancel")
+	}
+}
+
+// TestIngestStreamText tests that combo lines are parsed and fed into the bulk pipeline.
+func TestIngestStreamText(t *testing.T) {
+	store := NewMemoryStore()
+	ing := NewBulkIngester(store, BulkConfig{BatchDocs: 2, BatchBytes: 1 << 20, Workers: 1})
+
+	input := "rudi@gmail.com:rahasia123\nadmin|qwerty\nxx\n{\"email\":\"sudi@yahoo.com\"}\n"
+	total := ingestStreamText(strings.NewReader(input), "combo.txt", ing, nil)
+	stats := ing.Close()
+
+	if total != 3 || stats.Indexed != 3 {
+		t.Fatalf("total = %d, indexed = %d, want 3/3", total, stats.Indexed)
+	}
+	result, _ := store.SearchBreaches("email:rudi@gmail.com", 10)
+	if result.Hits.Total.Value != 1 || result.Hits.Hits[0].Source["password"] != "rahasia123" {
+		t.Errorf("combo line not parsed: %+v", result.Hits.Hits)
+	}
+}
+
+// TestIngestProgress tests byte, line, skip and line-type accounting during ingest.
+func TestIngestProgress(t *testing.T) {
+	input := "rudi@gmail.com:rahasia123\nadmin|qwerty\nxx\n{\"email\":\"sudi@yahoo.com\"}\nINSERT INTO users VALUES (1)\n"
+	p := NewIngestProgress("mix.txt", int64(len(input)))
+	ing := NewBulkIngester(NewMemoryStore(), defaultBulkConfig())
+
+	ingestStreamText(p.Reader(strings.NewReader(input)), "mix.txt", ing, p)
+	ing.Close()
+
+	if p.BytesRead() != int64(len(input)) {
+		t.Errorf("BytesRead() = %d, want %d", p.BytesRead(), len(input))
+	}
+	if p.Lines() != 4 || p.Skipped() != 1 {
+		t.Errorf("Lines() = %d, Skipped() = %d, want 4 and 1", p.Lines(), p.Skipped())
+	}
+	types := p.LineTypes()
+	for _, kind := range []string{lineCombo, linePipe, lineJSONL, lineSQL} {
+		if types[kind] != 1 {
+			t.Errorf("LineTypes()[%s] = %d, want 1", kind, types[kind])
+		}
+	}
+}
+
+// TestIngestResume tests that every ingester can continue from a checkpoint offset
+// without losing or duplicating records.
+func TestIngestResume(t *testing.T) {
+	resumeTests := []struct {
+		name   string
+		file   string
+		input  string
+		expect int
+	}{
+		{"text", "combo.txt", "a1@x.com:pass1\r\na2@x.com:pass2\na3@x.com:pass3\na4@x.com:pass4\n", 4},
+		{"csv", "dump.csv", "email,password\na1@x.com,pass1\na2@x.com,pass2\na3@x.com,pass3\na4@x.com,pass4\n", 4},
+		{"json", "dump.json", `[ {"email":"a1@x.com"}, {"email":"a2@x.com"},{"email":"a3@x.com"} ,{"email":"a4@x.com"}]`, 4},
+	}
+
+	for _, tt := range resumeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+
+			// 1. Sesi pertama: ambil checkpoint setelah 2 record lalu "crash"
+			var cp IngestCheckpoint
+			p := NewIngestProgress(tt.file, int64(len(tt.input)))
+			ing := NewBulkIngester(store, defaultBulkConfig())
+			p.OnCheckpoint(2, func(c IngestCheckpoint) {
+				if cp.Offset == 0 {
+					ing.Flush()
+					cp = c
+				}
+			})
+			ingestByExtension(strings.NewReader(tt.input), tt.file, ing, p)
+			ing.Close()
+			if cp.Offset == 0 {
+				t.Fatal("no checkpoint recorded")
+			}
+
+			// 2. Sesi kedua: mulai dari offset checkpoint di store baru
+			resumed := NewMemoryStore()
+			p2 := NewIngestProgress(tt.file, int64(len(tt.input)))
+			p2.ResumeFrom(cp)
+			ing2 := NewBulkIngester(resumed, defaultBulkConfig())
+			ingestByExtension(strings.NewReader(tt.input[cp.Offset:]), tt.file, ing2, p2)
+			stats := ing2.Close()
+
+			if stats.Indexed != tt.expect-2 {
+				t.Errorf("resumed session indexed %d, want %d", stats.Indexed, tt.expect-2)
+			}
+			if p2.Lines() != int64(tt.expect) {
+				t.Errorf("Lines() after resume = %d, want %d", p2.Lines(), tt.expect)
+			}
+		})
+	}
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write(data)
+	gw.Close()
+	return buf.Bytes()
+}
+
+// TestIngestArchive tests magic-byte detection, per-member routing and leak_source naming.
+func TestIngestArchive(t *testing.T) {
+	members := map[string]string{
+		"users.csv":        "email,password\na1@x.com,pass1\na2@x.com,pass2\n",
+		"dir/combo.txt":    "a3@x.com:pass3\n",
+		"__MACOSX/._x.csv": "junk,junk\n1,2\n",
+	}
+	archiveTests := []struct {
+		name    string
+		file    string
+		data    []byte
+		sources []string
+	}{
+		{"zip", "leak.zip", zipArchive(t, members), []string{"leak.zip/users.csv", "leak.zip/dir/combo.txt"}},
+		{"tar.gz", "leak.tar.gz", tarGzArchive(t, members), []string{"leak.tar.gz/users.csv", "leak.tar.gz/dir/combo.txt"}},
+		{"gz", "dump.csv.gz", gzipBytes([]byte(members["users.csv"])), []string{"dump.csv.gz"}},
+	}
+
+	for _, tt := range archiveTests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			p := NewIngestProgress(tt.file, int64(len(tt.data)))
+			ing := NewBulkIngester(store, defaultBulkConfig())
+			_, err := ingestSource(context.Background(), bytes.NewReader(tt.data), tt.file, ing, p, defaultArchiveLimits())
+			ing.Close()
+			if err != nil {
+				t.Fatalf("ingestSource() error = %v", err)
+			}
+
+			for _, src := range tt.sources {
+				if n := store.DeleteBySource(src); n == 0 {
+					t.Errorf("no documents with leak_source %q", src)
+				}
+			}
+			if left := store.GetClusterStats().TotalRecords; left != 0 {
+				t.Errorf("%d documents with unexpected leak_source", left)
+			}
+			if p.Checkpoint().Offset != 0 {
+				t.Errorf("archive checkpoint offset = %d, want 0", p.Checkpoint().Offset)
+			}
+		})
+	}
+}
+
+// TestIngestArchiveLimits tests that zip bombs are stopped by ratio, size and member limits.
+func TestIngestArchiveLimits(t *testing.T) {
+	zeros := gzipBytes(make([]byte, 8<<20))
+	many := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		many[strings.Repeat("x", i+1)+".txt"] = "a@x.com:pass\n"
+	}
+
+	limitTests := []struct {
+		name   string
+		data   []byte
+		limits ArchiveLimits
+	}{
+		{"ratio", zeros, ArchiveLimits{MaxExpandedBytes: 1 << 30, MaxMembers: 100, MaxRatio: 100}},
+		{"expanded", zeros, ArchiveLimits{MaxExpandedBytes: 2 << 20, MaxMembers: 100, MaxRatio: 1e6}},
+		{"members", zipArchive(t, many), ArchiveLimits{MaxExpandedBytes: 1 << 30, MaxMembers: 10, MaxRatio: 1e6}},
+	}
+
+	for _, tt := range limitTests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := NewBulkIngester(NewMemoryStore(), defaultBulkConfig())
+			_, err := ingestSource(context.Background(), bytes.NewReader(tt.data), "bomb.gz", ing, nil, tt.limits)
+			ing.Close()
+			if !errors.Is(err, errArchiveLimit) {
+				t.Errorf("ingestSource() error = %v, want errArchiveLimit", err)
+			}
+		})
+	}
+}
+
+// TestIngestSQLDump tests row extraction from MySQL and PostgreSQL dumps.
+func TestIngestSQLDump(t *testing.T) {
+	mysqlDump := "-- MySQL dump 10.13\n" +
+		"/*!40101 SET NAMES utf8mb4 */;\n" +
+		"CREATE TABLE `users` (\n" +
+		"  `id` int(11) NOT NULL AUTO_INCREMENT,\n" +
+		"  `email` varchar(255) DEFAULT NULL,\n" +
+		"  `password` varchar(255) DEFAULT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `idx_email` (`email`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n" +
+		"INSERT INTO `users` VALUES (1,'o\\'brien@x.com','it''s;secret'),(2,'b@x.com',NULL),\n" +
+		"(3,'c@x.com',_binary 'h(a)sh');\n" +
+		"INSERT INTO `shop`.`orders` (`order_id`, `buyer`) VALUES (10, 'b@x.com');\n"
+
+	pgDump := "SET standard_conforming_strings = on;\n" +
+		"CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN RETURN NEW; END; $$ LANGUAGE plpgsql;\n" +
+		"CREATE TABLE public.accounts (\n    id integer NOT NULL,\n    username text,\n    phone text\n);\n" +
+		"COPY public.accounts (id, username, phone) FROM stdin;\n" +
+		"1\tbudi\t0812\n" +
+		"2\tsiti\\tx\t\\N\n" +
+		"\\.\n" +
+		"INSERT INTO public.accounts VALUES (3, 'c:\\path', E'a\\nb');\n"
+
+	sqlTests := []struct {
+		name  string
+		input string
+		rows  int
+		query string
+		field string
+		want  string
+	}{
+		{"mysql escape", mysqlDump, 4, "email:o'brien@x.com", "password", "it's;secret"},
+		{"mysql binary", mysqlDump, 4, "email:c@x.com", "password", "h(a)sh"},
+		{"mysql column list", mysqlDump, 4, "buyer:b@x.com", "sql_table", "orders"},
+		{"pg copy", pgDump, 3, "username:budi", "phone", "0812"},
+		{"pg copy escape", pgDump, 3, "id:2", "username", "siti\tx"},
+		{"pg standard strings", pgDump, 3, "id:3", "username", `c:\path`},
+		{"pg E string", pgDump, 3, "id:3", "phone", "a\nb"},
+	}
+
+	for _, tt := range sqlTests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			ing := NewBulkIngester(store, defaultBulkConfig())
+			rows := ingestByExtension(strings.NewReader(tt.input), "dump.sql", ing, nil)
+			ing.Close()
+
+			if rows != tt.rows {
+				t.Errorf("rows = %d, want %d", rows, tt.rows)
+			}
+			result, _ := store.SearchBreaches(tt.query, 10)
+			if result.Hits.Total.Value != 1 {
+				t.Fatalf("SearchBreaches(%q) = %d hits, want 1", tt.query, result.Hits.Total.Value)
+			}
+			if got := result.Hits.Hits[0].Source[tt.field]; got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestOpenSourceCancel tests that a stalled download is aborted by /cancel and by the stall timeout.
+func TestOpenSourceCancel(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a@x.com:pass1\n"))
+		w.(http.Flusher).Flush()
+		select { // Server berhenti mengirim data
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	jm := NewJobManager(nil, NewMemoryStore(), 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	body, _, err := jm.openSource(ctx, IngestJobRecord{Kind: "url", URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := io.ReadAll(body); err == nil {
+		t.Error("read after cancel succeeded, want error")
+	}
+	body.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stalled := newStallReader(resp.Body, func() { resp.Body.Close() }, 50*time.Millisecond)
+	if _, err := io.ReadAll(stalled); err == nil || !strings.Contains(err.Error(), "macet") {
+		t.Errorf("stalled read error = %v, want stall error", err)
+	}
+	stalled.Close()
+}
+
+// TestJobResume tests that only FAILED jobs with a read failure can be resumed, and only once.
+func TestJobResume(t *testing.T) {
+	store := NewMemoryStore()
+	store.SaveIngestJob(IngestJobRecord{ID: "J-FAIL", Source: "big.csv", Kind: "url", Status: jobFailed, Offset: 4096, Line: 120, Error: "unexpected EOF", Resumable: true})
+	store.SaveIngestJob(IngestJobRecord{ID: "J-BOMB", Source: "bomb.zip", Kind: "url", Status: jobFailed, Error: "arsip melebihi batas"})
+	store.SaveIngestJob(IngestJobRecord{ID: "J-DONE", Source: "ok.csv", Kind: "url", Status: jobDone})
+	jm := NewJobManager(nil, store, 0)
+	jm.ResumePending()
+
+	rec, err := jm.Resume("J-FAIL")
+	if err != nil || rec.Status != jobQueued || rec.Offset != 4096 || rec.Error != "" {
+		t.Fatalf("Resume(J-FAIL) = %+v, %v, want queued from offset 4096", rec, err)
+	}
+	if len(jm.queue) != 1 {
+		t.Errorf("queue length = %d, want 1", len(jm.queue))
+	}
+	if _, err := jm.Resume("J-FAIL"); err == nil {
+		t.Error("second Resume succeeded, want error (job already queued)")
+	}
+	if _, err := jm.Resume("J-DONE"); err == nil {
+		t.Error("Resume(J-DONE) succeeded, want error")
+	}
+	if _, err := jm.Resume("J-BOMB"); err == nil {
+		t.Error("Resume(J-BOMB) succeeded, want error (archive failures are not resumable)")
+	}
+}
+
+// TestFormatIngestSummaryIcon tests that failed and cancelled jobs are not reported with a success tick.
+func TestFormatIngestSummaryIcon(t *testing.T) {
+	p := NewIngestProgress("dump.csv", 0)
+	for status, icon := range jobStatusIcon {
+		if got := formatIngestSummary(icon, "JOB", p, BulkStats{}); !strings.HasPrefix(got, icon+" **JOB**") {
+			t.Errorf("%s summary = %q, want prefix %s", status, got, icon)
+		}
+	}
+}
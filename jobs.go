@@ -0,0 +1,531 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- BACKGROUND INGEST JOBS ---
+
+const (
+	jobQueued    = "QUEUED"
+	jobRunning   = "RUNNING"
+	jobDone      = "DONE"
+	jobFailed    = "FAILED"
+	jobCancelled = "CANCELLED"
+)
+
+const (
+	jobQueueSize    = 100
+	checkpointEvery = 20000 // Simpan checkpoint setiap N record
+	jobListLimit    = 15    // Jumlah job yang tampil di /jobs
+
+	downloadStallTimeout = 2 * time.Minute // Download dianggap macet jika tidak ada data selama ini
+)
+
+// ingestHTTPClient: tanpa Timeout total (file bisa berjam-jam), tapi koneksi & header dibatasi.
+// Body yang macet di tengah jalan ditangani stallReader.
+var ingestHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   15 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		IdleConnTimeout:       90 * time.Second,
+	},
+}
+
+var jobStatusIcon = map[string]string{
+	jobQueued:    "🕓",
+	jobRunning:   "⏳",
+	jobDone:      "✅",
+	jobFailed:    "❌",
+	jobCancelled: "🚫",
+}
+
+type IngestJob struct {
+	IngestJobRecord
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	progress *IngestProgress
+}
+
+// JobManager menjalankan ingest di background agar update loop tidak terblokir.
+// Status & checkpoint disimpan ke Store sehingga job bisa dilanjutkan setelah restart.
+type JobManager struct {
+	bot   *tgbotapi.BotAPI
+	store Store
+	queue chan *IngestJob
+
+	mu   sync.Mutex
+	jobs map[string]*IngestJob
+
+	onFinish func(rec IngestJobRecord) // Dipanggil setelah job yang meng-index data berakhir
+}
+
+// ingestWorkersFromEnv membaca INGEST_WORKERS (jumlah job yang jalan bersamaan, default 1)
+func ingestWorkersFromEnv() int {
+	if v, err := strconv.Atoi(os.Getenv("INGEST_WORKERS")); err == nil && v > 0 {
+		return v
+	}
+	return 1
+}
+
+func NewJobManager(bot *tgbotapi.BotAPI, store Store, workers int) *JobManager {
+	jm := &JobManager{
+		bot:   bot,
+		store: store,
+		queue: make(chan *IngestJob, jobQueueSize),
+		jobs:  make(map[string]*IngestJob),
+	}
+	for i := 0; i < workers; i++ {
+		go jm.worker()
+	}
+	return jm
+}
+
+// OnFinish mendaftarkan callback setelah job berakhir (selesai, gagal atau dibatalkan) dengan
+// data ter-index, misal evaluasi watchlist. Harus dipanggil sebelum ResumePending/Submit.
+func (jm *JobManager) OnFinish(fn func(rec IngestJobRecord)) {
+	jm.onFinish = fn
+}
+
+// Submit memasukkan job baru ke antrian
+func (jm *JobManager) Submit(rec IngestJobRecord) (*IngestJob, error) {
+	rec.ID = generateJobID()
+	rec.Status = jobQueued
+	rec.CreatedAt = time.Now()
+
+	job := jm.track(rec)
+	if err := jm.enqueue(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ResumePending memuat job dari Store: yang masih QUEUED/RUNNING (bot crash / restart)
+// dimasukkan lagi ke antrian dan dilanjutkan dari checkpoint terakhir.
+func (jm *JobManager) ResumePending() {
+	for _, rec := range jm.store.ListIngestJobs() {
+		job := jm.track(rec)
+		if rec.Status != jobQueued && rec.Status != jobRunning {
+			continue
+		}
+
+		jm.update(job, func(r *IngestJobRecord) { r.Status = jobQueued })
+		if err := jm.enqueue(job); err != nil {
+			log.Printf("⚠️ Gagal melanjutkan job %s: %v", rec.ID, err)
+			continue
+		}
+		log.Printf("♻️ Resume job %s (%s) dari byte %d", rec.ID, rec.Source, rec.Offset)
+		jm.bot.Send(tgbotapi.NewMessage(rec.ChatID, fmt.Sprintf("♻️ Job `%s` (`%s`) dilanjutkan dari baris %d.", rec.ID, rec.Source, rec.Line)))
+	}
+}
+
+// Cancel menghentikan job yang sedang antri atau berjalan
+func (jm *JobManager) Cancel(id string) error {
+	jm.mu.Lock()
+	job, ok := jm.jobs[id]
+	jm.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("job `%s` tidak ditemukan", id)
+	}
+
+	status := jm.snapshot(job).Status
+	if status != jobQueued && status != jobRunning {
+		return fmt.Errorf("job `%s` sudah %s", id, status)
+	}
+
+	job.cancel()
+	if status == jobQueued {
+		// Worker akan melewati job ini saat diambil dari antrian
+		jm.update(job, func(r *IngestJobRecord) { r.Status = jobCancelled })
+	}
+	return nil
+}
+
+// Resume memasukkan lagi job FAILED ke antrian, ingest dilanjutkan dari checkpoint terakhir
+func (jm *JobManager) Resume(id string) (IngestJobRecord, error) {
+	jm.mu.Lock()
+	job, ok := jm.jobs[id]
+	if !ok {
+		jm.mu.Unlock()
+		return IngestJobRecord{}, fmt.Errorf("job `%s` tidak ditemukan", id)
+	}
+	// Status diubah di bawah lock yang sama agar /resume ganda tidak mengantrikan job dua kali
+	if job.Status != jobFailed {
+		status := job.Status
+		jm.mu.Unlock()
+		return IngestJobRecord{}, fmt.Errorf("job `%s` %s, hanya job %s yang bisa dilanjutkan", id, status, jobFailed)
+	}
+	// Arsip rusak / melebihi batas akan gagal lagi di tempat yang sama, checkpoint-nya juga tidak dipakai
+	if !job.Resumable {
+		jm.mu.Unlock()
+		return IngestJobRecord{}, fmt.Errorf("job `%s` gagal karena isi file (%s), upload ulang file yang sudah diperbaiki", id, job.Error)
+	}
+	job.Status = jobQueued
+	job.Error = ""
+	jm.mu.Unlock()
+
+	if err := jm.enqueue(job); err != nil {
+		return IngestJobRecord{}, err
+	}
+	return jm.snapshot(job), nil
+}
+
+// List mengembalikan job terbaru dulu
+func (jm *JobManager) List() []*IngestJob {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	var jobs []*IngestJob
+	for _, j := range jm.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].CreatedAt.After(jobs[k].CreatedAt) })
+	return jobs
+}
+
+// Get mengembalikan status terkini satu job
+func (jm *JobManager) Get(id string) (IngestJobRecord, bool) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	job, ok := jm.jobs[id]
+	if !ok {
+		return IngestJobRecord{}, false
+	}
+	return job.IngestJobRecord, true
+}
+
+func (jm *JobManager) track(rec IngestJobRecord) *IngestJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &IngestJob{IngestJobRecord: rec, ctx: ctx, cancel: cancel}
+
+	jm.mu.Lock()
+	jm.jobs[rec.ID] = job
+	jm.mu.Unlock()
+	return job
+}
+
+func (jm *JobManager) enqueue(job *IngestJob) error {
+	jm.store.SaveIngestJob(jm.snapshot(job))
+	select {
+	case jm.queue <- job:
+		return nil
+	default:
+		jm.update(job, func(r *IngestJobRecord) {
+			r.Status = jobFailed
+			r.Error = "antrian penuh"
+			r.Resumable = true
+		})
+		return errors.New("antrian job penuh, coba lagi nanti")
+	}
+}
+
+// update mengubah record job secara thread-safe lalu menyimpannya ke Store
+func (jm *JobManager) update(job *IngestJob, fn func(r *IngestJobRecord)) {
+	jm.mu.Lock()
+	fn(&job.IngestJobRecord)
+	rec := job.IngestJobRecord
+	jm.mu.Unlock()
+
+	jm.store.SaveIngestJob(rec)
+}
+
+func (jm *JobManager) snapshot(job *IngestJob) IngestJobRecord {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	return job.IngestJobRecord
+}
+
+func (jm *JobManager) worker() {
+	for job := range jm.queue {
+		if job.ctx.Err() != nil {
+			continue // Dibatalkan saat masih antri
+		}
+		jm.run(job)
+	}
+}
+
+func (jm *JobManager) run(job *IngestJob) {
+	jm.update(job, func(r *IngestJobRecord) {
+		r.Status = jobRunning
+		r.Error = ""
+		r.Resumable = false
+	})
+	rec := jm.snapshot(job)
+
+	statusMsg, _ := jm.bot.Send(tgbotapi.NewMessage(rec.ChatID, fmt.Sprintf("⚙️ Job `%s` mulai: `%s`", rec.ID, rec.Source)))
+
+	body, size, err := jm.openSource(job.ctx, rec)
+	if err != nil {
+		jm.update(job, func(r *IngestJobRecord) {
+			r.Status = jobFailed
+			r.Error = err.Error()
+			r.Resumable = job.ctx.Err() == nil // Gagal download / koneksi, coba lagi lewat /resume
+		})
+		jm.bot.Send(tgbotapi.NewMessage(rec.ChatID, fmt.Sprintf("❌ Job `%s` gagal: %v", rec.ID, err)))
+		return
+	}
+	defer body.Close()
+
+	// Tanpa offset (job baru / arsip) semua data di-ingest ulang dari awal
+	progress := NewIngestProgress(rec.Source, size)
+	prevIndexed := 0
+	if rec.Offset > 0 {
+		progress.ResumeFrom(IngestCheckpoint{Offset: rec.Offset, Line: rec.Line, Header: rec.Header})
+		prevIndexed = rec.Indexed
+	}
+	ing := NewBulkIngesterContext(job.ctx, jm.store, bulkConfigFromEnv())
+
+	// Checkpoint hanya disimpan setelah semua batch sebelumnya selesai,
+	// jadi posisi yang tersimpan tidak pernah mendahului data yang ter-index
+	progress.OnCheckpoint(checkpointEvery, func(cp IngestCheckpoint) {
+		ing.Flush()
+		jm.update(job, func(r *IngestJobRecord) {
+			r.Offset = cp.Offset
+			r.Line = cp.Line
+			r.Header = cp.Header
+			r.Indexed = prevIndexed + ing.Indexed()
+		})
+	})
+
+	jm.mu.Lock()
+	job.progress = progress
+	jm.mu.Unlock()
+
+	reader := &jobReader{ctx: job.ctx, r: body}
+	stop := startProgressReporter(jm.bot, rec.ChatID, statusMsg.MessageID, progress, ing)
+	_, ingestErr := ingestSource(job.ctx, progress.Reader(reader), rec.Source, ing, progress, archiveLimitsFromEnv())
+	stats := ing.Close()
+	stop()
+
+	status, title := jobDone, "INGEST SELESAI!"
+	errMsg := ""
+	resumable := false
+	if job.ctx.Err() != nil {
+		status, title = jobCancelled, "JOB DIBATALKAN"
+	} else if reader.err != nil {
+		// Hanya kegagalan baca (jaringan / download macet) yang bisa dilanjutkan dari checkpoint
+		status, title = jobFailed, fmt.Sprintf("JOB GAGAL (checkpoint tersimpan, lanjutkan: /resume %s)", rec.ID)
+		errMsg = reader.err.Error()
+		resumable = true
+	} else if ingestErr != nil {
+		// Arsip rusak / melebihi batas zip-bomb
+		status, title = jobFailed, "JOB GAGAL: "+ingestErr.Error()
+		errMsg = ingestErr.Error()
+	}
+
+	cp := progress.Checkpoint()
+	jm.update(job, func(r *IngestJobRecord) {
+		r.Status = status
+		r.Error = errMsg
+		r.Resumable = resumable
+		r.Offset = cp.Offset
+		r.Line = cp.Line
+		r.Header = cp.Header
+		r.Indexed = prevIndexed + stats.Indexed
+	})
+
+	summary := fmt.Sprintf("🆔 Job `%s`\n", rec.ID) + formatIngestSummary(jobStatusIcon[status], title, progress, stats)
+	if _, err := jm.bot.Send(tgbotapi.NewEditMessageText(rec.ChatID, statusMsg.MessageID, summary)); err != nil {
+		// Pesan status hilang / tidak bisa diedit -> kirim baru
+		jm.bot.Send(tgbotapi.NewMessage(rec.ChatID, summary))
+	}
+
+	// Job gagal/dibatalkan tetap meninggalkan data ter-index, jadi ikut dievaluasi
+	if final := jm.snapshot(job); jm.onFinish != nil && final.Indexed > 0 {
+		jm.onFinish(final)
+	}
+}
+
+// openSource membuka stream source mulai dari rec.Offset (pakai HTTP Range jika didukung server).
+// Request terikat ctx job sehingga /cancel juga memutus download yang sedang menunggu.
+// Return size total file (-1 jika tidak diketahui).
+func (jm *JobManager) openSource(ctx context.Context, rec IngestJobRecord) (io.ReadCloser, int64, error) {
+	url := rec.URL
+	if rec.Kind == "file" {
+		// URL file Telegram berisi token & bisa kedaluwarsa, jadi selalu diminta ulang
+		fileURL, err := jm.bot.GetFileDirectURL(rec.FileID)
+		if err != nil {
+			return nil, 0, err
+		}
+		url = fileURL
+	}
+
+	dlCtx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(dlCtx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return nil, 0, err
+	}
+	if rec.Offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", rec.Offset))
+	}
+
+	resp, err := ingestHTTPClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, 0, err
+	}
+	body := newStallReader(resp.Body, cancel, downloadStallTimeout)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		body.Close()
+		return nil, 0, fmt.Errorf("download gagal: %s", resp.Status)
+	}
+
+	size := resp.ContentLength
+	if rec.Offset > 0 {
+		if resp.StatusCode == http.StatusPartialContent {
+			if size > 0 {
+				size += rec.Offset
+			}
+		} else if _, err := io.CopyN(io.Discard, body, rec.Offset); err != nil {
+			// Server tidak mendukung Range -> buang bagian yang sudah di-ingest
+			body.Close()
+			return nil, 0, err
+		}
+	}
+	return body, size, nil
+}
+
+// stallReader memutus download (lewat cancel context request) jika tidak ada data selama timeout
+type stallReader struct {
+	body    io.ReadCloser
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	timeout time.Duration
+
+	mu      sync.Mutex
+	stalled bool
+}
+
+func newStallReader(body io.ReadCloser, cancel context.CancelFunc, timeout time.Duration) *stallReader {
+	s := &stallReader{body: body, cancel: cancel, timeout: timeout}
+	s.timer = time.AfterFunc(timeout, func() {
+		s.mu.Lock()
+		s.stalled = true
+		s.mu.Unlock()
+		cancel()
+	})
+	return s
+}
+
+func (s *stallReader) Read(b []byte) (int, error) {
+	n, err := s.body.Read(b)
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	if err != nil && err != io.EOF {
+		s.mu.Lock()
+		stalled := s.stalled
+		s.mu.Unlock()
+		if stalled {
+			return n, fmt.Errorf("download macet (tidak ada data selama %s)", s.timeout)
+		}
+	}
+	return n, err
+}
+
+func (s *stallReader) Close() error {
+	s.timer.Stop()
+	s.cancel()
+	return s.body.Close()
+}
+
+// jobReader menghentikan ingest saat job dibatalkan dan mencatat error baca selain EOF
+type jobReader struct {
+	ctx context.Context
+	r   io.Reader
+	err error
+}
+
+func (j *jobReader) Read(b []byte) (int, error) {
+	if err := j.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := j.r.Read(b)
+	if err != nil && err != io.EOF && j.err == nil {
+		j.err = err
+	}
+	return n, err
+}
+
+// --- HANDLERS ---
+
+func handleJobs(bot *tgbotapi.BotAPI, chatID int64, jm *JobManager) {
+	jobs := jm.List()
+	if len(jobs) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "📭 Belum ada job ingest."))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🗂 **INGEST JOBS**\n\n")
+	for i, job := range jobs {
+		if i >= jobListLimit {
+			sb.WriteString(fmt.Sprintf("_(...%d job lama lainnya)_\n", len(jobs)-jobListLimit))
+			break
+		}
+		rec := jm.snapshot(job)
+		sb.WriteString(fmt.Sprintf("%s `%s` %s — `%s`\n", jobStatusIcon[rec.Status], rec.ID, rec.Status, rec.Source))
+
+		jm.mu.Lock()
+		p := job.progress
+		jm.mu.Unlock()
+
+		switch {
+		case rec.Status == jobRunning && p != nil:
+			line := fmt.Sprintf("   📄 %d baris", p.Lines())
+			if p.TotalSize > 0 {
+				line += fmt.Sprintf(" • %.1f%%", float64(p.BytesRead())/float64(p.TotalSize)*100)
+			}
+			sb.WriteString(line + "\n")
+		case rec.Status == jobFailed && rec.Error != "":
+			sb.WriteString(fmt.Sprintf("   ⚠️ %s\n", rec.Error))
+		case rec.Indexed > 0:
+			sb.WriteString(fmt.Sprintf("   ✅ %d ter-index\n", rec.Indexed))
+		}
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, sb.String()))
+}
+
+func handleResumeJob(bot *tgbotapi.BotAPI, chatID int64, jm *JobManager, id string) {
+	if id == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Gunakan: `/resume <job_id>`"))
+		return
+	}
+	rec, err := jm.Resume(strings.ToUpper(id))
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("♻️ Job `%s` (`%s`) dilanjutkan dari baris %d.", rec.ID, rec.Source, rec.Line)))
+}
+
+func handleCancelJob(bot *tgbotapi.BotAPI, chatID int64, jm *JobManager, id string) {
+	if id == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Gunakan: `/cancel <job_id>`"))
+		return
+	}
+	if err := jm.Cancel(strings.ToUpper(id)); err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🛑 Job `%s` dibatalkan.", strings.ToUpper(id))))
+}
@@ -57,6 +57,12 @@ func TestIsSensitive(t *testing.T) {
 		{"secret", true},
 		{"token", true},
 		{"auth_token", true},
+		{"Sandi", true},
+		{"kata_sandi", true},
+		{"PW", true},
+		{"md5", true},
+		{"bcrypt", true},
+		{"username", false},
 		{"insecure", false},
 		{"test", false},
 		{"data", false},
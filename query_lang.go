@@ -0,0 +1,448 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- QUERY LANGUAGE ---
+// Sintaks /s:
+//   rudi hartono                 -> semua kata harus ada di full_text (AND implisit)
+//   "rudi hartono"               -> frasa persis
+//   email:rudi@gmail.com         -> field kanonik email (lihat field_aliases.go)
+//   source:old.txt               -> field lain: yang namanya mengandung "source"
+//   name:"rudi hartono"          -> frasa di field tertentu
+//   password:*                   -> field password ada (tidak kosong)
+//   a AND b, a OR b, NOT a, ( )  -> operator boolean (huruf besar), juga && || !
+// Prioritas: NOT > AND > OR.
+
+const (
+	opTerm = "TERM"
+	opAnd  = "AND"
+	opOr   = "OR"
+	opNot  = "NOT"
+)
+
+type QueryNode struct {
+	Op       string
+	Field    string // Kosong = full_text
+	Value    string // "*" = field harus ada
+	Phrase   bool
+	Children []*QueryNode
+}
+
+// QuerySyntaxError menunjuk posisi (byte) di input tempat parsing gagal
+type QuerySyntaxError struct {
+	Input string
+	Pos   int
+	Msg   string
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("%s (posisi %d)", e.Msg, utf8.RuneCountInString(e.Input[:e.Pos])+1)
+}
+
+// Pretty menampilkan query dengan penanda ^ di bawah posisi error (untuk blok kode Telegram)
+func (e *QuerySyntaxError) Pretty() string {
+	caret := strings.Repeat(" ", utf8.RuneCountInString(e.Input[:e.Pos])) + "^"
+	return fmt.Sprintf("%s\n%s", e.Input, caret)
+}
+
+// --- LEXER ---
+
+const (
+	qtWord = iota
+	qtPhrase
+	qtLParen
+	qtRParen
+	qtAnd
+	qtOr
+	qtNot
+	qtEOF
+)
+
+type queryToken struct {
+	kind  int
+	text  string // Isi word/phrase (escape sudah dibuang)
+	field string // Untuk field:value
+	pos   int
+}
+
+func tokenizeQuery(input string) ([]queryToken, error) {
+	var tokens []queryToken
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, queryToken{kind: qtLParen, pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, queryToken{kind: qtRParen, pos: i})
+			i++
+		case c == '"':
+			text, next, err := readQueryPhrase(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{kind: qtPhrase, text: text, pos: i})
+			i = next
+		case strings.HasPrefix(input[i:], "&&"):
+			tokens = append(tokens, queryToken{kind: qtAnd, pos: i})
+			i += 2
+		case strings.HasPrefix(input[i:], "||"):
+			tokens = append(tokens, queryToken{kind: qtOr, pos: i})
+			i += 2
+		case c == '!':
+			tokens = append(tokens, queryToken{kind: qtNot, pos: i})
+			i++
+		default:
+			tok, next, err := readQueryWord(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i = next
+		}
+	}
+	return append(tokens, queryToken{kind: qtEOF, pos: len(input)}), nil
+}
+
+// readQueryPhrase membaca "..." mulai dari quote pembuka di posisi start. \" dan \\ di-escape.
+func readQueryPhrase(input string, start int) (string, int, error) {
+	var sb strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			if i+1 < len(input) {
+				i++
+				sb.WriteByte(input[i])
+			}
+		case '"':
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(input[i])
+		}
+	}
+	return "", 0, &QuerySyntaxError{Input: input, Pos: start, Msg: "tanda kutip tidak ditutup"}
+}
+
+// readQueryWord membaca kata biasa atau field:value. Escape \x menjadikan x literal
+// (misal `\:` agar titik dua tidak dianggap pemisah field).
+func readQueryWord(input string, start int) (queryToken, int, error) {
+	var sb strings.Builder
+	field := ""
+	hasField := false
+	i := start
+	for i < len(input) {
+		c := input[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(' || c == ')' || c == '"' {
+			break
+		}
+		if c == '\\' && i+1 < len(input) {
+			sb.WriteByte(input[i+1])
+			i += 2
+			continue
+		}
+		if c == ':' && !hasField && sb.Len() > 0 {
+			field = sb.String()
+			hasField = true
+			sb.Reset()
+			i++
+
+			// field:"frasa"
+			if i < len(input) && input[i] == '"' {
+				text, next, err := readQueryPhrase(input, i)
+				if err != nil {
+					return queryToken{}, 0, err
+				}
+				return queryToken{kind: qtPhrase, text: text, field: field, pos: start}, next, nil
+			}
+			continue
+		}
+		sb.WriteByte(c)
+		i++
+	}
+
+	word := sb.String()
+	if hasField && word == "" {
+		return queryToken{}, 0, &QuerySyntaxError{Input: input, Pos: i, Msg: fmt.Sprintf("nilai kosong setelah '%s:'", field)}
+	}
+	if !hasField && input[start] != '\\' {
+		switch word {
+		case "AND":
+			return queryToken{kind: qtAnd, pos: start}, i, nil
+		case "OR":
+			return queryToken{kind: qtOr, pos: start}, i, nil
+		case "NOT":
+			return queryToken{kind: qtNot, pos: start}, i, nil
+		}
+	}
+	return queryToken{kind: qtWord, text: word, field: field, pos: start}, i, nil
+}
+
+// --- PARSER (recursive descent) ---
+
+type queryParser struct {
+	input  string
+	tokens []queryToken
+	pos    int
+}
+
+// parseSearchQuery mengubah input /s menjadi pohon query. Error selalu *QuerySyntaxError.
+func parseSearchQuery(input string) (*QueryNode, error) {
+	tokens, err := tokenizeQuery(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{input: input, tokens: tokens}
+	if p.peek().kind == qtEOF {
+		return nil, p.errorAt(p.peek(), "query kosong")
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != qtEOF {
+		if tok.kind == qtRParen {
+			return nil, p.errorAt(tok, "kurung tutup ')' tanpa pasangan")
+		}
+		return nil, p.errorAt(tok, "token tidak terduga")
+	}
+	return node, nil
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) advance() queryToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != qtEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *queryParser) errorAt(tok queryToken, msg string) error {
+	return &QuerySyntaxError{Input: p.input, Pos: tok.pos, Msg: msg}
+}
+
+func (p *queryParser) parseOr() (*QueryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []*QueryNode{left}
+	for p.peek().kind == qtOr {
+		p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	return joinNodes(opOr, children), nil
+}
+
+func (p *queryParser) parseAnd() (*QueryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	children := []*QueryNode{left}
+	for {
+		tok := p.peek()
+		if tok.kind == qtAnd {
+			p.advance()
+		} else if tok.kind == qtEOF || tok.kind == qtOr || tok.kind == qtRParen {
+			break
+		}
+		// AND eksplisit atau implisit (dua term bersebelahan)
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	return joinNodes(opAnd, children), nil
+}
+
+func (p *queryParser) parseUnary() (*QueryNode, error) {
+	if p.peek().kind == qtNot {
+		p.advance()
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &QueryNode{Op: opNot, Children: []*QueryNode{child}}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (*QueryNode, error) {
+	tok := p.advance()
+	switch tok.kind {
+	case qtLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != qtRParen {
+			return nil, p.errorAt(tok, "kurung '(' tidak ditutup")
+		}
+		p.advance()
+		return node, nil
+	case qtWord:
+		return &QueryNode{Op: opTerm, Field: tok.field, Value: tok.text}, nil
+	case qtPhrase:
+		return &QueryNode{Op: opTerm, Field: tok.field, Value: tok.text, Phrase: true}, nil
+	case qtEOF:
+		return nil, p.errorAt(tok, "query terpotong, kurang kata kunci di akhir")
+	case qtRParen:
+		return nil, p.errorAt(tok, "kurung tutup ')' tanpa pasangan / grup kosong")
+	default:
+		return nil, p.errorAt(tok, "operator tanpa kata kunci sebelumnya")
+	}
+}
+
+// joinNodes menggabungkan children, a AND (b AND c) diratakan jadi satu node
+func joinNodes(op string, children []*QueryNode) *QueryNode {
+	if len(children) == 1 {
+		return children[0]
+	}
+	node := &QueryNode{Op: op}
+	for _, c := range children {
+		if c.Op == op {
+			node.Children = append(node.Children, c.Children...)
+		} else {
+			node.Children = append(node.Children, c)
+		}
+	}
+	return node
+}
+
+// quoteQueryValue membungkus teks bebas menjadi frasa yang aman diparse ulang
+func quoteQueryValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// --- COMPILE KE ELASTICSEARCH ---
+
+// toQuery mengubah pohon query menjadi bool query. exactMatch mematikan fuzziness di field:value.
+func (n *QueryNode) toQuery(exactMatch bool) Query {
+	switch n.Op {
+	case opAnd:
+		return BoolQuery{Must: compileChildren(n.Children, exactMatch)}.Query()
+	case opOr:
+		return BoolQuery{Should: compileChildren(n.Children, exactMatch), MinimumShouldMatch: 1}.Query()
+	case opNot:
+		return BoolQuery{MustNot: compileChildren(n.Children, exactMatch)}.Query()
+	}
+
+	if n.Field == "" {
+		if n.Phrase {
+			return MatchPhrase("full_text", n.Value)
+		}
+		return Match("full_text", n.Value, "and", "AUTO")
+	}
+
+	// Field lain dicocokkan secara longgar (source -> leak_source)
+	field := strings.ToLower(n.Field)
+	loose := n.fieldQuery([]string{"*" + field + "*", "*" + strings.ToUpper(n.Field) + "*"}, exactMatch)
+	if !isCanonicalField(field) {
+		return loose
+	}
+	// Field kanonik diisi saat ingest (field_aliases.go). Record yang di-index sebelum normalisasi
+	// belum punya field tersebut, untuk record itu saja pencocokan longgar lama tetap dipakai.
+	return BoolQuery{Should: []Query{
+		n.fieldQuery([]string{field}, exactMatch),
+		BoolQuery{Must: []Query{loose}, MustNot: []Query{Exists(field)}}.Query(),
+	}, MinimumShouldMatch: 1}.Query()
+}
+
+func (n *QueryNode) fieldQuery(fields []string, exactMatch bool) Query {
+	if n.Value == "*" && !n.Phrase {
+		var exists []Query
+		for _, f := range fields {
+			exists = append(exists, Exists(f))
+		}
+		return BoolQuery{Should: exists, MinimumShouldMatch: 1}.Query()
+	}
+	if n.Phrase {
+		return MultiMatchPhrase(n.Value, fields)
+	}
+	fuzziness := "AUTO"
+	if exactMatch {
+		fuzziness = "0"
+	}
+	return MultiMatch(n.Value, fields, "and", fuzziness)
+}
+
+func compileChildren(children []*QueryNode, exactMatch bool) []Query {
+	var out []Query
+	for _, c := range children {
+		out = append(out, c.toQuery(exactMatch))
+	}
+	return out
+}
+
+// --- EVALUASI DI MEMORI (MemoryStore) ---
+
+func (n *QueryNode) matches(doc map[string]interface{}) bool {
+	switch n.Op {
+	case opAnd:
+		for _, c := range n.Children {
+			if !c.matches(doc) {
+				return false
+			}
+		}
+		return true
+	case opOr:
+		for _, c := range n.Children {
+			if c.matches(doc) {
+				return true
+			}
+		}
+		return false
+	case opNot:
+		return !n.Children[0].matches(doc)
+	}
+
+	match := func(text string) bool {
+		if n.Phrase {
+			return strings.Contains(strings.ToLower(text), strings.ToLower(n.Value))
+		}
+		return containsAllTerms(text, n.Value)
+	}
+
+	if n.Field == "" {
+		fullText, ok := doc["full_text"]
+		return ok && match(fmt.Sprintf("%v", fullText))
+	}
+
+	// Sama dengan toQuery: field kanonik jika record sudah punya, selain itu kolom yang mirip
+	field := strings.ToLower(n.Field)
+	canonical := isCanonicalField(field) && doc[field] != nil
+	for k, v := range doc {
+		if canonical && k != field || !canonical && !strings.Contains(strings.ToLower(k), field) {
+			continue
+		}
+		val := fmt.Sprintf("%v", v)
+		if n.Value == "*" && !n.Phrase {
+			if v != nil && val != "" {
+				return true
+			}
+			continue
+		}
+		if match(val) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// TestParseSearchQuery tests operator precedence, grouping, phrases and field terms.
+func TestParseSearchQuery(t *testing.T) {
+	parseTests := []struct {
+		input string
+		want  string
+	}{
+		{`rudi`, `full_text~rudi`},
+		{`rudi hartono`, `(full_text~rudi AND full_text~hartono)`},
+		{`"rudi hartono"`, `full_text="rudi hartono"`},
+		{`a OR b c`, `(full_text~a OR (full_text~b AND full_text~c))`},
+		{`(a OR b) c`, `((full_text~a OR full_text~b) AND full_text~c)`},
+		{`domain:acme.com AND password:* NOT source:old_combo.txt`, `(domain~acme.com AND password~* AND NOT source~old_combo.txt)`},
+		{`name:"rudi \"h\"" || !x && y`, `(name="rudi "h"" OR (NOT full_text~x AND full_text~y))`},
+		{`url:http://x.com/a`, `url~http://x.com/a`},
+		{`pass\:word and`, `(full_text~pass:word AND full_text~and)`},
+	}
+
+	for _, tt := range parseTests {
+		t.Run(tt.input, func(t *testing.T) {
+			node, err := parseSearchQuery(tt.input)
+			if err != nil {
+				t.Fatalf("parseSearchQuery() error = %v", err)
+			}
+			if got := describeNode(node); got != tt.want {
+				t.Errorf("parseSearchQuery() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseSearchQueryErrors tests that syntax errors point at the offending position.
+func TestParseSearchQueryErrors(t *testing.T) {
+	errorTests := []struct {
+		input string
+		pos   int
+	}{
+		{``, 0},
+		{`a AND`, 5},
+		{`OR a`, 0},
+		{`(a OR b`, 0},
+		{`a) b`, 1},
+		{`email: x`, 6},
+		{`name:"rudi`, 5},
+		{`a AND ()`, 7},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := parseSearchQuery(tt.input)
+			var syntaxErr *QuerySyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("parseSearchQuery() error = %v, want *QuerySyntaxError", err)
+			}
+			if syntaxErr.Pos != tt.pos {
+				t.Errorf("Pos = %d, want %d (%s)", syntaxErr.Pos, tt.pos, syntaxErr.Msg)
+			}
+		})
+	}
+}
+
+// TestQueryNodeCompile tests the Elasticsearch bool query generated for a boolean query.
+func TestQueryNodeCompile(t *testing.T) {
+	req, err := buildSearchQuery(`domain:acme.com AND password:* NOT source:old.txt`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	json.Unmarshal(req.JSON(), &got)
+
+	must := dig(got, "query", "bool", "must").([]interface{})
+	if len(must) != 3 {
+		t.Fatalf("bool.must has %d clauses, want 3: %s", len(must), req.JSON())
+	}
+	domain := dig(must[0].(map[string]interface{}), "bool", "should").([]interface{})
+	if dig(domain[0].(map[string]interface{}), "multi_match", "fuzziness") != "0" {
+		t.Errorf("field term is not exact: %v", must[0])
+	}
+	password := dig(must[1].(map[string]interface{}), "bool", "should").([]interface{})
+	exists := dig(password[0].(map[string]interface{}), "bool", "should").([]interface{})
+	if dig(exists[0].(map[string]interface{}), "exists", "field") != "password" {
+		t.Errorf("password:* = %v, want exists query", must[1])
+	}
+	if dig(must[2].(map[string]interface{}), "bool", "must_not") == nil {
+		t.Errorf("NOT = %v, want bool.must_not", must[2])
+	}
+}
+
+// TestMemoryStoreQueryLanguage tests boolean evaluation on MemoryStore.
+func TestMemoryStoreQueryLanguage(t *testing.T) {
+	store := NewMemoryStore()
+	store.IndexDocument(map[string]interface{}{"leak_source": "new.txt", "domain": "acme.com", "password": "x1", "full_text": "budi acme.com x1"}, "1")
+	store.IndexDocument(map[string]interface{}{"leak_source": "old.txt", "domain": "acme.com", "password": "x2", "full_text": "sari acme.com x2"}, "2")
+	store.IndexDocument(map[string]interface{}{"leak_source": "new.txt", "domain": "acme.com", "full_text": "joko acme.com"}, "3")
+
+	queryTests := []struct {
+		query string
+		want  int
+	}{
+		{`domain:acme.com AND password:* NOT source:old.txt`, 1},
+		{`budi OR sari`, 2},
+		{`NOT password:*`, 1},
+		{`(budi OR joko) source:new.txt`, 2},
+		{`"acme.com x2"`, 1},
+	}
+	for _, tt := range queryTests {
+		result, err := store.SearchBreaches(tt.query, 10)
+		if err != nil {
+			t.Fatalf("SearchBreaches(%q) error = %v", tt.query, err)
+		}
+		if result.Hits.Total.Value != tt.want {
+			t.Errorf("SearchBreaches(%q) = %d, want %d", tt.query, result.Hits.Total.Value, tt.want)
+		}
+	}
+}
+
+// describeNode renders a query tree compactly for assertions.
+func describeNode(n *QueryNode) string {
+	switch n.Op {
+	case opNot:
+		return "NOT " + describeNode(n.Children[0])
+	case opAnd, opOr:
+		s := "("
+		for i, c := range n.Children {
+			if i > 0 {
+				s += " " + n.Op + " "
+			}
+			s += describeNode(c)
+		}
+		return s + ")"
+	}
+	field := n.Field
+	if field == "" {
+		field = "full_text"
+	}
+	if n.Phrase {
+		return field + `="` + n.Value + `"`
+	}
+	return field + "~" + n.Value
+}
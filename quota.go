@@ -0,0 +1,413 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- KUOTA PER USER ---
+// Berbeda dengan rate limit (per menit, di RAM), kuota dihitung per hari/bulan dan disimpan
+// di Store (index user_quotas) sehingga tidak hilang saat restart. Batasnya mengikuti tier
+// key yang di-redeem (lihat access_keys.go) dan bisa ditimpa admin per user lewat /setquota.
+
+const (
+	quotaUnlimited = -1
+	quotaFreeTier  = "free" // User tanpa key (mode OPEN)
+)
+
+type QuotaLimits struct {
+	SearchesPerDay  int   `json:"searches_per_day"`
+	ExportsPerMonth int   `json:"exports_per_month"`
+	ExportRows      int64 `json:"export_rows_per_month"`
+}
+
+var unlimitedQuota = QuotaLimits{quotaUnlimited, quotaUnlimited, quotaUnlimited}
+
+var tierQuotas = map[string]QuotaLimits{
+	quotaFreeTier: {SearchesPerDay: 20, ExportsPerMonth: 2, ExportRows: 10000},
+	"basic":       {SearchesPerDay: 100, ExportsPerMonth: 10, ExportRows: 100000},
+	"pro":         {SearchesPerDay: 1000, ExportsPerMonth: 100, ExportRows: 5000000},
+	"enterprise":  unlimitedQuota,
+}
+
+// QuotaKind: jenis kuota yang dipotong quotaMiddleware sebelum command jalan.
+// Export tidak lewat middleware karena jatah baris dipesan & diselesaikan di handleExport (reserveExport).
+type QuotaKind string
+
+const QuotaSearch QuotaKind = "search"
+
+// UserQuota: pemakaian user pada hari/bulan berjalan + override dari admin
+type UserQuota struct {
+	UserID     string       `json:"user_id"`
+	Day        string       `json:"day"` // 2006-01-02
+	Searches   int          `json:"searches"`
+	Month      string       `json:"month"` // 2006-01
+	Exports    int          `json:"exports"`
+	ExportRows int64        `json:"export_rows"`
+	Override   *QuotaLimits `json:"override,omitempty"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// rollover menolkan hitungan saat sudah berganti hari/bulan
+func (q *UserQuota) rollover(now time.Time) {
+	if day := now.Format("2006-01-02"); q.Day != day {
+		q.Day = day
+		q.Searches = 0
+	}
+	if month := now.Format("2006-01"); q.Month != month {
+		q.Month = month
+		q.Exports = 0
+		q.ExportRows = 0
+	}
+}
+
+// quotaState: pemakaian + batas yang berlaku untuk satu user
+type quotaState struct {
+	UserQuota
+	Limits QuotaLimits
+	Tier   string // Label asal batas: tier key, "custom" (override) atau "staff"
+}
+
+var (
+	// errQuotaUnavailable: kuota tidak bisa dibaca/disimpan, command ditolak daripada menimpa hitungan yang tersimpan
+	errQuotaUnavailable = errors.New("kuota tidak bisa dibaca, coba lagi nanti")
+	errQuotaConflict    = errors.New("kuota sedang dipakai permintaan lain, coba lagi")
+	errQuotaExhausted   = errors.New("kuota habis")
+)
+
+// loadQuota: override admin, staff tanpa batas, tier dari key yang masih aktif, selain itu free
+func loadQuota(store Store, userID int64, role Role, now time.Time) (*quotaState, error) {
+	uid := strconv.FormatInt(userID, 10)
+	q, _, err := store.GetUserQuota(uid)
+	if err != nil {
+		log.Printf("⚠️ Gagal membaca kuota %s: %v", uid, err)
+		return nil, errQuotaUnavailable
+	}
+	q.UserID = uid
+	return newQuotaState(q, role, userTier(store, uid, now), now), nil
+}
+
+func newQuotaState(q UserQuota, role Role, tier string, now time.Time) *quotaState {
+	q.rollover(now)
+	state := &quotaState{UserQuota: q}
+	// Override admin berlaku juga untuk staff, baru setelah itu staff tanpa batas
+	switch {
+	case q.Override != nil:
+		state.Limits, state.Tier = *q.Override, "custom"
+	case role.Can(PermStaff):
+		state.Limits, state.Tier = unlimitedQuota, "staff"
+	default:
+		state.Limits, state.Tier = tierQuotas[tier], tier
+	}
+	return state
+}
+
+// updateQuota menerapkan fn pada kuota terbaru secara atomik (Store.UpdateUserQuota), jadi cek sisa
+// kuota dan pemotongannya tidak bisa diselip permintaan lain. Error dari fn (misal errQuotaExhausted)
+// membatalkan update, state tetap dikembalikan untuk pesan ke user.
+func updateQuota(store Store, userID int64, role Role, now time.Time, fn func(s *quotaState) error) (*quotaState, error) {
+	uid := strconv.FormatInt(userID, 10)
+	tier := userTier(store, uid, now) // Dibaca di luar fn: MemoryStore memegang lock selama fn jalan
+	var state *quotaState
+	var fnErr error
+	_, err := store.UpdateUserQuota(uid, func(q *UserQuota) error {
+		state = newQuotaState(*q, role, tier, now)
+		if fnErr = fn(state); fnErr != nil {
+			return fnErr
+		}
+		state.UpdatedAt = now
+		*q = state.UserQuota
+		return nil
+	})
+	switch {
+	case err == nil:
+		return state, nil
+	case fnErr != nil:
+		return state, fnErr
+	case errors.Is(err, errQuotaConflict):
+		return nil, err
+	}
+	log.Printf("⚠️ Gagal memperbarui kuota %s: %v", uid, err)
+	return nil, errQuotaUnavailable
+}
+
+// userTier: tier langganan yang masih aktif (user lama tanpa tier = basic), selain itu free
+func userTier(store Store, userID string, now time.Time) string {
+	user, ok := store.GetAuthorizedUser(userID)
+	if !ok || !user.Active(now) {
+		return quotaFreeTier
+	}
+	if _, known := tierQuotas[user.Tier]; !known {
+		return defaultKeyTier
+	}
+	return user.Tier
+}
+
+// quotaLeft: sisa jatah, quotaUnlimited jika tanpa batas
+func quotaLeft(limit, used int64) int64 {
+	if limit < 0 {
+		return quotaUnlimited
+	}
+	if used >= limit {
+		return 0
+	}
+	return limit - used
+}
+
+func (s *quotaState) SearchesLeft() int64 {
+	return quotaLeft(int64(s.Limits.SearchesPerDay), int64(s.Searches))
+}
+
+func (s *quotaState) ExportsLeft() int64 {
+	return quotaLeft(int64(s.Limits.ExportsPerMonth), int64(s.Exports))
+}
+
+func (s *quotaState) ExportRowsLeft() int64 {
+	return quotaLeft(s.Limits.ExportRows, s.ExportRows)
+}
+
+// exportReservation: jatah export yang dipesan sebelum spool. Baris yang tidak terpakai
+// dikembalikan lewat settle setelah file terkirim.
+type exportReservation struct {
+	store   Store
+	userID  int64
+	role    Role
+	month   string
+	rows    int64 // Baris yang dipesan, 0 = tanpa batas
+	settled bool
+}
+
+// reserveExport memotong 1 export dan seluruh sisa baris bulan ini sekaligus, sehingga export
+// bersamaan tidak bisa melewati batas. MaxRows adalah batas spool (0 = tanpa batas).
+func reserveExport(store Store, userID int64, role Role, now time.Time) (*exportReservation, *quotaState, error) {
+	res := &exportReservation{store: store, userID: userID, role: role}
+	state, err := updateQuota(store, userID, role, now, func(s *quotaState) error {
+		if s.ExportsLeft() == 0 || s.ExportRowsLeft() == 0 {
+			return errQuotaExhausted
+		}
+		res.rows = 0
+		if left := s.ExportRowsLeft(); left > 0 {
+			res.rows = left
+		}
+		s.Exports++
+		s.ExportRows += res.rows
+		res.month = s.Month
+		return nil
+	})
+	if err != nil {
+		return nil, state, err
+	}
+	return res, state, nil
+}
+
+func (r *exportReservation) MaxRows() int {
+	return int(r.rows)
+}
+
+// settle mencatat baris yang benar-benar terkirim dan mengembalikan sisanya.
+// used < 0 berarti export gagal: jatah export ikut dikembalikan. Hanya dipanggil sekali.
+func (r *exportReservation) settle(used int64) {
+	if r.settled {
+		return
+	}
+	r.settled = true
+	_, err := updateQuota(r.store, r.userID, r.role, time.Now(), func(s *quotaState) error {
+		if s.Month != r.month {
+			return nil // Sudah berganti bulan, hitungan lama sudah direset
+		}
+		if used < 0 {
+			s.Exports--
+			s.ExportRows -= r.rows
+		} else {
+			s.ExportRows += used - r.rows
+		}
+		s.Exports = max(s.Exports, 0)
+		s.ExportRows = max(s.ExportRows, 0)
+		return nil
+	})
+	if err != nil {
+		log.Printf("⚠️ Pengembalian kuota export user %d gagal: %v", r.userID, err)
+	}
+}
+
+// Waktu reset: tengah malam berikutnya & tanggal 1 bulan berikutnya (zona waktu server)
+func nextQuotaDay(now time.Time) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+}
+
+func nextQuotaMonth(now time.Time) time.Time {
+	y, m, _ := now.Date()
+	return time.Date(y, m+1, 1, 0, 0, 0, 0, now.Location())
+}
+
+func formatQuota(used, limit int64) string {
+	if limit < 0 {
+		return fmt.Sprintf("%d / ∞", used)
+	}
+	return fmt.Sprintf("%d / %d", used, limit)
+}
+
+// quotaText: ringkasan untuk /quota
+func quotaText(s *quotaState, now time.Time) string {
+	return fmt.Sprintf("📊 *KUOTA* `%s` (%s)\n\n🔍 Pencarian hari ini: %s\n   ↻ reset %s\n📄 Export bulan ini: %s\n📑 Baris export bulan ini: %s\n   ↻ reset %s",
+		s.UserID, s.Tier,
+		formatQuota(int64(s.Searches), int64(s.Limits.SearchesPerDay)), nextQuotaDay(now).Format("2006-01-02 15:04"),
+		formatQuota(int64(s.Exports), int64(s.Limits.ExportsPerMonth)),
+		formatQuota(s.ExportRows, s.Limits.ExportRows), nextQuotaMonth(now).Format("2006-01-02 15:04"))
+}
+
+// parseQuotaOverride menerapkan "search=100 export=5 rows=50000" (angka atau "unlimited") di atas base
+func parseQuotaOverride(base QuotaLimits, args []string) (QuotaLimits, error) {
+	limits := base
+	for _, arg := range args {
+		name, value, ok := strings.Cut(strings.ToLower(arg), "=")
+		if !ok {
+			return limits, fmt.Errorf("format %q salah, gunakan nama=angka", arg)
+		}
+		n := int64(quotaUnlimited)
+		if value != "unlimited" {
+			var err error
+			if n, err = strconv.ParseInt(value, 10, 64); err != nil || n < 0 {
+				return limits, fmt.Errorf("nilai %q harus angka >= 0 atau unlimited", value)
+			}
+		}
+		switch name {
+		case "search":
+			limits.SearchesPerDay = int(n)
+		case "export":
+			limits.ExportsPerMonth = int(n)
+		case "rows":
+			limits.ExportRows = n
+		default:
+			return limits, fmt.Errorf("kuota %q tidak dikenal (search, export, rows)", name)
+		}
+	}
+	return limits, nil
+}
+
+// --- MIDDLEWARE & HANDLERS ---
+
+// chargeSearch: fn updateQuota untuk satu pencarian (bot & API)
+func chargeSearch(s *quotaState) error {
+	if s.SearchesLeft() == 0 {
+		return errQuotaExhausted
+	}
+	s.Searches++
+	return nil
+}
+
+// refundSearch: fn updateQuota untuk mengembalikan satu pencarian yang gagal di database.
+// Hanya hitungan hari `day` (hari saat dipotong) yang dikurangi, setelah rollover tidak ada yang dikembalikan.
+func refundSearch(day string) func(s *quotaState) error {
+	return func(s *quotaState) error {
+		if s.Day == day && s.Searches > 0 {
+			s.Searches--
+		}
+		return nil
+	}
+}
+
+// quotaMiddleware memotong kuota harian sebelum command jalan
+func quotaMiddleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *CommandContext) {
+			if ctx.Command.Quota != QuotaSearch {
+				next(ctx)
+				return
+			}
+			now := time.Now()
+			state, err := updateQuota(ctx.Store, ctx.User.ID, ctx.Role, now, chargeSearch)
+			if err == errQuotaExhausted {
+				ctx.Reply(fmt.Sprintf("📉 **KUOTA HABIS**\nBatas %d pencarian/hari tercapai. Reset: %s\nCek sisa kuota: /quota",
+					state.Limits.SearchesPerDay, nextQuotaDay(now).Format("2006-01-02 15:04")))
+				return
+			}
+			if err != nil {
+				ctx.Reply("❌ " + err.Error())
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+// /quota [user]: user melihat kuota sendiri, pemegang PermQuota boleh melihat user lain
+func handleQuota(ctx *CommandContext) {
+	target := ctx.User.ID
+	if arg := strings.TrimSpace(ctx.Args); arg != "" && ctx.Role.Can(PermQuota) {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			ctx.Reply("❌ ID user harus berupa angka.")
+			return
+		}
+		target = id
+	}
+	role := ctx.Role
+	if target != ctx.User.ID {
+		role = ctx.Store.GetUserRole(strconv.FormatInt(target, 10))
+	}
+	now := time.Now()
+	state, err := loadQuota(ctx.Store, target, role, now)
+	if err != nil {
+		ctx.Reply("❌ " + err.Error())
+		return
+	}
+	reply := tgbotapi.NewMessage(ctx.ChatID, quotaText(state, now))
+	reply.ParseMode = "Markdown"
+	ctx.Bot.Send(reply)
+}
+
+// /setquota <user> search=N export=N rows=N | default
+func handleSetQuota(ctx *CommandContext) {
+	parts := strings.Fields(ctx.Args)
+	if len(parts) < 2 {
+		ctx.ReplyUsage()
+		return
+	}
+	targetID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		ctx.Reply("❌ ID user harus berupa angka.")
+		return
+	}
+
+	now := time.Now()
+	role := ctx.Store.GetUserRole(parts[0])
+	_, err = updateQuota(ctx.Store, targetID, role, now, func(s *quotaState) error {
+		if strings.EqualFold(parts[1], "default") {
+			s.Override = nil
+			return nil
+		}
+		limits, err := parseQuotaOverride(s.Limits, parts[1:])
+		if err != nil {
+			return err
+		}
+		s.Override = &limits
+		return nil
+	})
+	if err == errQuotaUnavailable || err == errQuotaConflict {
+		ctx.Reply("❌ " + err.Error())
+		return
+	}
+	if err != nil {
+		ctx.Reply("⚠️ " + err.Error())
+		return
+	}
+	ctx.Store.LogActivity(ctx.User, "SETQUOTA", ctx.Args)
+
+	// Tampilkan ulang dengan batas yang baru berlaku
+	state, err := loadQuota(ctx.Store, targetID, role, now)
+	if err != nil {
+		ctx.Reply("✅ Kuota diperbarui.")
+		return
+	}
+	reply := tgbotapi.NewMessage(ctx.ChatID, "✅ Kuota diperbarui.\n\n"+quotaText(state, now))
+	reply.ParseMode = "Markdown"
+	ctx.Bot.Send(reply)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// TestLoadQuota tests which limits apply and that counters reset per day/month.
+func TestLoadQuota(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	later := now.Add(30 * 24 * time.Hour)
+
+	store.AuthorizeUser(AuthorizedUser{UserID: "1", Tier: "pro", ExpiresAt: &later})
+	store.AuthorizeUser(AuthorizedUser{UserID: "2"}) // User lama tanpa tier
+	seedQuota(store, UserQuota{UserID: "3", Override: &QuotaLimits{SearchesPerDay: 5, ExportsPerMonth: 1, ExportRows: 50}})
+	seedQuota(store, UserQuota{UserID: "4", Day: "2024-03-14", Searches: 9, Month: "2024-03", Exports: 2, ExportRows: 500})
+
+	tests := []struct {
+		userID int64
+		role   Role
+		tier   string
+		limits QuotaLimits
+	}{
+		{1, roleUser, "pro", tierQuotas["pro"]},
+		{2, roleUser, "basic", tierQuotas["basic"]},
+		{3, roleUser, "custom", QuotaLimits{5, 1, 50}},
+		{4, roleUser, quotaFreeTier, tierQuotas[quotaFreeTier]},
+		{5, roleModerator, quotaFreeTier, tierQuotas[quotaFreeTier]}, // Moderator tidak kebal kuota
+		{5, roleAdmin, "staff", unlimitedQuota},
+		{3, roleAdmin, "custom", QuotaLimits{5, 1, 50}}, // /setquota berlaku juga untuk staff
+	}
+	for _, tt := range tests {
+		s, _ := loadQuota(store, tt.userID, tt.role, now)
+		if s.Tier != tt.tier || s.Limits != tt.limits {
+			t.Errorf("user %d: tier %q limits %+v, want %q %+v", tt.userID, s.Tier, s.Limits, tt.tier, tt.limits)
+		}
+	}
+
+	s, _ := loadQuota(store, 4, roleUser, now)
+	if s.Searches != 0 || s.Exports != 2 || s.ExportRows != 500 {
+		t.Errorf("rollover in same month = %+v, want searches reset only", s.UserQuota)
+	}
+	s, _ = loadQuota(store, 4, roleUser, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	if s.Exports != 0 || s.ExportRows != 0 {
+		t.Errorf("rollover in new month = %+v, want exports reset", s.UserQuota)
+	}
+	staff, _ := loadQuota(store, 5, roleAdmin, now)
+	if s.ExportsLeft() != 2 || s.ExportRowsLeft() != 10000 || staff.SearchesLeft() != quotaUnlimited {
+		t.Error("remaining quota is wrong")
+	}
+
+	// Store gagal dibaca -> ditolak, bukan dianggap kuota kosong
+	if s, err := loadQuota(brokenQuotaStore{store}, 3, roleUser, now); s != nil || err != errQuotaUnavailable {
+		t.Errorf("loadQuota() on store failure = %+v, %v, want errQuotaUnavailable", s, err)
+	}
+
+	if got := nextQuotaDay(now); !got.Equal(time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("nextQuotaDay() = %s", got)
+	}
+	if got := nextQuotaMonth(time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("nextQuotaMonth() = %s", got)
+	}
+}
+
+func seedQuota(store Store, q UserQuota) {
+	store.UpdateUserQuota(q.UserID, func(cur *UserQuota) error {
+		*cur = q
+		return nil
+	})
+}
+
+// brokenQuotaStore simulates an Elasticsearch failure when reading quotas.
+type brokenQuotaStore struct{ *MemoryStore }
+
+func (brokenQuotaStore) GetUserQuota(userID string) (UserQuota, bool, error) {
+	return UserQuota{}, false, errors.New("503 Service Unavailable")
+}
+
+func (brokenQuotaStore) UpdateUserQuota(userID string, fn func(q *UserQuota) error) (UserQuota, error) {
+	return UserQuota{}, errors.New("503 Service Unavailable")
+}
+
+// TestReserveExport tests that concurrent exports cannot share the same remaining rows
+// and that unused rows are refunded.
+func TestReserveExport(t *testing.T) {
+	store := NewMemoryStore()
+	seedQuota(store, UserQuota{UserID: "3", Override: &QuotaLimits{SearchesPerDay: 5, ExportsPerMonth: 5, ExportRows: 100}})
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var won []*exportReservation
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res, _, err := reserveExport(store, 3, roleUser, now); err == nil {
+				mu.Lock()
+				won = append(won, res)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(won) != 1 || won[0].MaxRows() != 100 {
+		t.Fatalf("got %d reservations, want exactly 1 holding all 100 rows", len(won))
+	}
+
+	won[0].settle(30)
+	won[0].settle(0) // Sudah diselesaikan, diabaikan
+	if q, _, _ := store.GetUserQuota("3"); q.Exports != 1 || q.ExportRows != 30 {
+		t.Errorf("after settle(30) = %+v, want 1 export and 30 rows", q)
+	}
+
+	res, _, err := reserveExport(store, 3, roleUser, now)
+	if err != nil || res.MaxRows() != 70 {
+		t.Fatalf("second reservation = %+v, %v, want 70 rows", res, err)
+	}
+	res.settle(-1)
+	if q, _, _ := store.GetUserQuota("3"); q.Exports != 1 || q.ExportRows != 30 {
+		t.Errorf("after failed export = %+v, want reservation refunded", q)
+	}
+
+	// Staff tanpa batas: tidak ada baris yang dipesan
+	if res, _, err := reserveExport(store, 5, roleAdmin, now); err != nil || res.MaxRows() != 0 {
+		t.Errorf("staff reservation = %+v, %v, want unlimited", res, err)
+	}
+	if _, _, err := reserveExport(brokenQuotaStore{store}, 3, roleUser, now); err != errQuotaUnavailable {
+		t.Errorf("reserveExport() on store failure = %v, want errQuotaUnavailable", err)
+	}
+}
+
+// TestParseQuotaOverride tests the /setquota argument parser.
+func TestParseQuotaOverride(t *testing.T) {
+	base := tierQuotas["basic"]
+	got, err := parseQuotaOverride(base, []string{"search=5", "ROWS=unlimited"})
+	if err != nil || got != (QuotaLimits{SearchesPerDay: 5, ExportsPerMonth: base.ExportsPerMonth, ExportRows: quotaUnlimited}) {
+		t.Errorf("parseQuotaOverride() = %+v, %v", got, err)
+	}
+	for _, bad := range []string{"search", "search=-1", "export=x", "foo=1"} {
+		if _, err := parseQuotaOverride(base, []string{bad}); err == nil {
+			t.Errorf("parseQuotaOverride(%q) error = nil", bad)
+		}
+	}
+}
+
+// TestQuotaMiddleware tests that searches are counted persistently per user.
+func TestQuotaMiddleware(t *testing.T) {
+	store := NewMemoryStore()
+	r := NewRouter()
+	r.Use(roleMiddleware(99), quotaMiddleware())
+	calls := 0
+	r.Handle(&Command{Name: "/s", Quota: QuotaSearch, Handler: func(ctx *CommandContext) { calls++ }})
+	r.Handle(&Command{Name: "/help", Handler: func(ctx *CommandContext) { calls++ }})
+
+	for _, text := range []string{"/s a", "/s b", "/help"} {
+		msg := &tgbotapi.Message{Text: text, Chat: &tgbotapi.Chat{ID: 1}, From: &tgbotapi.User{ID: 7}}
+		r.Dispatch(&CommandContext{Msg: msg, Store: store})
+	}
+	if q, _, _ := store.GetUserQuota("7"); calls != 3 || q.Searches != 2 || q.Day != time.Now().Format("2006-01-02") {
+		t.Errorf("calls = %d, quota = %+v, want 3 calls and 2 searches today", calls, q)
+	}
+}
+
+// TestExportCallbackQuotaUser tests that export buttons charge the user who clicked them,
+// not the bot that sent the message carrying the button.
+func TestExportCallbackQuotaUser(t *testing.T) {
+	const botID, userID = 999, 42
+	var mu sync.Mutex
+	var buttons []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		mu.Lock()
+		buttons = append(buttons, regexp.MustCompile(`pg:[^"]+:export`).FindAllString(r.FormValue("reply_markup"), -1)...)
+		mu.Unlock()
+		if regexp.MustCompile(`/getMe$`).MatchString(r.URL.Path) {
+			fmt.Fprintf(w, `{"ok": true, "result": {"id": %d, "is_bot": true, "username": "radar_bot"}}`, botID)
+			return
+		}
+		fmt.Fprintf(w, `{"ok": true, "result": {"message_id": 1, "chat": {"id": %d}}}`, userID)
+	}))
+	defer srv.Close()
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("test", srv.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStore()
+	store.IndexDocument(map[string]interface{}{"email": "ceo@acme.com", "password": "x"}, "a")
+	store.IndexDocument(map[string]interface{}{"email": "dev@acme.com", "password": "y"}, "b")
+	router := newCommandRouter(NewLiveConfig(SystemConfig{Mode: "OPEN", RateLimit: 100}), nil, NewRateLimiter(""), 1)
+
+	user := &tgbotapi.User{ID: userID}
+	chat := &tgbotapi.Chat{ID: userID}
+	botMsg := &tgbotapi.Message{MessageID: 1, Chat: chat, From: &tgbotapi.User{ID: botID, IsBot: true}}
+	handleUpdate(bot, store, router, tgbotapi.Update{Message: &tgbotapi.Message{MessageID: 2, Text: "/s email:acme.com", Chat: chat, From: user}})
+	if len(buttons) != 1 {
+		t.Fatalf("export buttons = %v, want one from the search result", buttons)
+	}
+
+	for _, data := range []string{buttons[0], domainCallback + ":acme.com"} {
+		handleUpdate(bot, store, router, tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{ID: data, From: user, Message: botMsg, Data: data}})
+	}
+
+	if q, _, _ := store.GetUserQuota("42"); q.Exports != 2 || q.ExportRows != 4 {
+		t.Errorf("clicking user quota = %+v, want 2 exports and 4 rows", q)
+	}
+	if q, found, _ := store.GetUserQuota("999"); found {
+		t.Errorf("bot quota = %+v, want untouched", q)
+	}
+}
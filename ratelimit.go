@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// --- RATE LIMIT (TOKEN BUCKET) ---
+// Setiap user punya "ember" berisi maksimal RateBurst token yang terisi RateLimit token per menit
+// secara bertahap (bukan reset mendadak tiap menit). Command mengambil token sebanyak Cost-nya.
+// Ember yang sudah penuh kembali sama dengan user baru, jadi aman dibuang dari RAM.
+
+const (
+	rateLimitSweepInterval = time.Minute
+	rateLimitSaveInterval  = 30 * time.Second
+)
+
+type tokenBucket struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+	warned  bool      // Pesan RATE LIMIT sudah dikirim, jangan spam
+}
+
+type RateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[int64]*tokenBucket
+	lastSweep time.Time
+	path      string // Snapshot JSON (opsional), kosong = hanya di RAM
+}
+
+// NewRateLimiter: path diisi dari RATE_LIMIT_STATE agar batas user tidak ter-reset saat restart
+func NewRateLimiter(path string) *RateLimiter {
+	l := &RateLimiter{buckets: make(map[int64]*tokenBucket), path: path}
+	if path != "" {
+		if err := l.load(); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️ Gagal membaca state rate limit %s: %v", path, err)
+		}
+	}
+	return l
+}
+
+// Bobot token per command: export jauh lebih berat dari pencarian biasa
+const (
+	searchCost = 1
+	exportCost = 5
+)
+
+// rateBurst: kapasitas ember, default sama dengan limit per menit
+func (c SystemConfig) rateBurst() int {
+	if c.RateBurst > 0 {
+		return c.RateBurst
+	}
+	return c.RateLimit
+}
+
+func rateParams(config *SystemConfig) (burst float64, perSecond float64) {
+	return float64(config.rateBurst()), float64(config.RateLimit) / 60
+}
+
+// Allow mengambil cost token milik user. Jika tidak cukup, wait = perkiraan waktu tunggu
+// dan warn = true hanya pada penolakan pertama sejak request terakhir yang lolos.
+func (l *RateLimiter) Allow(userID int64, cost int, config *SystemConfig, now time.Time) (ok bool, wait time.Duration, warn bool) {
+	burst, perSecond := rateParams(config)
+	need := math.Min(float64(cost), burst) // Cost > burst tidak boleh mustahil dipenuhi
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(burst, perSecond, now)
+
+	b, exists := l.buckets[userID]
+	if !exists {
+		b = &tokenBucket{Tokens: burst, Updated: now}
+		l.buckets[userID] = b
+	}
+	b.refill(burst, perSecond, now)
+
+	if b.Tokens >= need {
+		b.Tokens -= need
+		b.warned = false
+		return true, 0, false
+	}
+	if perSecond > 0 {
+		wait = time.Duration((need - b.Tokens) / perSecond * float64(time.Second))
+	}
+	warn = !b.warned
+	b.warned = true
+	return false, wait, warn
+}
+
+func (b *tokenBucket) refill(burst, perSecond float64, now time.Time) {
+	if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(burst, b.Tokens+elapsed*perSecond)
+	}
+	b.Updated = now
+}
+
+// sweep membuang ember yang sudah terisi penuh (idle cukup lama), maksimal sekali per menit
+func (l *RateLimiter) sweep(burst, perSecond float64, now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for id, b := range l.buckets {
+		b.refill(burst, perSecond, now)
+		if b.Tokens >= burst {
+			delete(l.buckets, id)
+		}
+	}
+}
+
+// Len: jumlah user yang sedang dilacak
+func (l *RateLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// --- PERSISTENCE ---
+
+// Save menulis snapshot ke file sementara lalu rename, agar file tidak pernah setengah jadi
+func (l *RateLimiter) Save() error {
+	if l.path == "" {
+		return nil
+	}
+	l.mu.Lock()
+	snapshot := make(map[string]tokenBucket, len(l.buckets))
+	for id, b := range l.buckets {
+		snapshot[strconv.FormatInt(id, 10)] = *b
+	}
+	l.mu.Unlock()
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+func (l *RateLimiter) load() error {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return err
+	}
+	var snapshot map[string]tokenBucket
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	for id, b := range snapshot {
+		uid, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		b := b
+		l.buckets[uid] = &b
+	}
+	return nil
+}
+
+// StartAutoSave menyimpan snapshot secara berkala (no-op tanpa RATE_LIMIT_STATE).
+// Fungsi yang dikembalikan menghentikan loop lalu menyimpan snapshot terakhir (dipanggil saat shutdown).
+func (l *RateLimiter) StartAutoSave() (stop func()) {
+	if l.path == "" {
+		return func() {}
+	}
+	ticker := time.NewTicker(rateLimitSaveInterval)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := l.Save(); err != nil {
+					log.Printf("⚠️ Gagal menyimpan state rate limit: %v", err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			<-finished // Jangan sampai Save periodik dan Save terakhir menulis file .tmp bersamaan
+			if err := l.Save(); err != nil {
+				log.Printf("⚠️ Gagal menyimpan state rate limit: %v", err)
+			}
+		})
+	}
+}
+
+// --- MIDDLEWARE ---
+
+func rateLimitMiddleware(globalConfig *LiveConfig, limiter *RateLimiter) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *CommandContext) {
+			cost := ctx.Command.TokenCost(ctx)
+			if ctx.Role.Can(PermStaff) || cost == 0 {
+				next(ctx)
+				return
+			}
+
+			config := globalConfig.Get()
+			ok, wait, warn := limiter.Allow(ctx.User.ID, cost, &config, time.Now())
+			if !ok {
+				if warn {
+					ctx.Reply(fmt.Sprintf("⛔ **RATE LIMIT**\nBatas: %d request/menit (burst %d). Coba lagi dalam %d detik.",
+						config.RateLimit, config.rateBurst(), int(math.Ceil(wait.Seconds()))))
+				}
+				return
+			}
+			next(ctx)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRateLimiterBucket tests burst, gradual refill and weighted costs.
+func TestRateLimiterBucket(t *testing.T) {
+	config := &SystemConfig{RateLimit: 60, RateBurst: 10} // 1 token per detik
+	l := NewRateLimiter("")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 10; i++ {
+		if ok, _, _ := l.Allow(1, 1, config, now); !ok {
+			t.Fatalf("request %d within burst denied", i+1)
+		}
+	}
+	ok, wait, warn := l.Allow(1, 1, config, now)
+	if ok || !warn || wait != time.Second {
+		t.Errorf("over burst = (%v, %s, %v), want denied, 1s, warn", ok, wait, warn)
+	}
+	if _, _, warn := l.Allow(1, 1, config, now); warn {
+		t.Error("second denial should not warn again")
+	}
+
+	// 3 detik kemudian: 3 token, export (5) ditolak, pencarian lolos
+	now = now.Add(3 * time.Second)
+	if ok, wait, _ := l.Allow(1, exportCost, config, now); ok || wait != 2*time.Second {
+		t.Errorf("export with 3 tokens = (%v, %s), want denied, 2s", ok, wait)
+	}
+	if ok, _, _ := l.Allow(1, searchCost, config, now); !ok {
+		t.Error("search with 3 tokens denied")
+	}
+
+	// User lain tidak terpengaruh, cost di atas burst tetap bisa dipenuhi
+	if ok, _, _ := l.Allow(2, 50, config, now); !ok {
+		t.Error("cost above burst should be capped to burst")
+	}
+}
+
+// TestRateLimiterEviction tests that refilled buckets are dropped.
+func TestRateLimiterEviction(t *testing.T) {
+	config := &SystemConfig{RateLimit: 60}
+	l := NewRateLimiter("")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for uid := int64(1); uid <= 100; uid++ {
+		l.Allow(uid, 1, config, now)
+	}
+	if l.Len() != 100 {
+		t.Fatalf("Len() = %d, want 100", l.Len())
+	}
+
+	// 90 detik kemudian semua ember sudah penuh lagi -> dibuang saat sweep
+	l.Allow(999, 60, config, now.Add(90*time.Second))
+	if l.Len() != 1 {
+		t.Errorf("Len() after sweep = %d, want 1", l.Len())
+	}
+	// Sweep berikutnya baru semenit lagi, user baru ikut dilacak
+	l.Allow(1000, 1, config, now.Add(140*time.Second))
+	if l.Len() != 2 {
+		t.Errorf("Len() = %d, want 2 (999 & 1000)", l.Len())
+	}
+}
+
+// TestRateLimiterPersistence tests that bucket state survives a restart.
+func TestRateLimiterPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.json")
+	config := &SystemConfig{RateLimit: 5}
+	now := time.Now()
+
+	l := NewRateLimiter(path)
+	for i := 0; i < 5; i++ {
+		l.Allow(7, 1, config, now)
+	}
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewRateLimiter(path)
+	if ok, _, _ := restarted.Allow(7, 1, config, now); ok {
+		t.Error("bucket was reset by restart")
+	}
+	if ok, _, _ := restarted.Allow(8, 1, config, now); !ok {
+		t.Error("unknown user should start with a full bucket")
+	}
+}
+
+// TestRateLimiterStopAutoSave tests that stopping auto-save writes the final snapshot.
+func TestRateLimiterStopAutoSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.json")
+	config := &SystemConfig{RateLimit: 1}
+	now := time.Now()
+
+	l := NewRateLimiter(path)
+	stop := l.StartAutoSave()
+	l.Allow(7, 1, config, now)
+	stop()
+	stop() // shutdown bisa memanggil lebih dari sekali
+
+	if ok, _, _ := NewRateLimiter(path).Allow(7, 1, config, now); ok {
+		t.Error("state changed before shutdown was not saved")
+	}
+}
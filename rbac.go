@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- ROLE BASED ACCESS CONTROL ---
+// Role disimpan di Store (index user_roles), kecuali owner yang selalu diambil dari OWNER_ID.
+// Setiap command menyebut Permission yang dibutuhkan, role menentukan permission yang dimiliki.
+
+type Role string
+
+const (
+	roleOwner     Role = "owner"
+	roleAdmin     Role = "admin"
+	roleModerator Role = "moderator"
+	roleAnalyst   Role = "analyst"
+	roleUser      Role = "user"
+)
+
+// Urutan dari yang paling rendah, dipakai untuk aturan /grant & /revoke
+var roleOrder = []Role{roleUser, roleAnalyst, roleModerator, roleAdmin, roleOwner}
+
+type Permission string
+
+const (
+	PermStaff      Permission = "staff"       // Kebal ban, rate limit & kuota
+	PermPanel      Permission = "panel"       // /help versi panel
+	PermAccess     Permission = "access"      // Boleh cari/export walau mode CLOSE tanpa key
+	PermModerate   Permission = "moderate"    // /ban, /unban
+	PermAudit      Permission = "audit"       // /audit, /getusers
+	PermSystem     Permission = "system"      // /open, /close, /setlimit, /stats, /exportpolicy
+	PermKeys       Permission = "keys"        // /genkey, /keys, /revokekey, /deauth, /delkey
+	PermBroadcast  Permission = "broadcast"   // /broadcast, /notif, /sendto
+	PermIngest     Permission = "ingest"      // Upload file/URL, /jobs, /cancel
+	PermDeleteData Permission = "delete_data" // /cleansource
+	PermRoles      Permission = "roles"       // /grant, /revoke, /roles
+	PermQuota      Permission = "quota"       // /setquota, /quota <user>
+)
+
+var rolePermissions = map[Role][]Permission{
+	roleAdmin:     {PermStaff, PermPanel, PermAccess, PermModerate, PermAudit, PermSystem, PermKeys, PermBroadcast, PermIngest, PermDeleteData, PermRoles, PermQuota},
+	roleModerator: {PermPanel, PermAccess, PermModerate, PermAudit},
+	roleAnalyst:   {PermAccess},
+	roleUser:      {},
+}
+
+// RoleAssignment: satu baris di index user_roles
+type RoleAssignment struct {
+	UserID    string    `json:"user_id"`
+	Role      Role      `json:"role"`
+	GrantedBy string    `json:"granted_by"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+func parseRole(s string) (Role, bool) {
+	r := Role(strings.ToLower(strings.TrimSpace(s)))
+	for _, known := range roleOrder {
+		if r == known {
+			return r, true
+		}
+	}
+	return "", false
+}
+
+// Rank: user = 0 ... owner = 4, role tak dikenal = -1
+func (r Role) Rank() int {
+	for i, known := range roleOrder {
+		if r == known {
+			return i
+		}
+	}
+	return -1
+}
+
+// Can: owner memiliki semua permission
+func (r Role) Can(p Permission) bool {
+	if r == roleOwner {
+		return true
+	}
+	for _, have := range rolePermissions[r] {
+		if have == p {
+			return true
+		}
+	}
+	return false
+}
+
+// canAssignRole: aktor hanya boleh mengubah user yang role-nya di bawah dia,
+// dan hanya memberi role di bawah role-nya sendiri. Owner tidak bisa diberikan lewat bot.
+func canAssignRole(actor, current, target Role) bool {
+	if !actor.Can(PermRoles) || target == roleOwner {
+		return false
+	}
+	return current.Rank() < actor.Rank() && target.Rank() < actor.Rank()
+}
+
+// resolveRole: OWNER_ID selalu owner, selain itu dari Store (default user)
+func resolveRole(store Store, ownerID, userID int64) Role {
+	if userID == ownerID {
+		return roleOwner
+	}
+	return store.GetUserRole(strconv.FormatInt(userID, 10))
+}
+
+// --- HANDLERS ---
+
+// /grant <id> <role>
+func handleGrant(ctx *CommandContext, ownerID int64) {
+	parts := strings.Fields(ctx.Args)
+	if len(parts) != 2 {
+		ctx.ReplyUsage()
+		return
+	}
+	role, ok := parseRole(parts[1])
+	if !ok {
+		ctx.Reply(fmt.Sprintf("❌ Role tidak dikenal. Pilihan: %s", roleNames()))
+		return
+	}
+	assignRole(ctx, ownerID, parts[0], role)
+}
+
+// /revoke <id> -> kembali jadi user biasa
+func handleRevoke(ctx *CommandContext, ownerID int64) {
+	parts := strings.Fields(ctx.Args)
+	if len(parts) != 1 {
+		ctx.ReplyUsage()
+		return
+	}
+	assignRole(ctx, ownerID, parts[0], roleUser)
+}
+
+func assignRole(ctx *CommandContext, ownerID int64, target string, role Role) {
+	targetID, err := strconv.ParseInt(target, 10, 64)
+	if err != nil {
+		ctx.Reply("❌ ID user harus berupa angka.")
+		return
+	}
+	current := resolveRole(ctx.Store, ownerID, targetID)
+	if !canAssignRole(ctx.Role, current, role) {
+		ctx.Reply(fmt.Sprintf("⛔ Anda (%s) tidak bisa mengubah role user ini (%s) menjadi %s.", ctx.Role, current, role))
+		return
+	}
+
+	ctx.Store.SetUserRole(RoleAssignment{
+		UserID:    target,
+		Role:      role,
+		GrantedBy: strconv.FormatInt(ctx.User.ID, 10),
+		GrantedAt: time.Now(),
+	})
+	ctx.Store.LogActivity(ctx.User, "ROLE", fmt.Sprintf("%s: %s -> %s", target, current, role))
+	ctx.Reply(fmt.Sprintf("✅ **ROLE UPDATED**\nUser `%s`: %s ➡️ %s", target, current, role))
+
+	if role != roleUser {
+		ctx.Bot.Send(tgbotapi.NewMessage(targetID, fmt.Sprintf("🎖 Anda sekarang memiliki role *%s*. Ketik /help untuk melihat command.", role)))
+	}
+}
+
+// /roles: daftar user yang punya role selain user
+func handleListRoles(ctx *CommandContext) {
+	assignments := ctx.Store.ListUserRoles()
+	if len(assignments) == 0 {
+		ctx.Reply("ℹ️ Belum ada role yang diberikan (selain owner).")
+		return
+	}
+	sort.Slice(assignments, func(i, j int) bool {
+		if assignments[i].Role.Rank() != assignments[j].Role.Rank() {
+			return assignments[i].Role.Rank() > assignments[j].Role.Rank()
+		}
+		return assignments[i].UserID < assignments[j].UserID
+	})
+
+	var sb strings.Builder
+	sb.WriteString("🎖 *DAFTAR ROLE*\n")
+	for _, a := range assignments {
+		sb.WriteString(fmt.Sprintf("▪️ `%s` — %s (oleh `%s`, %s)\n", a.UserID, a.Role, a.GrantedBy, a.GrantedAt.Format("2006-01-02")))
+	}
+	reply := tgbotapi.NewMessage(ctx.ChatID, sb.String())
+	reply.ParseMode = "Markdown"
+	ctx.Bot.Send(reply)
+}
+
+func roleNames() string {
+	var names []string
+	for _, r := range roleOrder {
+		if r != roleOwner {
+			names = append(names, string(r))
+		}
+	}
+	return strings.Join(names, ", ")
+}
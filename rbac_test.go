@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+// TestCanAssignRole tests who may grant and revoke which roles.
+func TestCanAssignRole(t *testing.T) {
+	tests := []struct {
+		actor, current, target Role
+		want                   bool
+	}{
+		{roleOwner, roleUser, roleAdmin, true},
+		{roleOwner, roleAdmin, roleUser, true},
+		{roleOwner, roleUser, roleOwner, false},
+		{roleAdmin, roleUser, roleModerator, true},
+		{roleAdmin, roleAnalyst, roleUser, true},
+		{roleAdmin, roleUser, roleAdmin, false},
+		{roleAdmin, roleAdmin, roleUser, false},
+		{roleModerator, roleUser, roleAnalyst, false},
+		{roleUser, roleUser, roleAnalyst, false},
+	}
+	for _, tt := range tests {
+		if got := canAssignRole(tt.actor, tt.current, tt.target); got != tt.want {
+			t.Errorf("canAssignRole(%s, %s, %s) = %v, want %v", tt.actor, tt.current, tt.target, got, tt.want)
+		}
+	}
+}
+
+// TestResolveRole tests that OWNER_ID always wins and revoked users fall back to user.
+func TestResolveRole(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetUserRole(RoleAssignment{UserID: "42", Role: roleAnalyst})
+
+	if got := resolveRole(store, 1, 1); got != roleOwner {
+		t.Errorf("owner role = %s", got)
+	}
+	if got := resolveRole(store, 1, 42); got != roleAnalyst {
+		t.Errorf("granted role = %s, want analyst", got)
+	}
+	store.SetUserRole(RoleAssignment{UserID: "42", Role: roleUser})
+	if got := resolveRole(store, 1, 42); got != roleUser || len(store.ListUserRoles()) != 0 {
+		t.Errorf("revoked role = %s, roles = %v", got, store.ListUserRoles())
+	}
+	if !roleAnalyst.Can(PermAccess) || roleAnalyst.Can(PermStaff) || !roleOwner.Can(PermRoles) {
+		t.Error("unexpected analyst/owner permissions")
+	}
+	// Moderator hanya ban/unban & audit, tidak kebal ban, rate limit & kuota
+	if roleModerator.Can(PermStaff) || !roleModerator.Can(PermPanel) || !roleAdmin.Can(PermStaff) {
+		t.Error("moderator must see the panel without the staff exemptions")
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- COMMAND ROUTER ---
+
+// CommandContext berisi semua yang dibutuhkan handler untuk memproses satu pesan
+type CommandContext struct {
+	Bot     *tgbotapi.BotAPI
+	Store   Store
+	Msg     *tgbotapi.Message
+	ChatID  int64
+	User    *tgbotapi.User
+	Role    Role // Diisi roleMiddleware
+	Command *Command
+	Args    string // Teks setelah token command, sudah di-trim
+
+	// Diisi jika update berasal dari tombol inline keyboard (Msg = pesan bot yang diklik)
+	Callback     *tgbotapi.CallbackQuery
+	callbackText string
+}
+
+// Reply mengirim pesan teks biasa ke chat asal
+func (ctx *CommandContext) Reply(text string) {
+	ctx.Bot.Send(tgbotapi.NewMessage(ctx.ChatID, text))
+}
+
+// ReplyUsage mengirim format penggunaan command
+func (ctx *CommandContext) ReplyUsage() {
+	ctx.Reply(fmt.Sprintf("⚠️ Gunakan: `%s`", ctx.Command.Usage))
+}
+
+// Answer mengisi teks notifikasi singkat untuk callback (dikirim router setelah handler selesai)
+func (ctx *CommandContext) Answer(text string) {
+	ctx.callbackText = text
+}
+
+type HandlerFunc func(ctx *CommandContext)
+
+// Middleware membungkus handler (cek ban, admin, rate limit, akses, dll)
+type Middleware func(next HandlerFunc) HandlerFunc
+
+type Command struct {
+	Name    string   // Token command, contoh: "/s"
+	Aliases []string // Token lain yang menuju handler yang sama (tidak tampil di /help)
+
+	// Match dipakai untuk pesan non-command (upload file, link URL).
+	// Jika diisi, Name boleh kosong.
+	Match func(msg *tgbotapi.Message) bool
+
+	// Callback adalah prefix callback data inline keyboard, contoh "pg" untuk "pg:<args>"
+	Callback string
+
+	Handler        HandlerFunc
+	Perm           Permission // Permission wajib (kosong = semua role)
+	RequiresAccess bool       // Wajib OPEN mode / whitelist
+	Cost           int        // Bobot rate limit / token (0 = tidak dihitung)
+	Quota          QuotaKind  // Kuota harian/bulanan yang dipotong (lihat quota.go)
+
+	// CostFunc opsional: bobot yang tergantung argumen (menimpa Cost)
+	CostFunc func(ctx *CommandContext) int
+
+	Category string // Judul grup di /help
+	Usage    string // Contoh: "/setlimit <n>"
+	Help     string // Deskripsi singkat di /help
+}
+
+// TokenCost: bobot rate limit command untuk request ini
+func (c *Command) TokenCost(ctx *CommandContext) int {
+	if c.CostFunc != nil {
+		return c.CostFunc(ctx)
+	}
+	return c.Cost
+}
+
+type Router struct {
+	commands    map[string]*Command
+	ordered     []*Command // Urutan registrasi, dipakai /help
+	matchers    []*Command
+	callbacks   map[string]*Command
+	middlewares []Middleware
+}
+
+func NewRouter() *Router {
+	return &Router{commands: make(map[string]*Command), callbacks: make(map[string]*Command)}
+}
+
+// Use menambahkan middleware. Middleware pertama adalah yang paling luar.
+func (r *Router) Use(mw ...Middleware) {
+	r.middlewares = append(r.middlewares, mw...)
+}
+
+func (r *Router) Handle(cmd *Command) {
+	if cmd.Name != "" {
+		r.commands[cmd.Name] = cmd
+		for _, alias := range cmd.Aliases {
+			r.commands[alias] = cmd
+		}
+	}
+	if cmd.Match != nil {
+		r.matchers = append(r.matchers, cmd)
+	}
+	if cmd.Callback != "" {
+		r.callbacks[cmd.Callback] = cmd
+	}
+	r.ordered = append(r.ordered, cmd)
+}
+
+// Lookup mencari command berdasarkan token persis (bukan prefix)
+func (r *Router) Lookup(name string) (*Command, bool) {
+	cmd, ok := r.commands[name]
+	return cmd, ok
+}
+
+// Dispatch mencari command untuk pesan di ctx lalu menjalankannya lewat rantai middleware.
+// Return false jika tidak ada command yang cocok.
+func (r *Router) Dispatch(ctx *CommandContext) bool {
+	ctx.ChatID = ctx.Msg.Chat.ID
+	ctx.User = ctx.Msg.From
+
+	name, args := parseCommand(ctx.Msg.Text)
+	cmd, ok := r.commands[name]
+	if !ok {
+		cmd = nil
+		for _, m := range r.matchers {
+			if m.Match(ctx.Msg) {
+				cmd = m
+				break
+			}
+		}
+		if cmd == nil {
+			return false
+		}
+		args = strings.TrimSpace(ctx.Msg.Text)
+	}
+
+	ctx.Command = cmd
+	ctx.Args = args
+	r.run(ctx)
+	return true
+}
+
+// DispatchCallback menjalankan handler tombol inline keyboard lewat middleware yang sama
+// (ban, akses, rate limit). Callback selalu dijawab agar loading di tombol berhenti.
+func (r *Router) DispatchCallback(ctx *CommandContext) bool {
+	cb := ctx.Callback
+	ctx.Msg = cb.Message
+	ctx.ChatID = cb.Message.Chat.ID
+	ctx.User = cb.From
+
+	prefix, args, _ := strings.Cut(cb.Data, ":")
+	cmd, ok := r.callbacks[prefix]
+	if !ok {
+		ctx.Bot.Request(tgbotapi.NewCallback(cb.ID, ""))
+		return false
+	}
+
+	ctx.Command = cmd
+	ctx.Args = args
+	r.run(ctx)
+	ctx.Bot.Request(tgbotapi.NewCallback(cb.ID, ctx.callbackText))
+	return true
+}
+
+func (r *Router) run(ctx *CommandContext) {
+	h := ctx.Command.Handler
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		h = r.middlewares[i](h)
+	}
+	h(ctx)
+}
+
+// parseCommand memecah "/cmd@NamaBot arg1 arg2" menjadi ("/cmd", "arg1 arg2")
+func parseCommand(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", ""
+	}
+
+	name, args := text, ""
+	if i := strings.IndexAny(text, " \t\n"); i >= 0 {
+		name, args = text[:i], strings.TrimSpace(text[i+1:])
+	}
+	if at := strings.Index(name, "@"); at >= 0 {
+		name = name[:at]
+	}
+	return strings.ToLower(name), args
+}
+
+// HelpText menyusun daftar command per kategori dari registry, hanya yang boleh dipakai role
+func (r *Router) HelpText(role Role) string {
+	var categories []string
+	grouped := make(map[string][]*Command)
+
+	for _, cmd := range r.ordered {
+		if cmd.Perm != "" && !role.Can(cmd.Perm) {
+			continue
+		}
+		if cmd.Help == "" {
+			continue
+		}
+		if _, exists := grouped[cmd.Category]; !exists {
+			categories = append(categories, cmd.Category)
+		}
+		grouped[cmd.Category] = append(grouped[cmd.Category], cmd)
+	}
+
+	var sb strings.Builder
+	for _, cat := range categories {
+		sb.WriteString("\n" + cat + "\n")
+		for _, cmd := range grouped[cat] {
+			if cmd.Name == "" {
+				// Handler non-command (upload) tidak punya token
+				sb.WriteString("• " + cmd.Help + "\n")
+				continue
+			}
+			usage := cmd.Usage
+			if usage == "" {
+				usage = cmd.Name
+			}
+			sb.WriteString(fmt.Sprintf("`%s` — %s\n", usage, cmd.Help))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// TestParseCommand tests the parseCommand function.
+func TestParseCommand(t *testing.T) {
+	parseTests := []struct {
+		input string
+		name  string
+		args  string
+	}{
+		{"/s rudi", "/s", "rudi"},
+		{"/stats", "/stats", ""},
+		{"/S@BreachRadarBot  email:a@b.com ", "/s", "email:a@b.com"},
+		{"/sendto 123 halo dunia", "/sendto", "123 halo dunia"},
+		{"halo", "", ""},
+	}
+
+	for _, tt := range parseTests {
+		t.Run(tt.input, func(t *testing.T) {
+			name, args := parseCommand(tt.input)
+			if name != tt.name || args != tt.args {
+				t.Errorf("parseCommand() = (%q, %q), want (%q, %q)", name, args, tt.name, tt.args)
+			}
+		})
+	}
+}
+
+// TestRouterExactMatch makes sure /s no longer swallows /stats, /sendto or /setlimit.
+func TestRouterExactMatch(t *testing.T) {
+	r := NewRouter()
+	var called string
+	for _, name := range []string{"/s", "/stats", "/ban", "/banlist"} {
+		name := name
+		r.Handle(&Command{Name: name, Handler: func(ctx *CommandContext) { called = name }})
+	}
+
+	dispatchTests := []struct {
+		text     string
+		expected string
+		ok       bool
+	}{
+		{"/s rudi", "/s", true},
+		{"/stats", "/stats", true},
+		{"/banlist", "/banlist", true},
+		{"/ban 123", "/ban", true},
+		{"/setlimit 5", "", false},
+	}
+
+	for _, tt := range dispatchTests {
+		t.Run(tt.text, func(t *testing.T) {
+			called = ""
+			msg := &tgbotapi.Message{Text: tt.text, Chat: &tgbotapi.Chat{ID: 1}, From: &tgbotapi.User{ID: 1}}
+			ok := r.Dispatch(&CommandContext{Msg: msg})
+			if ok != tt.ok || called != tt.expected {
+				t.Errorf("Dispatch(%q) = (%v, %q), want (%v, %q)", tt.text, ok, called, tt.ok, tt.expected)
+			}
+		})
+	}
+}
+
+// TestRouterPermissions tests that commands are skipped for roles without the permission.
+func TestRouterPermissions(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetUserRole(RoleAssignment{UserID: "2", Role: roleModerator})
+	store.SetUserRole(RoleAssignment{UserID: "3", Role: roleAdmin})
+
+	r := NewRouter()
+	r.Use(roleMiddleware(99), permissionMiddleware())
+	var called string
+	for name, perm := range map[string]Permission{"/delkey": PermKeys, "/cleansource": PermDeleteData, "/ban": PermModerate, "/audit": PermAudit} {
+		name := name
+		r.Handle(&Command{Name: name, Perm: perm, Handler: func(ctx *CommandContext) { called = name }})
+	}
+	r.Handle(&Command{Perm: PermIngest, Match: func(msg *tgbotapi.Message) bool { return msg.Document != nil }, Handler: func(ctx *CommandContext) { called = "upload" }})
+
+	permTests := []struct {
+		userID int64
+		text   string
+		upload bool
+		want   bool
+	}{
+		{1, "/delkey", false, false},
+		{1, "/ban 5", false, false},
+		{2, "/ban 5", false, true},
+		{2, "/audit 5", false, true},
+		{2, "/delkey", false, false},
+		{2, "/cleansource a.txt", false, false},
+		{2, "", true, false},
+		{3, "/delkey", false, true},
+		{3, "", true, true},
+		{99, "/cleansource a.txt", false, true},
+	}
+
+	for _, tt := range permTests {
+		called = ""
+		msg := &tgbotapi.Message{Text: tt.text, Chat: &tgbotapi.Chat{ID: 1}, From: &tgbotapi.User{ID: tt.userID}}
+		if tt.upload {
+			msg.Document = &tgbotapi.Document{FileName: "leak.csv"}
+		}
+		r.Dispatch(&CommandContext{Msg: msg, Store: store})
+		if got := called != ""; got != tt.want {
+			t.Errorf("user %d %q called = %v, want %v", tt.userID, tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- PAGINATED SEARCH (Inline Keyboard) ---
+
+const (
+	searchPageSize   = 5
+	searchSessionTTL = 10 * time.Minute // Harus lebih pendek dari pitKeepAlive
+	searchCallback   = "pg"             // Callback data: "pg:<sessionID>:<next|prev|export>"
+)
+
+// SearchSession menyimpan state pencarian terakhir satu user agar tombol Next/Prev
+// bisa melanjutkan query tanpa mengulang dari awal.
+type SearchSession struct {
+	ID        string
+	UserID    int64
+	ChatID    int64
+	Keyword   string
+	ExpiresAt time.Time // Dijaga SearchSessions.mu
+
+	// Dijaga mu: user yang sama bisa mengklik dari grup & chat pribadi bersamaan
+	// (dispatcher hanya mengurutkan per chat), dan Start menutup PIT sesi user lain
+	mu        sync.Mutex
+	MessageID int
+	Page      int
+	PIT       string         // PIT sesi, dipakai ulang semua halaman (termasuk kembali ke halaman 0)
+	Cursors   []SearchCursor // Cursors[i] = posisi awal halaman i (search_after)
+	Total     int
+	closed    bool // PIT sudah ditutup, halaman berikutnya tidak boleh diambil lagi
+}
+
+var errSessionClosed = errors.New("sesi pencarian sudah berakhir")
+
+// SearchSessions: satu sesi aktif per user, /s baru menggantikan sesi lama
+type SearchSessions struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	byUser map[int64]*SearchSession
+}
+
+func NewSearchSessions(ttl time.Duration) *SearchSessions {
+	return &SearchSessions{ttl: ttl, byUser: make(map[int64]*SearchSession)}
+}
+
+// Start membuat sesi baru. PIT sesi lama user ini & sesi lain yang sudah kedaluwarsa ditutup
+// agar tidak menumpuk sampai batas search.max_open_pit_context.
+func (ss *SearchSessions) Start(store Store, userID, chatID int64, keyword string) *SearchSession {
+	ss.mu.Lock()
+	var stale []*SearchSession
+
+	// Bersihkan sesi kedaluwarsa sekalian (tanpa goroutine terpisah)
+	now := time.Now()
+	for uid, s := range ss.byUser {
+		if now.After(s.ExpiresAt) || uid == userID {
+			stale = append(stale, s)
+			delete(ss.byUser, uid)
+		}
+	}
+
+	s := &SearchSession{
+		ID:        newSessionID(),
+		UserID:    userID,
+		ChatID:    chatID,
+		Keyword:   keyword,
+		Cursors:   []SearchCursor{{}},
+		ExpiresAt: now.Add(ss.ttl),
+	}
+	ss.byUser[userID] = s
+	ss.mu.Unlock()
+
+	closeSessions(store, stale)
+	return s
+}
+
+// Get mengembalikan sesi jika masih aktif & ID-nya cocok (tombol dari hasil lama ditolak)
+func (ss *SearchSessions) Get(store Store, userID int64, id string) *SearchSession {
+	ss.mu.Lock()
+	s, ok := ss.byUser[userID]
+	if !ok || s.ID != id {
+		ss.mu.Unlock()
+		return nil
+	}
+	if time.Now().After(s.ExpiresAt) {
+		delete(ss.byUser, userID)
+		ss.mu.Unlock()
+		closeSessions(store, []*SearchSession{s})
+		return nil
+	}
+	s.ExpiresAt = time.Now().Add(ss.ttl)
+	ss.mu.Unlock()
+	return s
+}
+
+// End membuang sesi (misal pesan hasil gagal terkirim sehingga tombolnya tidak pernah ada)
+func (ss *SearchSessions) End(store Store, s *SearchSession) {
+	ss.mu.Lock()
+	if ss.byUser[s.UserID] == s {
+		delete(ss.byUser, s.UserID)
+	}
+	ss.mu.Unlock()
+	closeSessions(store, []*SearchSession{s})
+}
+
+// closeSessions dipanggil di luar SearchSessions.mu karena CloseSearchCursor melakukan request ke ES.
+// Lock sesi menunggu fetchSearchPage yang sedang jalan, jadi PIT terbaru yang ditutup.
+func closeSessions(store Store, sessions []*SearchSession) {
+	for _, s := range sessions {
+		s.mu.Lock()
+		pit := s.PIT
+		s.closed = true
+		s.mu.Unlock()
+		if pit != "" {
+			store.CloseSearchCursor(SearchCursor{PIT: pit})
+		}
+	}
+}
+
+// state: salinan field yang dibutuhkan handler tombol
+func (s *SearchSession) state() (messageID, page, pages int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.MessageID, s.Page, len(s.Cursors)
+}
+
+func (s *SearchSession) setMessageID(id int) {
+	s.mu.Lock()
+	s.MessageID = id
+	s.mu.Unlock()
+}
+
+func newSessionID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// fetchSearchPage mengambil halaman `page` dari sesi lalu menyusun teks & tombolnya
+func fetchSearchPage(store Store, s *SearchSession, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return "", nil, errSessionClosed
+	}
+	if page >= len(s.Cursors) {
+		page = len(s.Cursors) - 1
+	}
+	cursor := s.Cursors[page]
+	cursor.PIT = s.PIT
+	result, next, err := store.SearchBreachesAfter(s.Keyword, searchPageSize, cursor)
+	if err != nil {
+		return "", nil, err
+	}
+	if next.PIT != "" {
+		s.PIT = next.PIT // ES bisa mengembalikan ID PIT baru di setiap response
+	}
+	if len(s.Cursors) == page+1 {
+		s.Cursors = append(s.Cursors, next)
+	}
+	s.Page = page
+	s.Total = result.Hits.Total.Value
+
+	if s.Total == 0 {
+		return fmt.Sprintf("✅ *AMAN!*\nNihil: `%s`", escapeMarkdown(s.Keyword)), nil, nil
+	}
+
+	totalPages := (s.Total + searchPageSize - 1) / searchPageSize
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🚨 *DATA FOUND!*\nKeyword: `%s`\nResult: %d Data • Halaman %d/%d\n\n", escapeMarkdown(s.Keyword), s.Total, page+1, totalPages))
+	for _, hit := range result.Hits.Hits {
+		sb.WriteString(formatSearchRecord(hit))
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️ Prev", searchCallbackData(s, "prev")))
+	}
+	if page+1 < totalPages {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Next ➡️", searchCallbackData(s, "next")))
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📥 Export", searchCallbackData(s, "export"))))
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return sb.String(), &markup, nil
+}
+
+func searchCallbackData(s *SearchSession, action string) string {
+	return searchCallback + ":" + s.ID + ":" + action
+}
+
+// handleSearchCallback memproses klik Next/Prev/Export dan mengedit pesan hasil yang sama
+func handleSearchCallback(ctx *CommandContext, sessions *SearchSessions, config SystemConfig) {
+	id, action, _ := strings.Cut(ctx.Args, ":")
+	s := sessions.Get(ctx.Store, ctx.User.ID, id)
+	var messageID, page, pages int
+	if s != nil {
+		messageID, page, pages = s.state()
+	}
+	if s == nil || ctx.Msg.MessageID != messageID {
+		ctx.Answer("⌛ Sesi pencarian sudah kedaluwarsa, ulangi dengan /s")
+		// Hapus tombol agar tidak diklik lagi
+		ctx.Bot.Send(tgbotapi.NewEditMessageReplyMarkup(ctx.ChatID, ctx.Msg.MessageID, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+		return
+	}
+
+	switch action {
+	case "next":
+		if pages <= page+1 {
+			return
+		}
+		page++
+	case "prev":
+		if page == 0 {
+			return
+		}
+		page--
+	case "export":
+		ctx.Store.LogActivity(ctx.User, "EXPORT", s.Keyword)
+		ctx.Answer("📄 Menyiapkan export...")
+		handleExport(ctx.Bot, ctx.Msg, ctx.Store, ExportRequest{Format: "csv", Keyword: s.Keyword}.withPolicy(ctx, &config))
+		return
+	default:
+		return
+	}
+
+	text, markup, err := fetchSearchPage(ctx.Store, s, page)
+	if err == errSessionClosed {
+		ctx.Answer("⌛ Sesi pencarian sudah kedaluwarsa, ulangi dengan /s")
+		return
+	}
+	if err != nil {
+		ctx.Answer("❌ Error Database.")
+		return
+	}
+	edit := tgbotapi.NewEditMessageText(ctx.ChatID, messageID, text)
+	edit.ParseMode = "Markdown"
+	edit.ReplyMarkup = markup
+	ctx.Bot.Send(edit)
+}
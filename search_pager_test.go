@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pitStore hands out a PIT per opened cursor and records which ones are closed.
+type pitStore struct {
+	*MemoryStore
+	mu     sync.Mutex
+	opened int
+	closed map[string]bool
+}
+
+func (p *pitStore) SearchBreachesAfter(keyword string, size int, cursor SearchCursor) (*ESResponse, SearchCursor, error) {
+	p.mu.Lock()
+	if cursor.PIT == "" {
+		p.opened++
+		cursor.PIT = fmt.Sprintf("pit-%d", p.opened)
+	}
+	p.mu.Unlock()
+	result, next, err := p.MemoryStore.SearchBreachesAfter(keyword, size, cursor)
+	next.PIT = cursor.PIT
+	return result, next, err
+}
+
+func (p *pitStore) CloseSearchCursor(cursor SearchCursor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed[cursor.PIT] = true
+}
+
+// TestSearchSessions tests per-user replacement, stale button rejection and expiry.
+func TestSearchSessions(t *testing.T) {
+	store := NewMemoryStore()
+	sessions := NewSearchSessions(50 * time.Millisecond)
+
+	old := sessions.Start(store, 1, 100, "budi")
+	current := sessions.Start(store, 1, 100, "sari")
+	other := sessions.Start(store, 2, 200, "joko")
+
+	if sessions.Get(store, 1, old.ID) != nil {
+		t.Error("Get() returned a replaced session")
+	}
+	if s := sessions.Get(store, 1, current.ID); s == nil || s.Keyword != "sari" {
+		t.Errorf("Get() = %+v, want current session", s)
+	}
+	if sessions.Get(store, 2, current.ID) != nil {
+		t.Error("Get() returned another user's session")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if sessions.Get(store, 2, other.ID) != nil {
+		t.Error("Get() returned an expired session")
+	}
+}
+
+// TestSearchSessionPIT tests that paging reuses one PIT and replaced or expired sessions close it.
+func TestSearchSessionPIT(t *testing.T) {
+	store := &pitStore{MemoryStore: NewMemoryStore(), closed: make(map[string]bool)}
+	for i := 0; i < 12; i++ {
+		store.IndexDocument(map[string]interface{}{"full_text": "budi", "leak_source": "a.txt"}, fmt.Sprint(i))
+	}
+	sessions := NewSearchSessions(50 * time.Millisecond)
+
+	s := sessions.Start(store, 1, 100, "budi")
+	for _, page := range []int{0, 1, 0, 1, 2} {
+		if _, _, err := fetchSearchPage(store, s, page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.opened != 1 || s.PIT != "pit-1" {
+		t.Errorf("opened %d PITs (session PIT %q), want 1 reused across pages", store.opened, s.PIT)
+	}
+
+	next := sessions.Start(store, 1, 100, "budi")
+	if !store.closed["pit-1"] {
+		t.Error("replaced session PIT not closed")
+	}
+	fetchSearchPage(store, next, 0)
+	time.Sleep(60 * time.Millisecond)
+	if sessions.Get(store, 1, next.ID) != nil || !store.closed["pit-2"] {
+		t.Errorf("expired session PIT not closed: %v", store.closed)
+	}
+}
+
+// TestSearchSessionConcurrent tests paging racing with a new /s from the same user (run with -race).
+func TestSearchSessionConcurrent(t *testing.T) {
+	store := &pitStore{MemoryStore: NewMemoryStore(), closed: make(map[string]bool)}
+	for i := 0; i < 30; i++ {
+		store.IndexDocument(map[string]interface{}{"full_text": "budi", "leak_source": "a.txt"}, fmt.Sprint(i))
+	}
+	sessions := NewSearchSessions(time.Minute)
+	s := sessions.Start(store, 1, 100, "budi")
+	if _, _, err := fetchSearchPage(store, s, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, page, _ := s.state()
+			fetchSearchPage(store, s, page+1)
+		}()
+	}
+	sessions.Start(store, 1, 100, "sari")
+	wg.Wait()
+
+	if _, _, err := fetchSearchPage(store, s, 0); err != errSessionClosed {
+		t.Errorf("fetchSearchPage() on replaced session = %v, want errSessionClosed", err)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if !store.closed[s.PIT] {
+		t.Errorf("PIT %q of the replaced session was not closed", s.PIT)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Store adalah lapisan penyimpanan yang dipakai semua handler.
+// Implementasi: ElasticStore (produksi) dan MemoryStore (lokal & unit test).
+type Store interface {
+	// --- BREACH DATA ---
+	SearchBreaches(keyword string, size int) (*ESResponse, error)
+	// SearchBreachesAfter mengambil satu halaman mulai dari cursor, return cursor halaman berikutnya
+	SearchBreachesAfter(keyword string, size int, cursor SearchCursor) (*ESResponse, SearchCursor, error)
+	// CloseSearchCursor melepas resource cursor (PIT) setelah paging selesai
+	CloseSearchCursor(cursor SearchCursor)
+	IndexDocument(doc map[string]interface{}, id string)
+	BulkIndex(docs []BulkDocument) ([]BulkItemResult, error)
+	DeleteBySource(filename string) int
+	GetClusterStats() SystemStats
+	RefreshBreaches() // Data hasil bulk ingest langsung bisa dicari (tanpa menunggu refresh interval)
+	// DomainReport meringkas eksposur satu domain (/domain) dari aggregation breach_data
+	DomainReport(domain string) (DomainReport, error)
+
+	// --- ACCESS KEYS ---
+	SaveAccessKey(key AccessKey)
+	GetAccessKey(key string) (AccessKey, bool)
+	RedeemAccessKey(key, userID string, now time.Time) (AccessKey, error) // Validasi + catat pemakaian secara atomik
+	ListAccessKeys() []AccessKey
+	RevokeAccessKey(key string) bool // Nonaktifkan satu key, false jika tidak ada
+	DeleteAccessKey(key string)
+	ResetAllAccess()
+
+	// --- AUTHORIZED USERS ---
+	AuthorizeUser(user AuthorizedUser)
+	GetAuthorizedUser(userID string) (AuthorizedUser, bool)
+	IsUserAuthorized(userID string) bool // false jika langganan sudah lewat
+	GetAllVerifiedUserIDs() []int64      // Hanya user yang aksesnya masih berlaku
+	DeauthorizeUser(userID string) bool  // Keluarkan satu user dari whitelist, false jika tidak ada
+
+	// --- BLACKLIST ---
+	IsUserBanned(userID string) bool
+	BanUser(userID string, reason string)
+	UnbanUser(userID string)
+
+	// --- ROLES ---
+	GetUserRole(userID string) Role        // Default roleUser
+	SetUserRole(assignment RoleAssignment) // roleUser = hapus role
+	ListUserRoles() []RoleAssignment       // Hanya role selain user
+
+	// --- QUOTAS ---
+	// GetUserQuota: false jika user belum pernah dihitung, error jika Store gagal dibaca
+	// (jangan diperlakukan sebagai kuota kosong, save berikutnya akan menimpa hitungan & override)
+	GetUserQuota(userID string) (UserQuota, bool, error)
+	// UpdateUserQuota: read-modify-write atomik, fn bisa dipanggil ulang jika bentrok dengan update lain.
+	// Error dari fn membatalkan update dan dikembalikan apa adanya.
+	UpdateUserQuota(userID string, fn func(q *UserQuota) error) (UserQuota, error)
+
+	// --- API TOKENS ---
+	SaveAPIToken(token APIToken)
+	GetAPIToken(hash string) (APIToken, bool)
+	ListAPITokens(userID string) []APIToken // userID kosong = semua token
+	DeleteAPIToken(hash string) bool
+
+	// --- WATCHLISTS ---
+	SaveWatch(watch Watch)
+	ListWatches(userID string) []Watch // userID kosong = semua watch
+	DeleteWatch(id string) bool
+	// ClaimWatchAlerts mencatat record yang akan dikirim ke user secara atomik,
+	// return hanya record yang belum pernah dikirim ke user tersebut
+	ClaimWatchAlerts(userID string, recordIDs []string) []string
+	// ReleaseWatchAlerts membatalkan klaim jika alert gagal dikirim, record ikut alert berikutnya
+	ReleaseWatchAlerts(userID string, recordIDs []string)
+
+	// --- SYSTEM CONFIG ---
+	GetSystemConfig() SystemConfig
+	SaveSystemConfig(config SystemConfig)
+
+	// --- ACTIVITY LOGS ---
+	LogActivity(user *tgbotapi.User, action string, content string)
+	SearchActivity(keyword string, size int) (*ESResponse, error)
+	GetAllUniqueLogUserIDs() []int64
+	GenerateUserReport() []UserReport
+
+	// --- INGEST JOBS ---
+	SaveIngestJob(job IngestJobRecord)
+	ListIngestJobs() []IngestJobRecord // Terbaru dulu
+}
+
+// Pastikan kedua implementasi selalu memenuhi interface
+var (
+	_ Store = (*ElasticStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
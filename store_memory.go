@@ -0,0 +1,684 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MemoryStore menyimpan semua data di RAM.
+// Dipakai untuk menjalankan bot secara lokal tanpa cluster dan untuk unit test handler.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	breaches     map[string]map[string]interface{}
+	breachOrder  []string // Urutan insert agar hasil pencarian stabil
+	accessKeys   map[string]AccessKey
+	authorized   map[string]AuthorizedUser
+	blacklist    map[string]BlacklistEntry
+	roles        map[string]RoleAssignment
+	quotas       map[string]UserQuota
+	apiTokens    map[string]APIToken
+	watches      map[string]Watch
+	alerted      map[string]bool // userID + "\x00" + recordID
+	config       *SystemConfig
+	activityLogs []UserActivity
+	ingestJobs   map[string]IngestJobRecord
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		breaches:   make(map[string]map[string]interface{}),
+		accessKeys: make(map[string]AccessKey),
+		authorized: make(map[string]AuthorizedUser),
+		blacklist:  make(map[string]BlacklistEntry),
+		roles:      make(map[string]RoleAssignment),
+		quotas:     make(map[string]UserQuota),
+		apiTokens:  make(map[string]APIToken),
+		watches:    make(map[string]Watch),
+		alerted:    make(map[string]bool),
+		ingestJobs: make(map[string]IngestJobRecord),
+	}
+}
+
+// --- BREACH DATA ---
+
+func (m *MemoryStore) SearchBreaches(keyword string, size int) (*ESResponse, error) {
+	node, err := parseSearchQuery(keyword)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result ESResponse
+	for _, id := range m.breachOrder {
+		doc := m.breaches[id]
+		if !node.matches(doc) {
+			continue
+		}
+		result.Hits.Total.Value++
+		if len(result.Hits.Hits) < size {
+			result.Hits.Hits = append(result.Hits.Hits, ESHit{ID: id, Source: copyDoc(doc)})
+		}
+	}
+	return &result, nil
+}
+
+// SearchBreachesAfter: cursor.After = [posisi dokumen terakhir di breachOrder]
+func (m *MemoryStore) SearchBreachesAfter(keyword string, size int, cursor SearchCursor) (*ESResponse, SearchCursor, error) {
+	node, err := parseSearchQuery(keyword)
+	if err != nil {
+		return nil, cursor, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start := 0
+	if len(cursor.After) == 1 {
+		if pos, ok := cursor.After[0].(int); ok {
+			start = pos + 1
+		}
+	}
+
+	var result ESResponse
+	next := cursor
+	for i, id := range m.breachOrder {
+		doc := m.breaches[id]
+		if !node.matches(doc) {
+			continue
+		}
+		result.Hits.Total.Value++
+		if i >= start && len(result.Hits.Hits) < size {
+			result.Hits.Hits = append(result.Hits.Hits, ESHit{ID: id, Source: copyDoc(doc), Sort: []interface{}{i}})
+			next.After = []interface{}{i}
+		}
+	}
+	return &result, next, nil
+}
+
+// CloseSearchCursor: MemoryStore tidak memakai PIT
+func (m *MemoryStore) CloseSearchCursor(cursor SearchCursor) {}
+
+// RefreshBreaches: data di RAM selalu langsung terlihat
+func (m *MemoryStore) RefreshBreaches() {}
+
+func (m *MemoryStore) IndexDocument(doc map[string]interface{}, id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.breaches[id]; !exists {
+		m.breachOrder = append(m.breachOrder, id)
+	}
+	m.breaches[id] = copyDoc(doc)
+}
+
+func (m *MemoryStore) BulkIndex(docs []BulkDocument) ([]BulkItemResult, error) {
+	for _, d := range docs {
+		m.IndexDocument(d.Doc, d.ID)
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) DeleteBySource(filename string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := 0
+	remaining := m.breachOrder[:0]
+	for _, id := range m.breachOrder {
+		if fmt.Sprintf("%v", m.breaches[id]["leak_source"]) == filename {
+			delete(m.breaches, id)
+			deleted++
+			continue
+		}
+		remaining = append(remaining, id)
+	}
+	m.breachOrder = remaining
+	return deleted
+}
+
+// DomainReport meniru aggregation di ElasticStore.DomainReport
+func (m *MemoryStore) DomainReport(domain string) (DomainReport, error) {
+	report := DomainReport{Domain: domain}
+	node, err := parseSearchQuery(domainQuery(domain))
+	if err != nil {
+		return report, err
+	}
+	plaintext, _ := parseSearchQuery(domainPlaintextQuery)
+	hashed, _ := parseSearchQuery(domainHashedQuery)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	accounts := make(map[string]bool)
+	sources := make(map[string]int64)
+	passwords := make(map[string]int64)
+	for _, id := range m.breachOrder {
+		doc := m.breaches[id]
+		if !node.matches(doc) {
+			continue
+		}
+		report.Records++
+		if email, ok := doc["email"]; ok {
+			accounts[strings.ToLower(fmt.Sprintf("%v", email))] = true
+		}
+		if src, ok := doc["leak_source"]; ok {
+			sources[fmt.Sprintf("%v", src)]++
+		}
+		if hashed.matches(doc) {
+			report.Hashed++
+		}
+		if plaintext.matches(doc) {
+			report.Plaintext++
+			if pw, ok := doc["password"]; ok {
+				passwords[fmt.Sprintf("%v", pw)]++
+			}
+		}
+		if uploaded, err := time.Parse(time.RFC3339, fmt.Sprintf("%v", doc["upload_date"])); err == nil && uploaded.After(report.LastUpload) {
+			report.LastUpload = uploaded
+		}
+	}
+	report.Accounts = int64(len(accounts))
+	report.Sources = topDomainCounts(sources, 1, domainReportSources)
+	report.TopPasswords = topDomainCounts(passwords, 2, domainReportPasswords)
+	return report, nil
+}
+
+// topDomainCounts: urut count terbesar lalu nama (seperti terms aggregation), min = min_doc_count
+func topDomainCounts(counts map[string]int64, min int64, size int) []DomainCount {
+	var out []DomainCount
+	for name, n := range counts {
+		if n >= min {
+			out = append(out, DomainCount{Name: name, Records: n})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Records != out[j].Records {
+			return out[i].Records > out[j].Records
+		}
+		return out[i].Name < out[j].Name
+	})
+	if len(out) > size {
+		out = out[:size]
+	}
+	return out
+}
+
+func (m *MemoryStore) GetClusterStats() SystemStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := SystemStats{
+		TotalRecords: int64(len(m.breaches)),
+		TotalUsers:   int64(len(m.authorized)),
+	}
+
+	sources := make(map[string]bool)
+	for _, doc := range m.breaches {
+		if src, ok := doc["leak_source"]; ok {
+			sources[fmt.Sprintf("%v", src)] = true
+		}
+	}
+	stats.TotalSources = len(sources)
+
+	// Top 3 keyword (meniru terms aggregation di ElasticStore)
+	counts := make(map[string]int)
+	for _, l := range m.activityLogs {
+		counts[l.Query]++
+	}
+	var keywords []string
+	for k := range counts {
+		keywords = append(keywords, k)
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if counts[keywords[i]] != counts[keywords[j]] {
+			return counts[keywords[i]] > counts[keywords[j]]
+		}
+		return keywords[i] < keywords[j]
+	})
+	if len(keywords) > 3 {
+		keywords = keywords[:3]
+	}
+	stats.TopSearches = keywords
+
+	return stats
+}
+
+// --- ACCESS KEYS ---
+
+func (m *MemoryStore) SaveAccessKey(key AccessKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accessKeys[key.Key] = key
+}
+
+func (m *MemoryStore) GetAccessKey(key string) (AccessKey, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	k, ok := m.accessKeys[key]
+	return k, ok
+}
+
+func (m *MemoryStore) RedeemAccessKey(key, userID string, now time.Time) (AccessKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.accessKeys[key]
+	if !ok {
+		return AccessKey{}, errKeyNotFound
+	}
+	k.RedeemedBy = append([]string(nil), k.RedeemedBy...)
+	if err := k.redeem(userID, now); err != nil {
+		return k, err
+	}
+	m.accessKeys[key] = k
+	return k, nil
+}
+
+func (m *MemoryStore) ListAccessKeys() []AccessKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]AccessKey, 0, len(m.accessKeys))
+	for _, k := range m.accessKeys {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (m *MemoryStore) RevokeAccessKey(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.accessKeys[key]
+	if !ok {
+		return false
+	}
+	k.Active = false
+	m.accessKeys[key] = k
+	return true
+}
+
+func (m *MemoryStore) DeleteAccessKey(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.accessKeys, key)
+}
+
+func (m *MemoryStore) ResetAllAccess() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accessKeys = make(map[string]AccessKey)
+	m.authorized = make(map[string]AuthorizedUser)
+}
+
+// --- AUTHORIZED USERS ---
+
+func (m *MemoryStore) AuthorizeUser(user AuthorizedUser) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.authorized[user.UserID] = user
+}
+
+func (m *MemoryStore) GetAuthorizedUser(userID string) (AuthorizedUser, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.authorized[userID]
+	return u, ok
+}
+
+func (m *MemoryStore) IsUserAuthorized(userID string) bool {
+	u, ok := m.GetAuthorizedUser(userID)
+	return ok && u.Active(time.Now())
+}
+
+func (m *MemoryStore) DeauthorizeUser(userID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.authorized[userID]
+	delete(m.authorized, userID)
+	return ok
+}
+
+func (m *MemoryStore) GetAllVerifiedUserIDs() []int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var userIDs []int64
+	now := time.Now()
+	for uidStr, u := range m.authorized {
+		if !u.Active(now) {
+			continue
+		}
+		if uid, err := strconv.ParseInt(uidStr, 10, 64); err == nil {
+			userIDs = append(userIDs, uid)
+		}
+	}
+	return userIDs
+}
+
+// --- BLACKLIST ---
+
+func (m *MemoryStore) IsUserBanned(userID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.blacklist[userID]
+	return ok
+}
+
+func (m *MemoryStore) BanUser(userID string, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blacklist[userID] = BlacklistEntry{UserID: userID, BannedAt: time.Now(), Reason: reason, BannedBy: "ADMIN"}
+}
+
+func (m *MemoryStore) UnbanUser(userID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blacklist, userID)
+}
+
+// --- ROLES ---
+
+func (m *MemoryStore) GetUserRole(userID string) Role {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if a, ok := m.roles[userID]; ok {
+		return a.Role
+	}
+	return roleUser
+}
+
+func (m *MemoryStore) SetUserRole(assignment RoleAssignment) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if assignment.Role == roleUser {
+		delete(m.roles, assignment.UserID)
+		return
+	}
+	m.roles[assignment.UserID] = assignment
+}
+
+func (m *MemoryStore) ListUserRoles() []RoleAssignment {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var roles []RoleAssignment
+	for _, a := range m.roles {
+		roles = append(roles, a)
+	}
+	return roles
+}
+
+// --- QUOTAS ---
+
+func (m *MemoryStore) GetUserQuota(userID string) (UserQuota, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	q, ok := m.quotas[userID]
+	q.UserID = userID
+	return q, ok, nil
+}
+
+func (m *MemoryStore) UpdateUserQuota(userID string, fn func(q *UserQuota) error) (UserQuota, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q := m.quotas[userID]
+	q.UserID = userID
+	if err := fn(&q); err != nil {
+		return q, err
+	}
+	m.quotas[userID] = q
+	return q, nil
+}
+
+// --- API TOKENS ---
+
+func (m *MemoryStore) SaveAPIToken(token APIToken) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apiTokens[token.Hash] = token
+}
+
+func (m *MemoryStore) GetAPIToken(hash string) (APIToken, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.apiTokens[hash]
+	return t, ok
+}
+
+func (m *MemoryStore) ListAPITokens(userID string) []APIToken {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var tokens []APIToken
+	for _, t := range m.apiTokens {
+		if userID == "" || t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens
+}
+
+func (m *MemoryStore) DeleteAPIToken(hash string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.apiTokens[hash]
+	delete(m.apiTokens, hash)
+	return ok
+}
+
+// --- WATCHLISTS ---
+
+func (m *MemoryStore) SaveWatch(watch Watch) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watches[watch.ID] = watch
+}
+
+func (m *MemoryStore) ListWatches(userID string) []Watch {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var watches []Watch
+	for _, w := range m.watches {
+		if userID == "" || w.UserID == userID {
+			watches = append(watches, w)
+		}
+	}
+	sort.Slice(watches, func(i, j int) bool { return watches[i].CreatedAt.Before(watches[j].CreatedAt) })
+	return watches
+}
+
+func (m *MemoryStore) DeleteWatch(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.watches[id]
+	delete(m.watches, id)
+	return ok
+}
+
+func (m *MemoryStore) ClaimWatchAlerts(userID string, recordIDs []string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var fresh []string
+	for _, id := range recordIDs {
+		key := userID + "\x00" + id
+		if m.alerted[key] {
+			continue
+		}
+		m.alerted[key] = true
+		fresh = append(fresh, id)
+	}
+	return fresh
+}
+
+func (m *MemoryStore) ReleaseWatchAlerts(userID string, recordIDs []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range recordIDs {
+		delete(m.alerted, userID+"\x00"+id)
+	}
+}
+
+// --- SYSTEM CONFIG ---
+
+func (m *MemoryStore) GetSystemConfig() SystemConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config == nil {
+		// Default sama dengan ElasticStore
+		return SystemConfig{Mode: "OPEN", RateLimit: 10}
+	}
+	return *m.config
+}
+
+func (m *MemoryStore) SaveSystemConfig(config SystemConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = &config
+}
+
+// --- ACTIVITY LOGS ---
+
+func (m *MemoryStore) LogActivity(user *tgbotapi.User, action string, content string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.activityLogs = append(m.activityLogs, UserActivity{
+		Timestamp:  time.Now(),
+		UserID:     strconv.FormatInt(user.ID, 10),
+		Username:   user.UserName,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		ActionType: action,
+		Query:      content,
+	})
+}
+
+func (m *MemoryStore) SearchActivity(keyword string, size int) (*ESResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	terms := strings.Fields(strings.ToLower(keyword))
+	var result ESResponse
+
+	// Terbaru dulu (sort timestamp desc)
+	for i := len(m.activityLogs) - 1; i >= 0; i-- {
+		l := m.activityLogs[i]
+		haystack := strings.ToLower(strings.Join([]string{l.Username, l.FirstName, l.LastName, l.Query}, " "))
+		matched := false
+		for _, t := range terms {
+			if strings.Contains(haystack, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		result.Hits.Total.Value++
+		if len(result.Hits.Hits) < size {
+			// Round-trip JSON agar bentuk _source sama persis dengan ES
+			var src map[string]interface{}
+			body, _ := json.Marshal(l)
+			json.Unmarshal(body, &src)
+			result.Hits.Hits = append(result.Hits.Hits, ESHit{Source: src})
+		}
+	}
+	return &result, nil
+}
+
+func (m *MemoryStore) GetAllUniqueLogUserIDs() []int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var userIDs []int64
+	for _, l := range m.activityLogs {
+		if seen[l.UserID] {
+			continue
+		}
+		seen[l.UserID] = true
+		if uid, err := strconv.ParseInt(l.UserID, 10, 64); err == nil {
+			userIDs = append(userIDs, uid)
+		}
+	}
+	return userIDs
+}
+
+func (m *MemoryStore) GenerateUserReport() []UserReport {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Log terakhir menimpa yang lama -> profil terbaru per user
+	userMap := make(map[string]UserReport)
+	for _, l := range m.activityLogs {
+		status := "GUEST"
+		if _, ok := m.authorized[l.UserID]; ok {
+			status = "VERIFIED"
+		}
+		userMap[l.UserID] = UserReport{
+			UserID:    l.UserID,
+			Username:  l.Username,
+			FirstName: l.FirstName,
+			LastName:  l.LastName,
+			Status:    status,
+		}
+	}
+
+	var report []UserReport
+	for _, u := range userMap {
+		report = append(report, u)
+	}
+	return report
+}
+
+// --- INGEST JOBS ---
+
+func (m *MemoryStore) SaveIngestJob(job IngestJobRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.UpdatedAt = time.Now()
+	job.Header = append([]string(nil), job.Header...)
+	m.ingestJobs[job.ID] = job
+}
+
+func (m *MemoryStore) ListIngestJobs() []IngestJobRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var jobs []IngestJobRecord
+	for _, j := range m.ingestJobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	return jobs
+}
+
+// --- HELPER ---
+
+func containsAllTerms(text string, query string) bool {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return false
+	}
+	text = strings.ToLower(text)
+	for _, t := range terms {
+		if !strings.Contains(text, t) {
+			return false
+		}
+	}
+	return true
+}
+
+func copyDoc(doc map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		out[k] = v
+	}
+	return out
+}
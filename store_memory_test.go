@@ -0,0 +1,182 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMemoryStoreSearch tests field:value and free-text search on MemoryStore.
+func TestMemoryStoreSearch(t *testing.T) {
+	store := NewMemoryStore()
+	store.IndexDocument(map[string]interface{}{"leak_source": "a.txt", "email": "rudi@gmail.com", "full_text": "rudi@gmail.com rahasia"}, "1")
+	store.IndexDocument(map[string]interface{}{"leak_source": "b.csv", "Email": "sudi@yahoo.com", "full_text": "sudi@yahoo.com sudi"}, "2")
+
+	searchTests := []struct {
+		keyword  string
+		expected int
+	}{
+		{"email:rudi@gmail.com", 1},
+		{"EMAIL:sudi@yahoo.com", 1},
+		{"sudi", 1},
+		{"rudi rahasia", 1},
+		{"rudi sudi", 0},
+		{"ip:127.0.0.1", 0},
+	}
+
+	for _, tt := range searchTests {
+		t.Run(tt.keyword, func(t *testing.T) {
+			result, err := store.SearchBreaches(tt.keyword, 10)
+			if err != nil {
+				t.Fatalf("SearchBreaches() error = %v", err)
+			}
+			if result.Hits.Total.Value != tt.expected {
+				t.Errorf("SearchBreaches() total = %d, want %d", result.Hits.Total.Value, tt.expected)
+			}
+		})
+	}
+
+	if deleted := store.DeleteBySource("a.txt"); deleted != 1 {
+		t.Errorf("DeleteBySource() = %d, want 1", deleted)
+	}
+	if stats := store.GetClusterStats(); stats.TotalRecords != 1 || stats.TotalSources != 1 {
+		t.Errorf("GetClusterStats() = %+v, want 1 record from 1 source", stats)
+	}
+}
+
+// TestMemoryStoreAccess tests the key, whitelist and blacklist flow on MemoryStore.
+func TestMemoryStoreAccess(t *testing.T) {
+	store := NewMemoryStore()
+	store.SaveAccessKey(AccessKey{Key: "BR-TEST1", Active: true, MaxUses: 1})
+
+	if _, ok := store.GetAccessKey("BR-TEST1"); !ok {
+		t.Fatal("GetAccessKey() = false for saved key")
+	}
+	store.AuthorizeUser(AuthorizedUser{UserID: "42", UsedKey: "BR-TEST1"})
+	store.DeleteAccessKey("BR-TEST1")
+
+	if _, ok := store.GetAccessKey("BR-TEST1"); ok {
+		t.Error("GetAccessKey() = true after DeleteAccessKey")
+	}
+	if !store.IsUserAuthorized("42") {
+		t.Error("IsUserAuthorized() = false after AuthorizeUser")
+	}
+
+	// Langganan yang sudah lewat tidak lagi dihitung sebagai authorized
+	past := time.Now().Add(-time.Hour)
+	store.AuthorizeUser(AuthorizedUser{UserID: "43", ExpiresAt: &past})
+	if store.IsUserAuthorized("43") {
+		t.Error("IsUserAuthorized() = true for expired user")
+	}
+	if ids := store.GetAllVerifiedUserIDs(); len(ids) != 1 || ids[0] != 42 {
+		t.Errorf("GetAllVerifiedUserIDs() = %v, want [42]", ids)
+	}
+
+	// Cabut key & keluarkan satu user tanpa reset global
+	store.SaveAccessKey(AccessKey{Key: "BR-TEST2", Active: true, MaxUses: 2})
+	if !store.RevokeAccessKey("BR-TEST2") || store.RevokeAccessKey("BR-NONE") {
+		t.Error("RevokeAccessKey() returned wrong result")
+	}
+	if _, err := store.RedeemAccessKey("BR-TEST2", "44", time.Now()); err != errKeyInactive {
+		t.Errorf("redeem revoked key error = %v, want errKeyInactive", err)
+	}
+	if keys := store.ListAccessKeys(); len(keys) != 1 || keys[0].Key != "BR-TEST2" {
+		t.Errorf("ListAccessKeys() = %+v", keys)
+	}
+	store.AuthorizeUser(AuthorizedUser{UserID: "45"})
+	if !store.DeauthorizeUser("45") || store.IsUserAuthorized("45") || !store.IsUserAuthorized("42") {
+		t.Error("DeauthorizeUser() did not remove only user 45")
+	}
+	if store.DeauthorizeUser("45") {
+		t.Error("DeauthorizeUser() = true for unknown user")
+	}
+
+	store.BanUser("42", "spam")
+	if !store.IsUserBanned("42") {
+		t.Error("IsUserBanned() = false after BanUser")
+	}
+	store.UnbanUser("42")
+	if store.IsUserBanned("42") {
+		t.Error("IsUserBanned() = true after UnbanUser")
+	}
+
+	store.ResetAllAccess()
+	if store.IsUserAuthorized("42") {
+		t.Error("IsUserAuthorized() = true after ResetAllAccess")
+	}
+}
+
+// TestMemoryStoreRedeemConcurrent tests that a key is never redeemed more than MaxUses times.
+func TestMemoryStoreRedeemConcurrent(t *testing.T) {
+	store := NewMemoryStore()
+	store.SaveAccessKey(AccessKey{Key: "BR-ONCE", Active: true, MaxUses: 1})
+	store.SaveAccessKey(AccessKey{Key: "BR-THREE", Active: true, MaxUses: 3})
+
+	for key, want := range map[string]int{"BR-ONCE": 1, "BR-THREE": 3} {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		wins := 0
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(uid string) {
+				defer wg.Done()
+				if _, err := store.RedeemAccessKey(key, uid, time.Now()); err == nil {
+					mu.Lock()
+					wins++
+					mu.Unlock()
+				}
+			}(strconv.Itoa(i))
+		}
+		wg.Wait()
+
+		k, _ := store.GetAccessKey(key)
+		if wins != want || k.Uses != want || len(k.RedeemedBy) != want || k.Active {
+			t.Errorf("%s: %d winners, key = %+v, want %d", key, wins, k, want)
+		}
+	}
+
+	// User yang sama tidak bisa memakai key multi-use dua kali
+	store.SaveAccessKey(AccessKey{Key: "BR-MULTI", Active: true, MaxUses: 5})
+	store.RedeemAccessKey("BR-MULTI", "7", time.Now())
+	if _, err := store.RedeemAccessKey("BR-MULTI", "7", time.Now()); err != errKeyRedeemed {
+		t.Errorf("second redeem by same user error = %v, want errKeyRedeemed", err)
+	}
+	if _, err := store.RedeemAccessKey("BR-NONE", "7", time.Now()); err != errKeyNotFound {
+		t.Errorf("unknown key error = %v, want errKeyNotFound", err)
+	}
+}
+
+// TestMemoryStoreSearchAfter tests that cursor paging visits every hit exactly once.
+func TestMemoryStoreSearchAfter(t *testing.T) {
+	store := NewMemoryStore()
+	for i := 0; i < 12; i++ {
+		text := "budi"
+		if i%3 == 0 {
+			text = "sari"
+		}
+		store.IndexDocument(map[string]interface{}{"full_text": text, "n": i}, string(rune('a'+i)))
+	}
+
+	seen := make(map[interface{}]bool)
+	cursor := SearchCursor{}
+	for page := 0; page < 3; page++ {
+		result, next, err := store.SearchBreachesAfter("budi", 3, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Hits.Total.Value != 8 {
+			t.Errorf("page %d total = %d, want 8", page, result.Hits.Total.Value)
+		}
+		for _, hit := range result.Hits.Hits {
+			if seen[hit.Source["n"]] {
+				t.Errorf("hit %v returned twice", hit.Source["n"])
+			}
+			seen[hit.Source["n"]] = true
+		}
+		cursor = next
+	}
+	if len(seen) != 8 {
+		t.Errorf("visited %d hits, want 8", len(seen))
+	}
+}
@@ -3,16 +3,42 @@ package main
 import "time"
 
 type ESResponse struct {
-	Hits struct {
+	PitID string `json:"pit_id,omitempty"`
+	Hits  struct {
 		Total struct {
 			Value int `json:"value"`
 		} `json:"total"`
-		Hits []struct {
-			Source map[string]interface{} `json:"_source"`
-		} `json:"hits"`
+		Hits []ESHit `json:"hits"`
 	} `json:"hits"`
 }
 
+type ESHit struct {
+	ID     string                 `json:"_id,omitempty"`
+	Source map[string]interface{} `json:"_source"`
+	Sort   []interface{}          `json:"sort,omitempty"` // Nilai sort untuk search_after
+}
+
+// SearchCursor menandai posisi awal satu halaman hasil pencarian.
+// PIT kosong = belum dibuka (halaman pertama) atau backend tanpa PIT (MemoryStore).
+type SearchCursor struct {
+	PIT   string
+	After []interface{}
+}
+
+// Satu dokumen untuk _bulk API
+type BulkDocument struct {
+	ID  string
+	Doc map[string]interface{}
+}
+
+// Hasil per item yang gagal di-index
+type BulkItemResult struct {
+	ID     string
+	Index  int // Posisi dokumen di request BulkIndex (ID bisa duplikat)
+	Status int
+	Error  string
+}
+
 type UserActivity struct {
 	Timestamp  time.Time `json:"timestamp"`
 	UserID     string    `json:"user_id"`
@@ -24,18 +50,68 @@ type UserActivity struct {
 }
 
 type AccessKey struct {
-	Key       string    `json:"key"`
-	CreatedAt time.Time `json:"created_at"`
-	Active    bool      `json:"active"`
+	Key        string        `json:"key"`
+	CreatedAt  time.Time     `json:"created_at"`
+	CreatedBy  string        `json:"created_by,omitempty"`
+	Active     bool          `json:"active"`
+	Duration   time.Duration `json:"duration,omitempty"`    // Lama akses per redeem, 0 = permanen
+	MaxUses    int           `json:"max_uses,omitempty"`    // Batas redeem, 0 dianggap 1 (key lama)
+	Uses       int           `json:"uses"`                  // Sudah berapa kali di-redeem
+	Tier       string        `json:"tier,omitempty"`        // Paket yang diberikan (lihat access_keys.go)
+	ExpiresAt  *time.Time    `json:"expires_at,omitempty"`  // Key tidak bisa di-redeem setelah ini
+	RedeemedBy []string      `json:"redeemed_by,omitempty"` // User ID yang sudah memakai key ini
 }
 
 type AuthorizedUser struct {
+	UserID     string     `json:"user_id"`
+	RedeemedAt time.Time  `json:"redeemed_at"`
+	UsedKey    string     `json:"used_key"`
+	Tier       string     `json:"tier,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // nil = akses permanen
+}
+
+// Token REST API (index api_tokens, Document ID = Hash). Token asli hanya ditampilkan sekali saat dibuat.
+type APIToken struct {
+	Hash      string    `json:"hash"` // SHA-256 token
+	ID        string    `json:"id"`   // Awalan hash untuk /apitoken revoke
 	UserID    string    `json:"user_id"`
-	RedeemedAt time.Time `json:"redeemed_at"`
-	UsedKey   string    `json:"used_key"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Watchlist user (index watchlists, Document ID = ID)
+type Watch struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Query     string    `json:"query"` // Query /s yang dipantau
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type SystemConfig struct {
-	Mode      string `json:"mode"`       // "OPEN" atau "CLOSE"
-	RateLimit int    `json:"rate_limit"` // Contoh: 10, 60, 300
-}
\ No newline at end of file
+	Mode      string `json:"mode"`                 // "OPEN" atau "CLOSE"
+	RateLimit int    `json:"rate_limit"`           // Token terisi per menit, contoh: 10, 60, 300
+	RateBurst int    `json:"rate_burst,omitempty"` // Kapasitas token (0 = sama dengan RateLimit)
+
+	ExportPolicy   map[Role]SensitivePolicy `json:"export_policy,omitempty"`   // Role -> mask/hash/include
+	EncryptExports bool                     `json:"encrypt_exports,omitempty"` // Paksa semua export jadi zip AES
+	ExportHashKey  string                   `json:"export_hash_key,omitempty"` // Kunci HMAC policy hash (hex), dibuat sekali
+}
+
+// Status & checkpoint job ingest (disimpan di index ingest_jobs)
+type IngestJobRecord struct {
+	ID        string    `json:"id"`
+	Source    string    `json:"source"` // Nama file = leak_source
+	Kind      string    `json:"kind"`   // "url" atau "file"
+	URL       string    `json:"url,omitempty"`
+	FileID    string    `json:"file_id,omitempty"` // Telegram file ID (URL file berisi token, jangan disimpan)
+	ChatID    int64     `json:"chat_id"`
+	Status    string    `json:"status"`
+	Offset    int64     `json:"offset"` // Byte terakhir yang datanya sudah pasti ter-index
+	Line      int64     `json:"line"`
+	Header    []string  `json:"header,omitempty"` // Header CSV (untuk resume di tengah file)
+	Indexed   int       `json:"indexed"`
+	Error     string    `json:"error,omitempty"`
+	Resumable bool      `json:"resumable,omitempty"` // FAILED karena download/koneksi, checkpoint masih valid untuk /resume
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base32"
@@ -14,10 +15,17 @@ func escapeMarkdown(text string) string {
 	return replacer.Replace(text)
 }
 
-// Cek apakah field mengandung data sensitif
+// Alias bawaan (field_aliases.go), dibangun sekali karena isSensitive dipanggil per kolom
+var sensitiveAliases = defaultFieldAliases()
+
+// Cek apakah field mengandung data sensitif, termasuk alias password seperti sandi, pw, md5
 func isSensitive(key string) bool {
 	k := strings.ToLower(key)
-	return strings.Contains(k, "pass") || strings.Contains(k, "hash") || strings.Contains(k, "pwd") || strings.Contains(k, "secret") || strings.Contains(k, "token")
+	if strings.Contains(k, "pass") || strings.Contains(k, "hash") || strings.Contains(k, "pwd") || strings.Contains(k, "secret") || strings.Contains(k, "token") {
+		return true
+	}
+	canonical := sensitiveAliases[aliasKey(key)]
+	return canonical == "password" || canonical == "password_hash"
 }
 
 // Sensor password
@@ -43,14 +51,62 @@ func maskPassword(line string) string {
 	return line
 }
 
+// Nama file aman dari keyword user: hanya huruf/angka/-/_/./@, maksimal 50 karakter
+func sanitizeFileName(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.', r == '@':
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+		if sb.Len() >= 50 {
+			break
+		}
+	}
+	out := strings.Trim(sb.String(), "._")
+	if out == "" {
+		return "export"
+	}
+	return out
+}
+
 func generateFingerprint(data string) string {
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
 
+// hmacFingerprint: HMAC-SHA256 dengan kunci server, tidak bisa dicocokkan dengan tabel hash umum
+func hmacFingerprint(key []byte, data string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateSecretKey() string {
+	bytes := make([]byte, 32)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
 func generateInviteKey() string {
 	bytes := make([]byte, 4) // 4 byte entropy
 	rand.Read(bytes)
 	// Output contoh: BR-J5M2A
 	return "BR-" + strings.ToUpper(strings.TrimRight(base32.StdEncoding.EncodeToString(bytes), "=")[:5])
 }
+
+func generateJobID() string {
+	bytes := make([]byte, 4)
+	rand.Read(bytes)
+	// Output contoh: J-7QK2M
+	return "J-" + strings.ToUpper(strings.TrimRight(base32.StdEncoding.EncodeToString(bytes), "=")[:5])
+}
+
+func generateWatchID() string {
+	bytes := make([]byte, 4)
+	rand.Read(bytes)
+	// Output contoh: W-4HTQZ
+	return "W-" + strings.ToUpper(strings.TrimRight(base32.StdEncoding.EncodeToString(bytes), "=")[:5])
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- WATCHLIST ---
+// /watch <query> menyimpan query per user (index watchlists). Setiap job ingest berakhir, semua watch
+// dievaluasi terhadap leak_source job tersebut. Record baru yang cocok dikirim ke pemilik watch
+// (kolom sensitif di-mask) dan dicatat di Store sehingga record yang sama tidak pernah dikirim dua kali.
+
+const (
+	watchMaxPerUser  = 20
+	watchPageSize    = 500 // Hit per halaman saat memeriksa satu watch, sekaligus ukuran batch klaim
+	watchPreviewSize = 5   // Record yang ditampilkan di notifikasi
+)
+
+// watchAlert: record baru satu watch di satu source
+type watchAlert struct {
+	Watch  Watch
+	Source string
+	IDs    []string // Semua record yang belum pernah dikirim ke user (sudah diklaim)
+	Hits   []ESHit  // Preview, maksimal watchPreviewSize
+}
+
+type WatchNotifier struct {
+	bot     *tgbotapi.BotAPI
+	store   Store
+	config  *LiveConfig
+	ownerID int64
+	mu      sync.Mutex // Evaluasi satu per satu walau beberapa job selesai bersamaan
+}
+
+func NewWatchNotifier(bot *tgbotapi.BotAPI, store Store, globalConfig *LiveConfig, ownerID int64) *WatchNotifier {
+	return &WatchNotifier{bot: bot, store: store, config: globalConfig, ownerID: ownerID}
+}
+
+// Evaluate dipanggil JobManager setelah job ingest berakhir (lihat JobManager.OnFinish)
+func (wn *WatchNotifier) Evaluate(rec IngestJobRecord) {
+	wn.mu.Lock()
+	defer wn.mu.Unlock()
+
+	watches := wn.store.ListWatches("")
+	if len(watches) == 0 {
+		return
+	}
+	wn.store.RefreshBreaches()
+
+	sent := 0
+	for _, w := range watches {
+		if !wn.subscriberAllowed(w.UserID) {
+			continue
+		}
+		alert, err := collectWatchAlert(wn.store, w, rec.Source)
+		if err != nil {
+			log.Printf("⚠️ Watch %s (%q) gagal dievaluasi: %v", w.ID, w.Query, err)
+			continue
+		}
+		if alert == nil {
+			continue
+		}
+		chatID, _ := strconv.ParseInt(w.UserID, 10, 64)
+		msg := tgbotapi.NewMessage(chatID, formatWatchAlert(alert))
+		msg.ParseMode = "Markdown"
+		if _, err := wn.bot.Send(msg); err != nil {
+			// Klaim dilepas agar record yang sama ikut alert berikutnya, bukan hilang selamanya
+			log.Printf("⚠️ Gagal mengirim alert watch %s ke %s: %v", w.ID, w.UserID, err)
+			wn.store.ReleaseWatchAlerts(w.UserID, alert.IDs)
+			continue
+		}
+		sent++
+	}
+	if sent > 0 {
+		log.Printf("🔔 Job %s (%s): %d alert watchlist terkirim", rec.ID, rec.Source, sent)
+	}
+}
+
+// subscriberAllowed: syarat sama dengan /s (tidak di-ban, punya akses di mode CLOSE)
+func (wn *WatchNotifier) subscriberAllowed(userID string) bool {
+	uid, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		return false
+	}
+	role := resolveRole(wn.store, wn.ownerID, uid)
+	if !role.Can(PermStaff) && wn.store.IsUserBanned(userID) {
+		return false
+	}
+	if role.Can(PermAccess) || wn.config.Get().Mode == "OPEN" {
+		return true
+	}
+	user, ok := wn.store.GetAuthorizedUser(userID)
+	return ok && user.Active(time.Now())
+}
+
+// collectWatchAlert menelusuri semua record watch di satu source per halaman (PIT + search_after,
+// sama seperti export) dan mengklaim yang belum pernah dikirim per halaman. nil jika tidak ada record baru.
+func collectWatchAlert(store Store, w Watch, source string) (*watchAlert, error) {
+	query := fmt.Sprintf("(%s) AND source:%s", w.Query, quoteQueryValue(source))
+	alert := &watchAlert{Watch: w, Source: source}
+	cursor := SearchCursor{}
+	defer func() { store.CloseSearchCursor(cursor) }()
+
+	for {
+		result, next, err := store.SearchBreachesAfter(query, watchPageSize, cursor)
+		if err != nil {
+			// Klaim halaman sebelumnya dilepas, evaluasi berikutnya mengulang dari awal
+			store.ReleaseWatchAlerts(w.UserID, alert.IDs)
+			return nil, err
+		}
+		cursor = next
+
+		byID := make(map[string]ESHit)
+		var ids []string
+		for _, hit := range result.Hits.Hits {
+			// source: dicocokkan longgar (frasa), pastikan leak_source memang dari job ini.
+			// Member arsip tercatat sebagai "<arsip>/<member>" (lihat ingest_archive.go).
+			leakSource := fmt.Sprintf("%v", hit.Source["leak_source"])
+			if hit.ID == "" || (leakSource != source && !strings.HasPrefix(leakSource, source+"/")) {
+				continue
+			}
+			byID[hit.ID] = hit
+			ids = append(ids, hit.ID)
+		}
+		for _, id := range store.ClaimWatchAlerts(w.UserID, ids) {
+			alert.IDs = append(alert.IDs, id)
+			if len(alert.Hits) < watchPreviewSize {
+				alert.Hits = append(alert.Hits, byID[id])
+			}
+		}
+		if len(result.Hits.Hits) < watchPageSize {
+			break
+		}
+	}
+
+	if len(alert.IDs) == 0 {
+		return nil, nil
+	}
+	return alert, nil
+}
+
+func formatWatchAlert(a *watchAlert) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🚨 *WATCHLIST ALERT*\nWatch `%s`: %d record baru di source `%s`\n\n",
+		escapeMarkdown(a.Watch.Query), len(a.IDs), escapeMarkdown(a.Source)))
+	for _, hit := range a.Hits {
+		sb.WriteString(formatSearchRecord(hit)) // Kolom sensitif di-mask dengan maskPassword
+	}
+	if more := len(a.IDs) - len(a.Hits); more > 0 {
+		sb.WriteString(fmt.Sprintf("_...dan %d record lainnya._\n", more))
+	}
+	sb.WriteString(fmt.Sprintf("\nHapus watch: `/unwatch %s`", a.Watch.ID))
+	return sb.String()
+}
+
+// --- HANDLERS ---
+
+// /watch           -> daftar watch milik sendiri
+// /watch <query>   -> pantau query (sintaks sama dengan /s)
+func handleWatch(ctx *CommandContext) {
+	userID := strconv.FormatInt(ctx.User.ID, 10)
+	watches := ctx.Store.ListWatches(userID)
+
+	if ctx.Args == "" {
+		if len(watches) == 0 {
+			ctx.Reply("📭 Belum ada watch. Contoh: `/watch email:ceo@acme.com` atau `/watch domain:acme.com`")
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString("👁 **WATCHLIST**\n\n")
+		for _, w := range watches {
+			sb.WriteString(fmt.Sprintf("`%s` %s\n", w.ID, w.Query))
+		}
+		sb.WriteString("\nHapus: `/unwatch <id>` atau `/unwatch all`")
+		ctx.Reply(sb.String())
+		return
+	}
+
+	if !validateQuery(ctx.Bot, ctx.ChatID, ctx.Args) {
+		return
+	}
+	for _, w := range watches {
+		if strings.EqualFold(w.Query, ctx.Args) {
+			ctx.Reply(fmt.Sprintf("ℹ️ Query ini sudah dipantau (`%s`).", w.ID))
+			return
+		}
+	}
+	if len(watches) >= watchMaxPerUser {
+		ctx.Reply(fmt.Sprintf("❌ Maksimal %d watch per user. Hapus dulu dengan `/unwatch <id>`.", watchMaxPerUser))
+		return
+	}
+
+	w := Watch{ID: generateWatchID(), UserID: userID, Query: ctx.Args, CreatedAt: time.Now()}
+	ctx.Store.SaveWatch(w)
+	ctx.Store.LogActivity(ctx.User, "WATCH", ctx.Args)
+	ctx.Reply(fmt.Sprintf("👁 Watch `%s` aktif: %s\nNotifikasi dikirim setiap ada data baru yang cocok.", w.ID, w.Query))
+}
+
+// /unwatch <id> | all
+func handleUnwatch(ctx *CommandContext) {
+	if ctx.Args == "" {
+		ctx.ReplyUsage()
+		return
+	}
+	userID := strconv.FormatInt(ctx.User.ID, 10)
+
+	removed := 0
+	for _, w := range ctx.Store.ListWatches(userID) {
+		if strings.EqualFold(ctx.Args, "all") || strings.EqualFold(ctx.Args, w.ID) {
+			if ctx.Store.DeleteWatch(w.ID) {
+				removed++
+			}
+		}
+	}
+	if removed == 0 {
+		ctx.Reply(fmt.Sprintf("❌ Watch `%s` tidak ditemukan.", ctx.Args))
+		return
+	}
+	ctx.Store.LogActivity(ctx.User, "UNWATCH", ctx.Args)
+	ctx.Reply(fmt.Sprintf("🗑 %d watch dihapus.", removed))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// TestCollectWatchAlert tests source scoping, archive members and per-user deduplication.
+func TestCollectWatchAlert(t *testing.T) {
+	store := NewMemoryStore()
+	docs := []struct {
+		id, email, source string
+	}{
+		{"1", "ceo@acme.com", "new.csv"},
+		{"2", "ceo@acme.com", "old.csv"},
+		{"3", "ceo@acme.com", "leak.zip/users.csv"},
+		{"4", "dev@acme.com", "new.csv"},
+		{"5", "ceo@acme.com", "renew.csv"},
+	}
+	for _, d := range docs {
+		store.IndexDocument(map[string]interface{}{"email": d.email, "password": "hunter2", "leak_source": d.source, "full_text": d.email}, d.id)
+	}
+	ceo := Watch{ID: "W-AAAAA", UserID: "1", Query: "email:ceo@acme.com"}
+
+	alert, err := collectWatchAlert(store, ceo, "new.csv")
+	if err != nil || alert == nil || len(alert.Hits) != 1 || alert.Hits[0].ID != "1" {
+		t.Fatalf("collectWatchAlert(new.csv) = %+v, %v, want record 1 only", alert, err)
+	}
+	if alert, _ := collectWatchAlert(store, ceo, "new.csv"); alert != nil {
+		t.Errorf("second evaluation = %+v, want nil (already alerted)", alert)
+	}
+	if alert, _ := collectWatchAlert(store, ceo, "leak.zip"); alert == nil || len(alert.Hits) != 1 || alert.Hits[0].ID != "3" {
+		t.Errorf("archive evaluation = %+v, want member record 3", alert)
+	}
+
+	// Watch lain milik user yang sama tidak mengirim ulang record 1, user lain tetap dapat
+	if alert, _ := collectWatchAlert(store, Watch{UserID: "1", Query: "email:acme.com"}, "new.csv"); alert == nil || len(alert.Hits) != 1 || alert.Hits[0].ID != "4" {
+		t.Errorf("overlapping watch = %+v, want record 4 only", alert)
+	}
+	if alert, _ := collectWatchAlert(store, Watch{UserID: "2", Query: "email:ceo@acme.com"}, "new.csv"); alert == nil || len(alert.Hits) != 1 {
+		t.Errorf("other user = %+v, want record 1", alert)
+	}
+
+	text := formatWatchAlert(alert)
+	if strings.Contains(text, "hunter2") || !strings.Contains(text, "PASSWORD") {
+		t.Errorf("alert text is not masked:\n%s", text)
+	}
+}
+
+// TestCollectWatchAlertPaging tests that every matching record is claimed, not only the first page.
+func TestCollectWatchAlertPaging(t *testing.T) {
+	store := NewMemoryStore()
+	const records = watchPageSize*2 + 3
+	for i := 0; i < records; i++ {
+		store.IndexDocument(map[string]interface{}{"email": fmt.Sprintf("u%d@acme.com", i), "leak_source": "big.csv"}, fmt.Sprint(i))
+	}
+	w := Watch{ID: "W-BBBBB", UserID: "1", Query: "email:acme.com"}
+
+	alert, err := collectWatchAlert(store, w, "big.csv")
+	if err != nil || alert == nil || len(alert.IDs) != records || len(alert.Hits) != watchPreviewSize {
+		t.Fatalf("collectWatchAlert() = %+v, %v, want %d claimed records", alert, err, records)
+	}
+	if text := formatWatchAlert(alert); !strings.Contains(text, fmt.Sprintf("%d record baru", records)) || !strings.Contains(text, fmt.Sprintf("dan %d record lainnya", records-watchPreviewSize)) {
+		t.Errorf("alert text does not report all records:\n%s", text)
+	}
+	if again, _ := collectWatchAlert(store, w, "big.csv"); again != nil {
+		t.Errorf("second evaluation claimed %d records, want none", len(again.IDs))
+	}
+}
+
+// TestWatchNotifierSendFailure tests that records of an alert Telegram rejected are alerted again later.
+func TestWatchNotifierSendFailure(t *testing.T) {
+	var blocked atomic.Bool
+	var alerts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getMe"):
+			fmt.Fprint(w, `{"ok": true, "result": {"id": 999, "is_bot": true, "username": "radar_bot"}}`)
+		case blocked.Load():
+			fmt.Fprint(w, `{"ok": false, "error_code": 403, "description": "Forbidden: bot was blocked by the user"}`)
+		default:
+			alerts.Add(1)
+			fmt.Fprint(w, `{"ok": true, "result": {"message_id": 1, "chat": {"id": 1}}}`)
+		}
+	}))
+	defer srv.Close()
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("test", srv.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewMemoryStore()
+	store.IndexDocument(map[string]interface{}{"email": "ceo@acme.com", "leak_source": "new.csv"}, "1")
+	store.SaveWatch(Watch{ID: "W-AAAAA", UserID: "1", Query: "email:ceo@acme.com"})
+	wn := NewWatchNotifier(bot, store, NewLiveConfig(SystemConfig{Mode: "OPEN"}), 0)
+
+	blocked.Store(true)
+	wn.Evaluate(IngestJobRecord{ID: "J1", Source: "new.csv"})
+	blocked.Store(false)
+	wn.Evaluate(IngestJobRecord{ID: "J1", Source: "new.csv"})
+	wn.Evaluate(IngestJobRecord{ID: "J1", Source: "new.csv"})
+	if n := alerts.Load(); n != 1 {
+		t.Errorf("alerts delivered = %d, want 1 (retried after the failed send, then deduplicated)", n)
+	}
+}
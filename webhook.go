@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- SUMBER UPDATE: LONG POLLING / WEBHOOK ---
+// BOT_MODE=polling (default) memakai getUpdates. BOT_MODE=webhook menjalankan HTTP server yang
+// menerima update di path rahasia, cocok di belakang reverse proxy & beberapa instance.
+// Keduanya meneruskan update ke UpdateDispatcher yang sama.
+
+const (
+	defaultPollTimeout   = 60
+	defaultWebhookListen = ":8080"
+	webhookSecretHeader  = "X-Telegram-Bot-Api-Secret-Token"
+	webhookMaxBodyBytes  = 1 << 20
+)
+
+// Aturan Telegram untuk secret_token: 1-256 karakter A-Z, a-z, 0-9, _ dan -
+var webhookSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+type webhookSettings struct {
+	PublicURL string // Contoh: https://bot.example.com (tanpa path)
+	Listen    string // Alamat HTTP server, contoh :8080
+	Path      string // Path rahasia, default diturunkan dari secret
+	Secret    string // Dicocokkan dengan header X-Telegram-Bot-Api-Secret-Token
+	TLSCert   string // Opsional, kosong = HTTP biasa (TLS di reverse proxy)
+	TLSKey    string
+}
+
+// webhookSettingsFromEnv: ok = false jika BOT_MODE bukan webhook
+func webhookSettingsFromEnv() (settings webhookSettings, ok bool, err error) {
+	if !strings.EqualFold(os.Getenv("BOT_MODE"), "webhook") {
+		return settings, false, nil
+	}
+	settings = webhookSettings{
+		PublicURL: strings.TrimRight(os.Getenv("WEBHOOK_URL"), "/"),
+		Listen:    os.Getenv("WEBHOOK_LISTEN"),
+		Path:      os.Getenv("WEBHOOK_PATH"),
+		Secret:    os.Getenv("WEBHOOK_SECRET"),
+		TLSCert:   os.Getenv("WEBHOOK_TLS_CERT"),
+		TLSKey:    os.Getenv("WEBHOOK_TLS_KEY"),
+	}
+	if u, perr := url.Parse(settings.PublicURL); perr != nil || u.Scheme != "https" || u.Host == "" {
+		return settings, true, errors.New("WEBHOOK_URL wajib berupa URL https://")
+	}
+	if !webhookSecretPattern.MatchString(settings.Secret) {
+		return settings, true, errors.New("WEBHOOK_SECRET wajib diisi (1-256 karakter A-Z, a-z, 0-9, _ atau -)")
+	}
+	if (settings.TLSCert == "") != (settings.TLSKey == "") {
+		return settings, true, errors.New("WEBHOOK_TLS_CERT dan WEBHOOK_TLS_KEY harus diisi berdua")
+	}
+	if settings.Listen == "" {
+		settings.Listen = defaultWebhookListen
+	}
+	if settings.Path == "" {
+		// Path tidak bisa ditebak tanpa secret, tapi juga tidak membocorkan secret di log proxy
+		sum := sha256.Sum256([]byte(settings.Secret))
+		settings.Path = "/telegram/" + hex.EncodeToString(sum[:16])
+	}
+	if !strings.HasPrefix(settings.Path, "/") {
+		settings.Path = "/" + settings.Path
+	}
+	return settings, true, nil
+}
+
+// newWebhookHandler memvalidasi request dari Telegram lalu meneruskan update ke submit
+func newWebhookHandler(secret string, submit func(update tgbotapi.Update)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		got := r.Header.Get(webhookSecretHeader)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var update tgbotapi.Update
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, webhookMaxBodyBytes)).Decode(&update); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		// Submit bisa menunggu jika worker penuh; Telegram menahan update berikutnya sampai dijawab
+		submit(update)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// runWebhook mendaftarkan webhook ke Telegram lalu menjalankan HTTP server (blocking)
+func runWebhook(bot *tgbotapi.BotAPI, settings webhookSettings, submit func(update tgbotapi.Update)) error {
+	// tgbotapi.WebhookConfig belum mendukung secret_token, jadi parameter diisi manual
+	params := tgbotapi.Params{
+		"url":          settings.PublicURL + settings.Path,
+		"secret_token": settings.Secret,
+	}
+	if _, err := bot.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("setWebhook gagal: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(settings.Path, newWebhookHandler(settings.Secret, submit))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	server := &http.Server{
+		Addr:              settings.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("🌐 Webhook aktif di %s (listen %s)", settings.PublicURL, settings.Listen)
+	if settings.TLSCert != "" {
+		return server.ListenAndServeTLS(settings.TLSCert, settings.TLSKey)
+	}
+	return server.ListenAndServe()
+}
+
+// runPolling memakai getUpdates (blocking). Webhook lama dihapus dulu karena Telegram
+// menolak getUpdates selama webhook masih terpasang.
+func runPolling(bot *tgbotapi.BotAPI, submit func(update tgbotapi.Update)) {
+	if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		log.Printf("⚠️ Gagal menghapus webhook lama: %v", err)
+	}
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = pollTimeoutFromEnv()
+	updates := bot.GetUpdatesChan(u)
+
+	for update := range updates {
+		submit(update)
+	}
+}
+
+func pollTimeoutFromEnv() int {
+	if v, err := strconv.Atoi(os.Getenv("POLL_TIMEOUT")); err == nil && v > 0 {
+		return v
+	}
+	return defaultPollTimeout
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// TestWebhookHandler tests secret token validation and update forwarding.
+func TestWebhookHandler(t *testing.T) {
+	var got []tgbotapi.Update
+	h := newWebhookHandler("s3cret_token", func(u tgbotapi.Update) { got = append(got, u) })
+
+	body := `{"update_id": 77, "message": {"message_id": 1, "text": "/s rudi", "chat": {"id": 5}, "from": {"id": 5}}}`
+	tests := []struct {
+		name   string
+		method string
+		secret string
+		body   string
+		want   int
+	}{
+		{"valid", http.MethodPost, "s3cret_token", body, http.StatusOK},
+		{"missing secret", http.MethodPost, "", body, http.StatusUnauthorized},
+		{"wrong secret", http.MethodPost, "s3cret_tokeN", body, http.StatusUnauthorized},
+		{"wrong method", http.MethodGet, "s3cret_token", "", http.StatusMethodNotAllowed},
+		{"bad json", http.MethodPost, "s3cret_token", "{", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/telegram/x", strings.NewReader(tt.body))
+		if tt.secret != "" {
+			req.Header.Set(webhookSecretHeader, tt.secret)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+
+	if len(got) != 1 || got[0].UpdateID != 77 || got[0].Message.Text != "/s rudi" {
+		t.Errorf("forwarded updates = %+v, want only update 77", got)
+	}
+}
+
+// TestWebhookSettingsFromEnv tests webhook configuration validation.
+func TestWebhookSettingsFromEnv(t *testing.T) {
+	t.Setenv("BOT_MODE", "")
+	if _, ok, err := webhookSettingsFromEnv(); ok || err != nil {
+		t.Fatalf("polling mode = (%v, %v), want (false, nil)", ok, err)
+	}
+
+	t.Setenv("BOT_MODE", "webhook")
+	t.Setenv("WEBHOOK_URL", "https://bot.example.com/")
+	t.Setenv("WEBHOOK_SECRET", "abc_DEF-123")
+	s, ok, err := webhookSettingsFromEnv()
+	if !ok || err != nil {
+		t.Fatalf("webhookSettingsFromEnv() = (%v, %v)", ok, err)
+	}
+	if s.PublicURL != "https://bot.example.com" || s.Listen != defaultWebhookListen || !strings.HasPrefix(s.Path, "/telegram/") || strings.Contains(s.Path, s.Secret) {
+		t.Errorf("settings = %+v", s)
+	}
+
+	for env, value := range map[string]string{"WEBHOOK_SECRET": "has space", "WEBHOOK_URL": "http://insecure.example.com", "WEBHOOK_TLS_CERT": "cert.pem"} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, value)
+			if _, _, err := webhookSettingsFromEnv(); err == nil {
+				t.Errorf("%s=%q accepted", env, value)
+			}
+		})
+	}
+}